@@ -1,7 +1,22 @@
 package main
 
-import "PlanetSimulation/internal/planetsimulation"
+import (
+	"os"
+
+	"PlanetSimulation/internal/headless"
+	"PlanetSimulation/internal/planetsimulation"
+)
 
 func main() {
-	planetsimulation.Start()
+	// "run" and "sweep" step simulations without opening a window
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(headless.Run(os.Args[2:], os.Stdout, os.Stderr))
+		case "sweep":
+			os.Exit(headless.Sweep(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	os.Exit(planetsimulation.Start(os.Args[1:], os.Stderr))
 }
@@ -2,10 +2,12 @@ module PlanetSimulation
 
 go 1.25.5
 
-require github.com/hajimehoshi/ebiten/v2 v2.9.8
+require (
+	github.com/ebitengine/debugui v0.2.0
+	github.com/hajimehoshi/ebiten/v2 v2.9.8
+)
 
 require (
-	github.com/ebitengine/debugui v0.2.0 // indirect
 	github.com/ebitengine/gomobile v0.0.0-20250923094054-ea854a63cce1 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
 	github.com/ebitengine/purego v0.9.1 // indirect
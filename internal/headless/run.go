@@ -0,0 +1,350 @@
+// Package headless runs simulations without graphics, e.g. in scripts and
+// continuous integration.
+package headless
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"PlanetSimulation/internal/physics"
+)
+
+// exit codes of Run
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+type runOptions struct {
+	file             string
+	preset           string
+	ticks            int
+	seconds          float64
+	timeStep         float64
+	integrator       string
+	outDirectory     string
+	recordEveryNTick int
+	// checks, disabled when zero or negative
+	maxEnergyDrift float64
+	expectBodies   int
+	noMerges       bool
+	maxDistance    float64
+}
+
+type mergeRecord struct {
+	Tick     int     `json:"tick"`
+	Time     float64 `json:"time"`
+	Survivor string  `json:"survivor"`
+	Absorbed string  `json:"absorbed"`
+}
+
+type checkResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// diagnostics is written to diagnostics.json. The energy isn't conserved by
+// merges, so the drift includes them.
+type diagnostics struct {
+	Scenario             string        `json:"scenario"`
+	Integrator           string        `json:"integrator"`
+	TimeStep             float64       `json:"timeStep"`
+	Ticks                int           `json:"ticks"`
+	Time                 float64       `json:"time"`
+	InitialBodies        int           `json:"initialBodies"`
+	FinalBodies          int           `json:"finalBodies"`
+	InitialEnergy        float64       `json:"initialEnergy"`
+	FinalEnergy          float64       `json:"finalEnergy"`
+	EnergyDrift          float64       `json:"energyDrift"`
+	AngularMomentumDrift float64       `json:"angularMomentumDrift"`
+	MomentumChange       float64       `json:"momentumChange"`
+	MaxDistance          float64       `json:"maxDistance"`
+	Merges               []mergeRecord `json:"merges"`
+	Checks               []checkResult `json:"checks"`
+	Passed               bool          `json:"passed"`
+}
+
+func newRunFlags(options *runOptions, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: planetsimulation run [flags]")
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "Runs a simulation preset without graphics and writes final_state.json,")
+		fmt.Fprintln(output, "trajectories.csv and diagnostics.json. Exits with 1 if a check fails and")
+		fmt.Fprintln(output, "with 2 if the scenario can't be run.")
+		fmt.Fprintln(output)
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&options.file, "file", "", "scenario `file` with a single preset or a presets file")
+	flags.StringVar(&options.preset, "preset", "", "`name` of the preset, searched in -file or in your and the built-in presets")
+	flags.IntVar(&options.ticks, "ticks", 0, "number of ticks to run")
+	flags.Float64Var(&options.seconds, "seconds", 0, "simulated `seconds` to run instead of -ticks")
+	flags.Float64Var(&options.timeStep, "dt", 0, "simulated `seconds` per tick, defaults to the time step of the preset or 1/120")
+	flags.StringVar(&options.integrator, "integrator", "", "euler or leapfrog, defaults to the integrator of the preset")
+	flags.StringVar(&options.outDirectory, "out", "run", "`directory` for the output files")
+	flags.IntVar(&options.recordEveryNTick, "record-every", 10, "`ticks` between trajectory samples, 0 writes no trajectories")
+	flags.Float64Var(&options.maxEnergyDrift, "max-energy-drift", 0, "fail if the relative energy drift is larger, 0 disables the check")
+	flags.IntVar(&options.expectBodies, "expect-bodies", -1, "fail unless this many bodies are left, -1 disables the check")
+	flags.BoolVar(&options.noMerges, "no-merges", false, "fail if bodies merged")
+	flags.Float64Var(&options.maxDistance, "max-distance", 0, "fail if a body ends farther from the centre of mass, e.g. because it was ejected, 0 disables the check")
+
+	return flags
+}
+
+// Run runs a simulation headless, args are the arguments after "run". It
+// returns the exit code of the process.
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	options := runOptions{}
+	flags := newRunFlags(&options, stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitPassed
+		}
+		return exitError
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments %q\n", flags.Args())
+		flags.Usage()
+		return exitError
+	}
+
+	result, err := run(options)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	fmt.Fprintf(stdout, "Ran %s for %d ticks (%s s): %d of %d bodies left, %d merges, energy drift %.3g\n",
+		result.Scenario, result.Ticks, strconv.FormatFloat(result.Time, 'g', 6, 64), result.FinalBodies, result.InitialBodies, len(result.Merges), result.EnergyDrift)
+	for _, check := range result.Checks {
+		status := "PASS"
+		if !check.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(stdout, "%s %s: %s\n", status, check.Name, check.Message)
+	}
+	fmt.Fprintf(stdout, "Wrote the results to %s\n", options.outDirectory)
+
+	if !result.Passed {
+		return exitFailed
+	}
+	return exitPassed
+}
+
+// run loads the scenario, steps it and writes the output files.
+func run(options runOptions) (*diagnostics, error) {
+	scenario, err := loadScenario(options.file, options.preset)
+	if err != nil {
+		return nil, err
+	}
+	if options.integrator != "" {
+		scenario.Integrator = options.integrator
+		if err := scenario.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	timeStep, ticks, err := scenario.duration(options.timeStep, options.ticks, options.seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(options.outDirectory, 0o755); err != nil {
+		return nil, err
+	}
+
+	system := scenario.system()
+	result := &diagnostics{
+		Scenario:      scenario.Name,
+		Integrator:    system.Integrator.String(),
+		TimeStep:      timeStep,
+		Ticks:         ticks,
+		InitialBodies: len(system.Bodies),
+		Merges:        make([]mergeRecord, 0),
+	}
+	kinetic, potential := system.Energy()
+	result.InitialEnergy = kinetic + potential
+	initialAngularMomentum := system.AngularMomentum()
+	initialMomentum := system.Momentum()
+
+	trajectories, err := newTrajectoryWriter(filepath.Join(options.outDirectory, "trajectories.csv"), options.recordEveryNTick)
+	if err != nil {
+		return nil, err
+	}
+	trajectories.write(system, true)
+
+	for tick := 1; tick <= ticks; tick++ {
+		for _, merge := range system.Step(timeStep) {
+			result.Merges = append(result.Merges, mergeRecord{system.Tick, system.Time, merge.Survivor.Name, merge.Absorbed.Name})
+		}
+		trajectories.write(system, tick == ticks)
+	}
+	if err := trajectories.close(); err != nil {
+		return nil, err
+	}
+
+	result.Time = system.Time - scenario.Time
+	result.FinalBodies = len(system.Bodies)
+	kinetic, potential = system.Energy()
+	result.FinalEnergy = kinetic + potential
+	result.EnergyDrift = physics.RelativeDrift(result.InitialEnergy, result.FinalEnergy)
+	result.AngularMomentumDrift = physics.RelativeDrift(initialAngularMomentum, system.AngularMomentum())
+	result.MomentumChange = system.Momentum().Sub(initialMomentum).Length()
+	center := system.CenterOfMass()
+	for _, body := range system.Bodies {
+		result.MaxDistance = max(result.MaxDistance, body.Position().Sub(center).Length())
+	}
+	result.check(options)
+
+	final := scenario
+	final.Name = fmt.Sprintf("%s at tick %d", scenario.Name, system.Tick)
+	final.Integrator = system.Integrator.String()
+	final.GravitationalConstant = system.GravitationalConstant
+	final.TimeStep = timeStep
+	final.Tick = system.Tick
+	final.Time = system.Time
+	final.Bodies = make([]physics.Body, len(system.Bodies))
+	for i, body := range system.Bodies {
+		final.Bodies[i] = *body
+	}
+
+	if err := writeJSON(filepath.Join(options.outDirectory, "final_state.json"), final); err != nil {
+		return nil, err
+	}
+	if err := writeJSON(filepath.Join(options.outDirectory, "diagnostics.json"), result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// duration returns the time step, the one of the scenario or 1/120 if
+// timeStep is zero, and the number of ticks to run, ticks or the ticks that
+// make up seconds.
+func (scenario *scenario) duration(timeStep float64, ticks int, seconds float64) (float64, int, error) {
+	if timeStep <= 0 {
+		timeStep = scenario.TimeStep
+	}
+	if timeStep <= 0 {
+		timeStep = 1.0 / 120
+	}
+
+	if seconds > 0 {
+		// a tiny tolerance so 1 / 0.1 doesn't become 11 ticks
+		ticks = int(math.Ceil(seconds/timeStep - 1e-9))
+	}
+	if ticks <= 0 {
+		return 0, 0, errors.New("choose how long to run with ticks or seconds")
+	}
+
+	return timeStep, ticks, nil
+}
+
+// check evaluates the enabled checks.
+func (result *diagnostics) check(options runOptions) {
+	result.Checks = make([]checkResult, 0)
+	add := func(name string, passed bool, format string, args ...any) {
+		result.Checks = append(result.Checks, checkResult{name, passed, fmt.Sprintf(format, args...)})
+	}
+
+	if options.maxEnergyDrift > 0 {
+		add("energy drift", result.EnergyDrift <= options.maxEnergyDrift, "%.3g, at most %.3g", result.EnergyDrift, options.maxEnergyDrift)
+	}
+	if options.expectBodies >= 0 {
+		add("bodies", result.FinalBodies == options.expectBodies, "%d left, expected %d", result.FinalBodies, options.expectBodies)
+	}
+	if options.noMerges {
+		add("no merges", len(result.Merges) == 0, "%d merges", len(result.Merges))
+	}
+	if options.maxDistance > 0 {
+		add("distance", result.MaxDistance <= options.maxDistance, "farthest body %.6g from the centre of mass, at most %.6g", result.MaxDistance, options.maxDistance)
+	}
+
+	result.Passed = true
+	for _, check := range result.Checks {
+		result.Passed = result.Passed && check.Passed
+	}
+}
+
+func writeJSON(path string, v any) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// trajectoryWriter writes the state of every body every N ticks in the CSV
+// format of the trajectory recorder of the game.
+type trajectoryWriter struct {
+	file             *os.File
+	writer           *bufio.Writer
+	csvWriter        *csv.Writer
+	recordEveryNTick int
+	lastTick         int
+}
+
+func newTrajectoryWriter(path string, recordEveryNTick int) (*trajectoryWriter, error) {
+	if recordEveryNTick <= 0 {
+		// nothing is written, an old file would be misleading
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return &trajectoryWriter{}, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(file)
+	trajectories := &trajectoryWriter{
+		file:             file,
+		writer:           writer,
+		csvWriter:        csv.NewWriter(writer),
+		recordEveryNTick: recordEveryNTick,
+		lastTick:         -1,
+	}
+	trajectories.csvWriter.Write(physics.TrajectoryColumns)
+
+	return trajectories, nil
+}
+
+// write records the bodies if enough ticks have passed or force is set.
+func (trajectories *trajectoryWriter) write(system *physics.System, force bool) {
+	if trajectories.file == nil || system.Tick == trajectories.lastTick {
+		return
+	}
+	if !force && trajectories.lastTick >= 0 && system.Tick-trajectories.lastTick < trajectories.recordEveryNTick {
+		return
+	}
+	trajectories.lastTick = system.Tick
+
+	for _, body := range system.Bodies {
+		trajectories.csvWriter.Write(physics.NewTrajectorySample(system.Tick, system.Time, body).Row())
+	}
+}
+
+func (trajectories *trajectoryWriter) close() error {
+	if trajectories.file == nil {
+		return nil
+	}
+
+	trajectories.csvWriter.Flush()
+	err := errors.Join(trajectories.csvWriter.Error(), trajectories.writer.Flush())
+	return errors.Join(err, trajectories.file.Close())
+}
@@ -0,0 +1,162 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a light planet on a circular orbit around a star and a moon on a collision
+// course with the star
+const testScenario = `{
+	"version": 2,
+	"presets": [{
+		"name": "Orbit",
+		"gravitationalConstant": 10000,
+		"integrator": "leapfrog",
+		"timeStep": 0.001,
+		"bodies": [
+			{"name": "Star", "x": 0, "y": 0, "velocity": {"x": 0, "y": 0}, "mass": 1000, "radius": 10},
+			{"name": "Planet", "x": 200, "y": 0, "velocity": {"x": 0, "y": 223.60679774997897}, "mass": 0.000001, "radius": 1}
+		]
+	}, {
+		"name": "Crash",
+		"bodies": [
+			{"name": "Star", "x": 0, "y": 0, "velocity": {"x": 0, "y": 0}, "mass": 1000, "radius": 10},
+			{"name": "Moon", "x": 50, "y": 0, "velocity": {"x": 0, "y": 0}, "mass": 1, "radius": 2}
+		]
+	}]
+}`
+
+func writeTestScenario(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(testScenario), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestRunPasses(t *testing.T) {
+	file := writeTestScenario(t)
+	out := t.TempDir()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	code := Run([]string{"-file", file, "-preset", "orbit", "-seconds", "1", "-out", out, "-max-energy-drift", "1e-6", "-expect-bodies", "2", "-no-merges", "-max-distance", "250"}, stdout, stderr)
+	if code != exitPassed {
+		t.Fatalf("exit code %d, stdout %s, stderr %s", code, stdout, stderr)
+	}
+
+	content, err := os.ReadFile(filepath.Join(out, "diagnostics.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := diagnostics{}
+	if err := json.Unmarshal(content, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Ticks != 1000 || len(result.Checks) != 4 || !result.Passed {
+		t.Errorf("diagnostics = %+v", result)
+	}
+
+	trajectories, err := os.Open(filepath.Join(out, "trajectories.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trajectories.Close()
+	rows, err := csv.NewReader(trajectories).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header, tick 0 and every 10th tick for both bodies
+	if want := 1 + 2*101; len(rows) != want || strings.Join(rows[0], ",") != "tick,time,id,name,x,y,vx,vy,mass" {
+		t.Errorf("got %d rows starting with %v, want %d", len(rows), rows[0], want)
+	}
+}
+
+func TestRunFailsChecks(t *testing.T) {
+	file := writeTestScenario(t)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	code := Run([]string{"-file", file, "-preset", "Crash", "-ticks", "2000", "-dt", "0.001", "-out", t.TempDir(), "-no-merges"}, stdout, stderr)
+	if code != exitFailed {
+		t.Fatalf("exit code %d, stdout %s, stderr %s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout.String(), "FAIL no merges: 1 merges") {
+		t.Errorf("stdout = %s", stdout)
+	}
+}
+
+func TestRunContinuesFromFinalState(t *testing.T) {
+	file := writeTestScenario(t)
+	out := t.TempDir()
+	args := []string{"-preset", "Orbit", "-ticks", "100", "-out", out, "-record-every", "0"}
+	if code := Run(append(args, "-file", file), &bytes.Buffer{}, &bytes.Buffer{}); code != exitPassed {
+		t.Fatalf("exit code %d", code)
+	}
+
+	finalState := filepath.Join(out, "final_state.json")
+	stdout := &bytes.Buffer{}
+	if code := Run([]string{"-file", finalState, "-ticks", "100", "-out", out}, stdout, &bytes.Buffer{}); code != exitPassed {
+		t.Fatalf("exit code %d", code)
+	}
+
+	content, err := os.ReadFile(finalState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final := scenario{}
+	if err := json.Unmarshal(content, &final); err != nil {
+		t.Fatal(err)
+	}
+	if final.Tick != 200 || final.Bodies[1].ID != 2 || final.Integrator != "leapfrog" {
+		t.Errorf("final state = %+v", final)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	file := writeTestScenario(t)
+	tests := map[string][]string{
+		"unknown preset":     {"-file", file, "-preset", "Nothing", "-ticks", "1"},
+		"ambiguous file":     {"-file", file, "-ticks", "1"},
+		"no duration":        {"-file", file, "-preset", "Orbit"},
+		"unknown integrator": {"-file", file, "-preset", "Orbit", "-ticks", "1", "-integrator", "rk4"},
+		"unknown flag":       {"-frobnicate"},
+	}
+
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			if code := Run(append(args, "-out", t.TempDir()), &bytes.Buffer{}, stderr); code != exitError {
+				t.Errorf("exit code %d, want %d", code, exitError)
+			}
+			if stderr.Len() == 0 {
+				t.Error("nothing was reported")
+			}
+		})
+	}
+}
+
+func TestDecodeScenariosValidatesLikeTheGame(t *testing.T) {
+	// version 0 files are migrated, the default gravitational constant is
+	// fine
+	legacy := `{"Presets": [{"Name": "Old", "Planets": [{"Name": "Star", "Radius": 10, "Mass": 1000, "velocity": {"x": 0, "y": 0}}]}]}`
+	scenarios, err := decodeScenarios([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) != 1 || scenarios[0].Name != "Old" || scenarios[0].Bodies[0].Mass != 1000 {
+		t.Errorf("scenarios = %+v", scenarios)
+	}
+
+	invalid := strings.Replace(testScenario, `"gravitationalConstant": 10000`, `"gravitationalConstant": -1`, 1)
+	_, err = decodeScenarios([]byte(invalid))
+	if want := "presets[0].gravitationalConstant: must be positive, got -1"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("error %v doesn't contain %q", err, want)
+	}
+}
@@ -0,0 +1,230 @@
+package headless
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"PlanetSimulation/internal/physics"
+	"PlanetSimulation/internal/planetsimulation/defaults"
+	"PlanetSimulation/internal/schema"
+)
+
+// A scenario is a simulation preset as stored in simulation_presets.json (see
+// internal/schema). Colors, traces and metadata don't matter headless and are
+// ignored. A scenario file
+// holds either a single preset or a whole presets file; the final state of a
+// run is written as a scenario too, including its tick and time, so a run can
+// be continued.
+type scenario struct {
+	Name                  string         `json:"name"`
+	GravitationalConstant float64        `json:"gravitationalConstant,omitempty"`
+	Integrator            string         `json:"integrator,omitempty"`
+	TimeStep              float64        `json:"timeStep,omitempty"`
+	Tick                  int            `json:"tick,omitempty"`
+	Time                  float64        `json:"time,omitempty"`
+	Bodies                []physics.Body `json:"bodies"`
+}
+
+// the gravitational constant of the game for presets without one
+const defaultGravitationalConstant = 10000.0
+
+// preset returns the scenario as a preset of the game, with the physics only.
+func (scenario *scenario) preset() schema.SimulationPreset {
+	preset := schema.SimulationPreset{
+		Name:                  scenario.Name,
+		GravitationalConstant: scenario.GravitationalConstant,
+		Integrator:            scenario.Integrator,
+		TimeStep:              scenario.TimeStep,
+		Bodies:                make([]schema.Body, len(scenario.Bodies)),
+	}
+	for i, body := range scenario.Bodies {
+		preset.Bodies[i] = schema.Body{
+			Name:     body.Name,
+			X:        body.X,
+			Y:        body.Y,
+			Velocity: body.Velocity,
+			Mass:     body.Mass,
+			Radius:   body.Radius,
+		}
+	}
+
+	return preset
+}
+
+// scenarioFromPreset returns the physics of a preset of the game.
+func scenarioFromPreset(preset schema.SimulationPreset) scenario {
+	scenario := scenario{
+		Name:                  preset.Name,
+		GravitationalConstant: preset.GravitationalConstant,
+		Integrator:            preset.Integrator,
+		TimeStep:              preset.TimeStep,
+		Bodies:                make([]physics.Body, len(preset.Bodies)),
+	}
+	for i, body := range preset.Bodies {
+		scenario.Bodies[i] = physics.Body{
+			Name:     body.Name,
+			X:        body.X,
+			Y:        body.Y,
+			Velocity: body.Velocity,
+			Mass:     body.Mass,
+			Radius:   body.Radius,
+		}
+	}
+
+	return scenario
+}
+
+// validate checks the scenario like the game checks its presets, without the
+// values that don't matter headless.
+func (scenario *scenario) validate() error {
+	return scenario.preset().ValidatePhysics("")
+}
+
+// system returns the physics at the start of the scenario. Bodies without an
+// id are numbered like the game does.
+func (scenario *scenario) system() *physics.System {
+	system := &physics.System{
+		Bodies:                make([]*physics.Body, len(scenario.Bodies)),
+		GravitationalConstant: scenario.GravitationalConstant,
+		Tick:                  scenario.Tick,
+		Time:                  scenario.Time,
+	}
+	if system.GravitationalConstant == 0 {
+		system.GravitationalConstant = defaultGravitationalConstant
+	}
+	system.Integrator, _ = physics.ParseIntegrator(scenario.Integrator)
+
+	lastID := 0
+	for _, body := range scenario.Bodies {
+		lastID = max(lastID, body.ID)
+	}
+	for i, body := range scenario.Bodies {
+		if body.ID == 0 {
+			lastID++
+			body.ID = lastID
+		}
+		system.Bodies[i] = &body
+	}
+
+	return system
+}
+
+// decodeScenarios returns the presets of a presets file or the single preset
+// of a scenario file. Presets files of older versions are migrated like the
+// game does.
+func decodeScenarios(content []byte) ([]scenario, error) {
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(content, &header); err != nil {
+		return nil, err
+	}
+
+	// a scenario has bodies, presets files of any version don't
+	if _, ok := header["bodies"]; ok {
+		single := scenario{}
+		if err := json.Unmarshal(content, &single); err != nil {
+			return nil, err
+		}
+		return []scenario{single}, single.validate()
+	}
+
+	file, err := schema.ParseSimulationPresets(content)
+	if err != nil {
+		return nil, err
+	}
+
+	scenarios := make([]scenario, len(file.Presets))
+	errs := make([]error, 0)
+	for i, preset := range file.Presets {
+		scenarios[i] = scenarioFromPreset(preset)
+		errs = append(errs, preset.ValidatePhysics(fmt.Sprintf("presets[%d]", i)))
+	}
+
+	return scenarios, errors.Join(errs...)
+}
+
+// findScenario returns the preset called name, ignoring the case. Without a
+// name a file with a single preset is fine.
+func findScenario(scenarios []scenario, name string) (scenario, bool) {
+	if name == "" && len(scenarios) == 1 {
+		return scenarios[0], true
+	}
+
+	for _, preset := range scenarios {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+
+	return scenario{}, false
+}
+
+// userPresetsPath returns where the game keeps the presets of the user.
+func userPresetsPath() (string, error) {
+	directory, err := schema.UserDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(directory, schema.SimulationPresetsFileName), nil
+}
+
+// loadScenario returns the preset name of the file at path. Without a path
+// the presets of the user and then the built-in presets are searched.
+func loadScenario(path string, name string) (scenario, error) {
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return scenario{}, err
+		}
+		scenarios, err := decodeScenarios(content)
+		if err != nil {
+			return scenario{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if found, ok := findScenario(scenarios, name); ok {
+			return found, nil
+		}
+		if name == "" {
+			return scenario{}, fmt.Errorf("%s has %d presets, choose one with -preset", path, len(scenarios))
+		}
+		return scenario{}, fmt.Errorf("%s has no preset called %q", path, name)
+	}
+
+	if name == "" {
+		return scenario{}, errors.New("choose a preset with -preset or a scenario file with -file")
+	}
+
+	// a preset of the user wins over a built-in one with the same name
+	if userPath, err := userPresetsPath(); err == nil {
+		content, err := os.ReadFile(userPath)
+		if err == nil {
+			scenarios, err := decodeScenarios(content)
+			if err != nil {
+				return scenario{}, fmt.Errorf("%s: %w", userPath, err)
+			}
+			if found, ok := findScenario(scenarios, name); ok {
+				return found, nil
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return scenario{}, err
+		}
+	}
+
+	content, err := defaults.Presets.ReadFile(schema.SimulationPresetsFileName)
+	if err != nil {
+		return scenario{}, err
+	}
+	scenarios, err := decodeScenarios(content)
+	if err != nil {
+		return scenario{}, fmt.Errorf("built-in presets: %w", err)
+	}
+	if found, ok := findScenario(scenarios, name); ok {
+		return found, nil
+	}
+
+	return scenario{}, fmt.Errorf("there is no preset called %q", name)
+}
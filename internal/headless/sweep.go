@@ -0,0 +1,559 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"PlanetSimulation/internal/physics"
+	"PlanetSimulation/internal/schema"
+)
+
+// sweepFile describes a parameter sweep: which fields of a base preset are
+// varied, how they are sampled and how long every combination runs.
+type sweepFile struct {
+	// the base scenario, a preset of file or, without file, of your and the
+	// built-in presets. file is relative to the sweep file.
+	Preset     string  `json:"preset"`
+	File       string  `json:"file,omitempty"`
+	Ticks      int     `json:"ticks,omitempty"`
+	Seconds    float64 `json:"seconds,omitempty"`
+	TimeStep   float64 `json:"timeStep,omitempty"`
+	Integrator string  `json:"integrator,omitempty"`
+	// grid runs every combination, random runs samples combinations drawn
+	// with seed
+	Sampling string `json:"sampling,omitempty"`
+	Samples  int    `json:"samples,omitempty"`
+	Seed     uint64 `json:"seed,omitempty"`
+	// a body that escapes farther than this from the centre of mass is
+	// ejected, 10 times the size of the base scenario if zero
+	EjectionDistance float64          `json:"ejectionDistance,omitempty"`
+	Parameters       []sweepParameter `json:"parameters"`
+}
+
+// sweepParameter is a varied field, either of a body or of the scenario. The
+// values are values or the range from from to to, in steps values for a grid.
+type sweepParameter struct {
+	Body   string    `json:"body,omitempty"`
+	Field  string    `json:"field"`
+	From   float64   `json:"from"`
+	To     float64   `json:"to"`
+	Steps  int       `json:"steps,omitempty"`
+	Log    bool      `json:"log,omitempty"`
+	Values []float64 `json:"values,omitempty"`
+	// index of the body in the base scenario
+	bodyIndex int
+}
+
+const (
+	samplingGrid   = "grid"
+	samplingRandom = "random"
+	// more runs are most likely a mistake in the sweep file
+	maxSweepRuns = 1000000
+)
+
+// the fields of a body that can be varied; speed scales the velocity and
+// keeps its direction
+var sweepBodyFields = []string{"x", "y", "velocity.x", "velocity.y", "speed", "mass", "radius"}
+
+// the fields of the scenario that can be varied
+var sweepScenarioFields = []string{"gravitationalConstant"}
+
+// outcomes of a run
+const (
+	outcomeStable    = "stable"
+	outcomeCollision = "collision"
+	outcomeEjection  = "ejection"
+)
+
+type sweepResult struct {
+	values  []float64
+	outcome string
+	// simulated seconds until the collision or ejection
+	eventTime float64
+	// the bodies that collided or were ejected
+	bodies      []string
+	energyDrift float64
+	ticks       int
+	err         error
+}
+
+func (parameter *sweepParameter) label() string {
+	if parameter.Body == "" {
+		return parameter.Field
+	}
+
+	return parameter.Body + "." + parameter.Field
+}
+
+// mustBePositive reports whether the values of the field can't be zero or
+// negative.
+func (parameter *sweepParameter) mustBePositive() bool {
+	return parameter.Field == "mass" || parameter.Field == "radius" || parameter.Field == "gravitationalConstant"
+}
+
+func (parameter *sweepParameter) validate(base *scenario, sampling string) error {
+	errs := make([]error, 0)
+
+	switch {
+	case slices.Contains(sweepBodyFields, parameter.Field):
+		parameter.bodyIndex = slices.IndexFunc(base.Bodies, func(body physics.Body) bool {
+			return strings.EqualFold(body.Name, parameter.Body)
+		})
+		if parameter.bodyIndex < 0 {
+			errs = append(errs, fmt.Errorf("body: %s has no body called %q", base.Name, parameter.Body))
+		}
+	case slices.Contains(sweepScenarioFields, parameter.Field):
+		if parameter.Body != "" {
+			errs = append(errs, fmt.Errorf("body: %s isn't a field of a body", parameter.Field))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("field: unknown field %q, one of %s", parameter.Field, strings.Join(slices.Concat(sweepBodyFields, sweepScenarioFields), ", ")))
+	}
+
+	values := parameter.Values
+	if len(values) == 0 {
+		values = []float64{parameter.From, parameter.To}
+		if sampling == samplingGrid && parameter.Steps < 1 {
+			errs = append(errs, fmt.Errorf("steps: must be positive for a grid, got %d", parameter.Steps))
+		}
+	}
+	for _, value := range values {
+		if !schema.IsFinite(value) {
+			errs = append(errs, fmt.Errorf("values: must be finite numbers, got %v", value))
+		} else if value <= 0 && (parameter.mustBePositive() || parameter.Log) {
+			errs = append(errs, fmt.Errorf("values: must be positive, got %v", value))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// at returns the value at fraction t of the range.
+func (parameter *sweepParameter) at(t float64) float64 {
+	if parameter.Log {
+		return parameter.From * math.Pow(parameter.To/parameter.From, t)
+	}
+
+	return parameter.From + (parameter.To-parameter.From)*t
+}
+
+// gridValues returns the values of the parameter on a grid.
+func (parameter *sweepParameter) gridValues() []float64 {
+	if len(parameter.Values) > 0 {
+		return parameter.Values
+	}
+	if parameter.Steps == 1 {
+		return []float64{parameter.From}
+	}
+
+	values := make([]float64, parameter.Steps)
+	for i := range values {
+		values[i] = parameter.at(float64(i) / float64(parameter.Steps-1))
+	}
+
+	return values
+}
+
+func (parameter *sweepParameter) sample(random *rand.Rand) float64 {
+	if len(parameter.Values) > 0 {
+		return parameter.Values[random.IntN(len(parameter.Values))]
+	}
+
+	return parameter.at(random.Float64())
+}
+
+// apply sets the field of the parameter in scenario to value.
+func (parameter *sweepParameter) apply(scenario *scenario, value float64) {
+	if parameter.Field == "gravitationalConstant" {
+		scenario.GravitationalConstant = value
+		return
+	}
+
+	body := &scenario.Bodies[parameter.bodyIndex]
+	switch parameter.Field {
+	case "x":
+		body.X = value
+	case "y":
+		body.Y = value
+	case "velocity.x":
+		body.Velocity.X = value
+	case "velocity.y":
+		body.Velocity.Y = value
+	case "speed":
+		if speed := body.Velocity.Length(); speed > 0 {
+			body.Velocity = body.Velocity.Scale(value / speed)
+		} else {
+			// without a direction the body moves along x
+			body.Velocity = physics.Vector{X: value}
+		}
+	case "mass":
+		body.Mass = value
+	case "radius":
+		body.Radius = value
+	}
+}
+
+// decodeSweep reads a sweep file and loads its base scenario.
+func decodeSweep(path string) (*sweepFile, scenario, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, scenario{}, err
+	}
+	// a misspelt key would silently sweep with a default
+	sweep := &sweepFile{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(sweep); err != nil {
+		return nil, scenario{}, fmt.Errorf("%s: %w", path, schema.JSONError(content, err))
+	}
+
+	file := sweep.File
+	if file != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(path), file)
+	}
+	base, err := loadScenario(file, sweep.Preset)
+	if err != nil {
+		return nil, scenario{}, err
+	}
+	if sweep.Integrator != "" {
+		base.Integrator = sweep.Integrator
+	}
+
+	errs := make([]error, 0)
+	if err := base.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if sweep.Sampling == "" {
+		sweep.Sampling = samplingGrid
+	}
+	if sweep.Sampling != samplingGrid && sweep.Sampling != samplingRandom {
+		errs = append(errs, fmt.Errorf("sampling: must be %s or %s, got %q", samplingGrid, samplingRandom, sweep.Sampling))
+	}
+	if sweep.Sampling == samplingRandom && sweep.Samples < 1 {
+		errs = append(errs, fmt.Errorf("samples: must be positive for random sampling, got %d", sweep.Samples))
+	}
+	if !schema.IsFinite(sweep.EjectionDistance) || sweep.EjectionDistance < 0 {
+		errs = append(errs, fmt.Errorf("ejectionDistance: must be zero or positive, got %v", sweep.EjectionDistance))
+	}
+	if len(sweep.Parameters) == 0 {
+		errs = append(errs, errors.New("parameters: nothing to vary"))
+	}
+	for i := range sweep.Parameters {
+		if err := sweep.Parameters[i].validate(&base, sweep.Sampling); err != nil {
+			errs = append(errs, fmt.Errorf("parameters[%d]: %w", i, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, scenario{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// every run is measured against the same distance, even when the
+	// parameters move the bodies
+	if sweep.EjectionDistance == 0 {
+		system := base.system()
+		center := system.CenterOfMass()
+		for _, body := range system.Bodies {
+			sweep.EjectionDistance = max(sweep.EjectionDistance, 10*body.Position().Sub(center).Length())
+		}
+	}
+
+	return sweep, base, nil
+}
+
+// combinations returns the values of the parameters of every run. On a grid
+// the last parameter changes fastest.
+func (sweep *sweepFile) combinations() ([][]float64, error) {
+	if sweep.Sampling == samplingRandom {
+		if sweep.Samples > maxSweepRuns {
+			return nil, fmt.Errorf("%d samples are more than %d runs", sweep.Samples, maxSweepRuns)
+		}
+
+		random := rand.New(rand.NewPCG(sweep.Seed, sweep.Seed))
+		combinations := make([][]float64, sweep.Samples)
+		for i := range combinations {
+			combinations[i] = make([]float64, len(sweep.Parameters))
+			for j := range sweep.Parameters {
+				combinations[i][j] = sweep.Parameters[j].sample(random)
+			}
+		}
+		return combinations, nil
+	}
+
+	combinations := [][]float64{{}}
+	for i := range sweep.Parameters {
+		values := sweep.Parameters[i].gridValues()
+		if len(combinations)*len(values) > maxSweepRuns {
+			return nil, fmt.Errorf("the grid has more than %d runs", maxSweepRuns)
+		}
+
+		next := make([][]float64, 0, len(combinations)*len(values))
+		for _, combination := range combinations {
+			for _, value := range values {
+				next = append(next, append(slices.Clip(combination), value))
+			}
+		}
+		combinations = next
+	}
+
+	return combinations, nil
+}
+
+// simulate runs base with values until a collision or an ejection happens or
+// ticks have passed.
+func (sweep *sweepFile) simulate(base scenario, values []float64, timeStep float64, ticks int) sweepResult {
+	result := sweepResult{values: values, outcome: outcomeStable}
+
+	scenario := base
+	scenario.Bodies = slices.Clone(base.Bodies)
+	for i := range sweep.Parameters {
+		sweep.Parameters[i].apply(&scenario, values[i])
+	}
+	if err := scenario.validate(); err != nil {
+		result.err = err
+		return result
+	}
+
+	system := scenario.system()
+	kinetic, potential := system.Energy()
+	initialEnergy := kinetic + potential
+
+	// the merge itself loses energy, so the drift of a collision is measured
+	// right before it
+	previous := &physics.System{GravitationalConstant: system.GravitationalConstant}
+	previousBodies := make([]physics.Body, 0, len(system.Bodies))
+
+	for tick := 1; tick <= ticks; tick++ {
+		previousBodies = previousBodies[:0]
+		for _, body := range system.Bodies {
+			previousBodies = append(previousBodies, *body)
+		}
+
+		merges := system.Step(timeStep)
+		result.ticks = tick
+		if len(merges) > 0 {
+			result.outcome = outcomeCollision
+			for _, merge := range merges {
+				result.bodies = append(result.bodies, merge.Survivor.Name+"+"+merge.Absorbed.Name)
+			}
+			previous.Bodies = previous.Bodies[:0]
+			for i := range previousBodies {
+				previous.Bodies = append(previous.Bodies, &previousBodies[i])
+			}
+			kinetic, potential = previous.Energy()
+			break
+		}
+
+		if unbound := system.Unbound(sweep.EjectionDistance); len(unbound) > 0 {
+			result.outcome = outcomeEjection
+			for _, body := range unbound {
+				result.bodies = append(result.bodies, body.Name)
+			}
+			kinetic, potential = system.Energy()
+			break
+		}
+
+		if tick == ticks {
+			kinetic, potential = system.Energy()
+		}
+	}
+
+	result.eventTime = system.Time - scenario.Time
+	result.energyDrift = physics.RelativeDrift(initialEnergy, kinetic+potential)
+
+	return result
+}
+
+// runAll runs all combinations on workers goroutines. The results are in the
+// order of the combinations.
+func (sweep *sweepFile) runAll(base scenario, combinations [][]float64, timeStep float64, ticks int, workers int) []sweepResult {
+	results := make([]sweepResult, len(combinations))
+	indices := make(chan int)
+
+	wg := sync.WaitGroup{}
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for i := range indices {
+				results[i] = sweep.simulate(base, combinations[i], timeStep, ticks)
+			}
+		})
+	}
+	for i := range combinations {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return results
+}
+
+type sweepOptions struct {
+	file         string
+	outDirectory string
+	workers      int
+}
+
+func newSweepFlags(options *sweepOptions, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: planetsimulation sweep -file sweep.json [flags]")
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "Runs every combination of the parameters of a sweep file without graphics")
+		fmt.Fprintln(output, "and prints how each one ended: stable, collision or ejection. The table is")
+		fmt.Fprintln(output, "written to summary.csv as well. Exits with 2 if the sweep can't be run.")
+		fmt.Fprintln(output)
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&options.file, "file", "", "sweep `file` with the base preset and the parameters to vary")
+	flags.StringVar(&options.outDirectory, "out", "sweep", "`directory` for summary.csv")
+	flags.IntVar(&options.workers, "workers", runtime.NumCPU(), "number of runs at the same time")
+
+	return flags
+}
+
+// Sweep runs a parameter sweep headless, args are the arguments after
+// "sweep". It returns the exit code of the process.
+func Sweep(args []string, stdout io.Writer, stderr io.Writer) int {
+	options := sweepOptions{}
+	flags := newSweepFlags(&options, stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitPassed
+		}
+		return exitError
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments %q\n", flags.Args())
+		flags.Usage()
+		return exitError
+	}
+	if options.file == "" {
+		fmt.Fprintln(stderr, "Error: choose a sweep file with -file")
+		return exitError
+	}
+
+	if err := runSweep(options, stdout); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return exitError
+	}
+
+	return exitPassed
+}
+
+// runSweep loads the sweep file, runs it and writes the summary.
+func runSweep(options sweepOptions, stdout io.Writer) error {
+	sweep, base, err := decodeSweep(options.file)
+	if err != nil {
+		return err
+	}
+	timeStep, ticks, err := base.duration(sweep.TimeStep, sweep.Ticks, sweep.Seconds)
+	if err != nil {
+		return fmt.Errorf("%s: %w", options.file, err)
+	}
+	combinations, err := sweep.combinations()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(options.outDirectory, 0o755); err != nil {
+		return err
+	}
+
+	results := sweep.runAll(base, combinations, timeStep, ticks, options.workers)
+	for i, result := range results {
+		if result.err != nil {
+			return fmt.Errorf("run %d: %w", i+1, result.err)
+		}
+	}
+
+	if err := sweep.writeSummary(filepath.Join(options.outDirectory, "summary.csv"), results); err != nil {
+		return err
+	}
+	if err := sweep.printSummary(stdout, results); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote the summary to %s\n", filepath.Join(options.outDirectory, "summary.csv"))
+
+	return nil
+}
+
+// printSummary prints a table of the runs and how many ended how.
+func (sweep *sweepFile) printSummary(output io.Writer, results []sweepResult) error {
+	table := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	header := []string{"run"}
+	for i := range sweep.Parameters {
+		header = append(header, sweep.Parameters[i].label())
+	}
+	header = append(header, "outcome", "time", "bodies", "energy drift")
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+
+	counts := map[string]int{}
+	for i, result := range results {
+		row := []string{strconv.Itoa(i + 1)}
+		for _, value := range result.values {
+			row = append(row, strconv.FormatFloat(value, 'g', 6, 64))
+		}
+		eventTime := ""
+		if result.outcome != outcomeStable {
+			eventTime = strconv.FormatFloat(result.eventTime, 'g', 6, 64)
+		}
+		row = append(row, result.outcome, eventTime, strings.Join(result.bodies, " "), strconv.FormatFloat(result.energyDrift, 'g', 3, 64))
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+		counts[result.outcome]++
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(output, "%d runs: %d stable, %d collisions, %d ejections\n",
+		len(results), counts[outcomeStable], counts[outcomeCollision], counts[outcomeEjection])
+	return err
+}
+
+// writeSummary writes the table of the runs as CSV.
+func (sweep *sweepFile) writeSummary(path string, results []sweepResult) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+
+	header := []string{"run"}
+	for i := range sweep.Parameters {
+		header = append(header, sweep.Parameters[i].label())
+	}
+	writer.Write(append(header, "outcome", "event_time", "bodies", "energy_drift", "ticks"))
+
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	for i, result := range results {
+		row := []string{strconv.Itoa(i + 1)}
+		for _, value := range result.values {
+			row = append(row, formatFloat(value))
+		}
+		eventTime := ""
+		if result.outcome != outcomeStable {
+			eventTime = formatFloat(result.eventTime)
+		}
+		writer.Write(append(row, result.outcome, eventTime, strings.Join(result.bodies, " "), formatFloat(result.energyDrift), strconv.Itoa(result.ticks)))
+	}
+
+	writer.Flush()
+	return errors.Join(writer.Error(), file.Close())
+}
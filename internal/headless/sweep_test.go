@@ -0,0 +1,165 @@
+package headless
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeTestSweep(t *testing.T, sweep string) string {
+	t.Helper()
+	scenarioPath := writeTestScenario(t)
+	path := filepath.Join(filepath.Dir(scenarioPath), "sweep.json")
+	if err := os.WriteFile(path, []byte(sweep), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSweepOutcomes(t *testing.T) {
+	// at rest the planet falls into the star, at twice the orbital speed it
+	// escapes
+	path := writeTestSweep(t, `{
+		"preset": "Orbit",
+		"file": "scenario.json",
+		"seconds": 10,
+		"ejectionDistance": 1000,
+		"parameters": [{"body": "planet", "field": "speed", "values": [0, 223.60679774997897, 447.21359549995793]}]
+	}`)
+	out := t.TempDir()
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	if code := Sweep([]string{"-file", path, "-out", out, "-workers", "2"}, stdout, stderr); code != exitPassed {
+		t.Fatalf("exit code %d, stdout %s, stderr %s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout.String(), "3 runs: 1 stable, 1 collisions, 1 ejections") {
+		t.Errorf("stdout = %s", stdout)
+	}
+
+	summary, err := os.Open(filepath.Join(out, "summary.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer summary.Close()
+	rows, err := csv.NewReader(summary).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != "run,planet.speed,outcome,event_time,bodies,energy_drift,ticks" {
+		t.Fatalf("rows = %v", rows)
+	}
+	want := [][]string{
+		{"collision", "Star+Planet"},
+		{"stable", ""},
+		{"ejection", "Planet"},
+	}
+	for i, row := range rows[1:] {
+		if row[2] != want[i][0] || row[4] != want[i][1] {
+			t.Errorf("run %d ended with %s of %q, want %s of %q", i+1, row[2], row[4], want[i][0], want[i][1])
+		}
+		if (row[2] == outcomeStable) != (row[3] == "") {
+			t.Errorf("run %d ended with %s at %q", i+1, row[2], row[3])
+		}
+	}
+}
+
+func TestSweepCombinations(t *testing.T) {
+	grid := &sweepFile{
+		Sampling: samplingGrid,
+		Parameters: []sweepParameter{
+			{Field: "mass", From: 1, To: 100, Steps: 3, Log: true},
+			{Field: "velocity.y", Values: []float64{-1, 1}},
+		},
+	}
+	combinations, err := grid.combinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{{1, -1}, {1, 1}, {10, -1}, {10, 1}, {100, -1}, {100, 1}}
+	if !slices.EqualFunc(combinations, want, slices.Equal) {
+		t.Errorf("grid = %v, want %v", combinations, want)
+	}
+
+	random := &sweepFile{
+		Sampling: samplingRandom,
+		Samples:  50,
+		Seed:     7,
+		Parameters: []sweepParameter{
+			{Field: "x", From: -10, To: 10},
+		},
+	}
+	first, err := random.combinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := random.combinations()
+	if len(first) != 50 || !slices.EqualFunc(first, second, slices.Equal) {
+		t.Errorf("random samples aren't reproducible: %v and %v", first, second)
+	}
+	for _, combination := range first {
+		if combination[0] < -10 || combination[0] > 10 {
+			t.Errorf("sample %v is out of range", combination)
+		}
+	}
+}
+
+func TestSweepEjectionDistanceOfBase(t *testing.T) {
+	// the planet is 200 from the star, moving it away doesn't move the
+	// distance it is ejected at
+	path := writeTestSweep(t, `{
+		"preset": "Orbit",
+		"file": "scenario.json",
+		"ticks": 1,
+		"parameters": [{"body": "planet", "field": "x", "values": [200, 5000]}]
+	}`)
+	sweep, base, err := decodeSweep(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(sweep.EjectionDistance-2000) > 1e-3 {
+		t.Errorf("ejection distance = %v, want 2000", sweep.EjectionDistance)
+	}
+
+	timeStep, ticks, err := base.duration(sweep.TimeStep, sweep.Ticks, sweep.Seconds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := sweep.runAll(base, [][]float64{{200}, {5000}}, timeStep, ticks, 1)
+	if results[0].outcome != outcomeStable || results[1].outcome != outcomeEjection {
+		t.Errorf("outcomes = %s and %s, want stable and ejection", results[0].outcome, results[1].outcome)
+	}
+}
+
+func TestInvalidSweep(t *testing.T) {
+	tests := map[string]string{
+		"unknown body":     `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "parameters": [{"body": "Comet", "field": "mass", "from": 1, "to": 2, "steps": 2}]}`,
+		"unknown field":    `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "parameters": [{"body": "Star", "field": "colour", "from": 1, "to": 2, "steps": 2}]}`,
+		"negative mass":    `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "parameters": [{"body": "Star", "field": "mass", "from": -1, "to": 2, "steps": 2}]}`,
+		"no steps":         `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "parameters": [{"body": "Star", "field": "x", "from": 1, "to": 2}]}`,
+		"no samples":       `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "sampling": "random", "parameters": [{"body": "Star", "field": "x", "from": 1, "to": 2}]}`,
+		"nothing to vary":  `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "parameters": []}`,
+		"no duration":      `{"preset": "Orbit", "file": "scenario.json", "parameters": [{"field": "gravitationalConstant", "values": [1]}]}`,
+		"unknown preset":   `{"preset": "Nothing", "file": "scenario.json", "ticks": 10, "parameters": [{"field": "gravitationalConstant", "values": [1]}]}`,
+		"unknown sampling": `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "sampling": "sobol", "parameters": [{"field": "gravitationalConstant", "values": [1]}]}`,
+		"misspelt key":     `{"preset": "Orbit", "file": "scenario.json", "ticks": 10, "ejectionDistanse": 5, "parameters": [{"field": "gravitationalConstant", "values": [1]}]}`,
+	}
+
+	for name, sweep := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := writeTestSweep(t, sweep)
+			stderr := &bytes.Buffer{}
+			if code := Sweep([]string{"-file", path, "-out", t.TempDir()}, &bytes.Buffer{}, stderr); code != exitError {
+				t.Errorf("exit code %d, want %d", code, exitError)
+			}
+			if stderr.Len() == 0 {
+				t.Error("nothing was reported")
+			}
+		})
+	}
+}
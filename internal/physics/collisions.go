@@ -0,0 +1,246 @@
+package physics
+
+import (
+	"math"
+	"slices"
+)
+
+// SpatialHash is a uniform grid broad phase. Every inserted bounding box is
+// registered in all cells it touches and only entries that share a cell are
+// reported as candidate pairs, so the cost scales with the number of nearby
+// pairs instead of n².
+type SpatialHash struct {
+	CellSize float64
+	cells    map[[2]int][]int
+}
+
+func NewSpatialHash(cellSize float64) *SpatialHash {
+	return &SpatialHash{
+		CellSize: cellSize,
+		cells:    make(map[[2]int][]int),
+	}
+}
+
+func (hash *SpatialHash) cell(x float64, y float64) (int, int) {
+	return int(math.Floor(x / hash.CellSize)), int(math.Floor(y / hash.CellSize))
+}
+
+func (hash *SpatialHash) Insert(index int, minX float64, minY float64, maxX float64, maxY float64) {
+	minCellX, minCellY := hash.cell(minX, minY)
+	maxCellX, maxCellY := hash.cell(maxX, maxY)
+
+	for cellX := minCellX; cellX <= maxCellX; cellX++ {
+		for cellY := minCellY; cellY <= maxCellY; cellY++ {
+			key := [2]int{cellX, cellY}
+			hash.cells[key] = append(hash.cells[key], index)
+		}
+	}
+}
+
+// CandidatePairs returns every pair of indices sharing at least one cell once,
+// with the smaller index first and sorted so the result is deterministic.
+func (hash *SpatialHash) CandidatePairs() [][2]int {
+	seen := make(map[[2]int]bool)
+	pairs := make([][2]int, 0)
+
+	for _, indices := range hash.cells {
+		for i := 0; i < len(indices); i++ {
+			for j := i + 1; j < len(indices); j++ {
+				pair := [2]int{min(indices[i], indices[j]), max(indices[i], indices[j])}
+				if pair[0] == pair[1] || seen[pair] {
+					continue
+				}
+
+				seen[pair] = true
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+
+	slices.SortFunc(pairs, comparePairs)
+
+	return pairs
+}
+
+func comparePairs(a [2]int, b [2]int) int {
+	if a[0] != b[0] {
+		return a[0] - b[0]
+	}
+	return a[1] - b[1]
+}
+
+// maxCellsPerBody limits the cells a body is registered in. Larger bodies are
+// paired with every other body instead.
+const maxCellsPerBody = 64
+
+// broadPhase returns the indices of all bodies whose swept bounding boxes
+// are close enough to possibly touch during the last step. It does not depend
+// on how gravity is solved.
+func broadPhase(bodies []*Body, startPositions []Vector) [][2]int {
+	boxes := make([][4]float64, len(bodies))
+	sizes := make([]float64, len(bodies))
+	for i, body := range bodies {
+		start := startPositions[i]
+		boxes[i] = [4]float64{
+			min(start.X, body.X) - body.Radius,
+			min(start.Y, body.Y) - body.Radius,
+			max(start.X, body.X) + body.Radius,
+			max(start.Y, body.Y) + body.Radius,
+		}
+		sizes[i] = max(boxes[i][2]-boxes[i][0], boxes[i][3]-boxes[i][1])
+	}
+
+	// cells as large as a typical box keep the number of cells per body low,
+	// a single large body is registered in several cells instead of making
+	// every cell large
+	cellSize := 1.0
+	if len(sizes) > 0 {
+		slices.Sort(sizes)
+		if median := sizes[len(sizes)/2]; median > 0 {
+			cellSize = median
+		}
+	}
+
+	hash := NewSpatialHash(cellSize)
+	large := []int{}
+	for i, box := range boxes {
+		cellsX := math.Floor(box[2]/cellSize) - math.Floor(box[0]/cellSize) + 1
+		cellsY := math.Floor(box[3]/cellSize) - math.Floor(box[1]/cellSize) + 1
+		if cellsX*cellsY > maxCellsPerBody {
+			large = append(large, i)
+			continue
+		}
+		hash.Insert(i, box[0], box[1], box[2], box[3])
+	}
+
+	pairs := hash.CandidatePairs()
+	if len(large) == 0 {
+		return pairs
+	}
+
+	for _, i := range large {
+		for j := range bodies {
+			// pairs of two large bodies are only added once
+			if j == i || j < i && slices.Contains(large, j) {
+				continue
+			}
+			pairs = append(pairs, [2]int{min(i, j), max(i, j)})
+		}
+	}
+	slices.SortFunc(pairs, comparePairs)
+
+	return pairs
+}
+
+// timeOfImpact returns the fraction of the step in [0, 1] at which two circles
+// moving linearly from their start to their end positions first touch.
+func timeOfImpact(start1 Vector, end1 Vector, radius1 float64, start2 Vector, end2 Vector, radius2 float64) (float64, bool) {
+	// relative position and relative displacement
+	dx, dy := start1.X-start2.X, start1.Y-start2.Y
+	ddx := (end1.X - start1.X) - (end2.X - start2.X)
+	ddy := (end1.Y - start1.Y) - (end2.Y - start2.Y)
+	radius := radius1 + radius2
+
+	// solve |d + t * dd|² = radius²
+	a := ddx*ddx + ddy*ddy
+	b := 2 * (dx*ddx + dy*ddy)
+	c := dx*dx + dy*dy - radius*radius
+
+	// already overlapping at the start of the step
+	if c <= 0 {
+		return 0, true
+	}
+
+	discriminant := b*b - 4*a*c
+	if a == 0 || discriminant < 0 {
+		return 0, false
+	}
+
+	t := (-b - math.Sqrt(discriminant)) / (2 * a)
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+
+	return t, true
+}
+
+type collision struct {
+	indices [2]int
+	time    float64
+}
+
+// merge lets p absorb other. These are the rules of the game rather than
+// conservation of mass and momentum.
+func merge(p *Body, other *Body) {
+	p.Mass += other.Mass / 2
+	if p.Radius <= 1000 {
+		p.Radius += other.Radius / 4
+	}
+
+	p.Velocity = p.Velocity.Add(other.Velocity.Scale(1 / p.Mass))
+}
+
+// handleCollisions merges all bodies that touched during the last step of
+// length dt. Collisions are resolved in the order they happened, at the
+// position the bodies had at that moment, so fast bodies can't tunnel
+// through others.
+func (system *System) handleCollisions(startPositions []Vector, dt float64) []Merge {
+	bodies := system.Bodies
+	collisions := make([]collision, 0)
+
+	for _, pair := range broadPhase(bodies, startPositions) {
+		p, other := bodies[pair[0]], bodies[pair[1]]
+
+		// narrow phase
+		t, collides := timeOfImpact(
+			startPositions[pair[0]], p.Position(), p.Radius,
+			startPositions[pair[1]], other.Position(), other.Radius,
+		)
+		if collides {
+			collisions = append(collisions, collision{pair, t})
+		}
+	}
+
+	slices.SortStableFunc(collisions, func(a collision, b collision) int {
+		if a.time < b.time {
+			return -1
+		}
+		if a.time > b.time {
+			return 1
+		}
+		return 0
+	})
+
+	// bodies whose path changed because of a merge, later impacts on the old path didn't happen
+	merged := make([]int, 0)
+	merges := make([]Merge, 0)
+
+	for _, collision := range collisions {
+		i, j := collision.indices[0], collision.indices[1]
+		if slices.Contains(merged, i) || slices.Contains(merged, j) {
+			continue
+		}
+
+		// move both bodies back to the moment of impact
+		for _, index := range collision.indices {
+			body, start := bodies[index], startPositions[index]
+			body.X = start.X + (body.X-start.X)*collision.time
+			body.Y = start.Y + (body.Y-start.Y)*collision.time
+		}
+
+		p, other := bodies[i], bodies[j]
+		if p.Mass < other.Mass {
+			p, other = other, p
+		}
+		merge(p, other)
+		merges = append(merges, Merge{p, other})
+
+		// the merged body travels the rest of the step with its new velocity
+		remainingTime := (1 - collision.time) * dt
+		p.translate(p.Velocity.Scale(remainingTime))
+
+		merged = append(merged, i, j)
+	}
+
+	return merges
+}
@@ -0,0 +1,139 @@
+package physics
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestTimeOfImpact(t *testing.T) {
+	tests := []struct {
+		name     string
+		start1   Vector
+		end1     Vector
+		start2   Vector
+		end2     Vector
+		collides bool
+		time     float64
+	}{
+		{"passes through", Vector{-100, 0}, Vector{100, 0}, Vector{0, 0}, Vector{0, 0}, true, 0.45},
+		{"misses", Vector{-100, 30}, Vector{100, 30}, Vector{0, 0}, Vector{0, 0}, false, 0},
+		{"stops short", Vector{-100, 0}, Vector{-50, 0}, Vector{0, 0}, Vector{0, 0}, false, 0},
+		{"already overlapping", Vector{5, 0}, Vector{100, 0}, Vector{0, 0}, Vector{0, 0}, true, 0},
+		{"head on", Vector{-100, 0}, Vector{0, 0}, Vector{100, 0}, Vector{0, 0}, true, 0.95},
+		{"moving apart", Vector{-20, 0}, Vector{-100, 0}, Vector{0, 0}, Vector{0, 0}, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			time, collides := timeOfImpact(test.start1, test.end1, 5, test.start2, test.end2, 5)
+			if collides != test.collides {
+				t.Fatalf("collides = %v, want %v", collides, test.collides)
+			}
+			if math.Abs(time-test.time) > 1e-9 {
+				t.Errorf("time = %v, want %v", time, test.time)
+			}
+		})
+	}
+}
+
+func TestStepRemovesAbsorbedBodies(t *testing.T) {
+	target := &Body{ID: 1, Name: "target", Mass: 1000, Radius: 20}
+	impactor := &Body{ID: 2, Name: "impactor", X: -200, Mass: 1, Radius: 1, Velocity: Vector{100000, 0}}
+	system := &System{Bodies: []*Body{target, impactor}}
+
+	merges := system.Step(0.01)
+
+	if len(merges) != 1 || merges[0].Survivor != target || merges[0].Absorbed != impactor {
+		t.Fatalf("merges = %+v, want the target absorbing the impactor", merges)
+	}
+	if len(system.Bodies) != 1 || system.Bodies[0] != target {
+		t.Fatalf("bodies = %+v, want only the target", system.Bodies)
+	}
+}
+
+// randomBodies returns n small bodies that moved during the last step and
+// one large body among them.
+func randomBodies(n int, seed uint64) ([]*Body, []Vector) {
+	random := rand.New(rand.NewPCG(seed, 0))
+	bodies := make([]*Body, n)
+	startPositions := make([]Vector, n)
+	for i := range bodies {
+		bodies[i] = &Body{
+			X:      random.Float64() * 1000,
+			Y:      random.Float64() * 1000,
+			Radius: 0.5 + random.Float64()*3,
+		}
+		startPositions[i] = Vector{bodies[i].X + random.NormFloat64()*5, bodies[i].Y + random.NormFloat64()*5}
+	}
+	bodies[n/2].Radius = 150
+
+	return bodies, startPositions
+}
+
+// bruteForcePairs returns the pairs whose swept bounding boxes overlap.
+func bruteForcePairs(bodies []*Body, startPositions []Vector) [][2]int {
+	box := func(i int) (float64, float64, float64, float64) {
+		start, body := startPositions[i], bodies[i]
+		return min(start.X, body.X) - body.Radius, min(start.Y, body.Y) - body.Radius,
+			max(start.X, body.X) + body.Radius, max(start.Y, body.Y) + body.Radius
+	}
+
+	pairs := [][2]int{}
+	for i := range bodies {
+		for j := i + 1; j < len(bodies); j++ {
+			minX1, minY1, maxX1, maxY1 := box(i)
+			minX2, minY2, maxX2, maxY2 := box(j)
+			if minX1 <= maxX2 && minX2 <= maxX1 && minY1 <= maxY2 && minY2 <= maxY1 {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+
+	return pairs
+}
+
+func TestBroadPhaseFindsAllOverlaps(t *testing.T) {
+	for seed := range uint64(5) {
+		bodies, startPositions := randomBodies(500, seed)
+		candidates := broadPhase(bodies, startPositions)
+
+		if !slices.IsSortedFunc(candidates, comparePairs) || len(slices.Compact(slices.Clone(candidates))) != len(candidates) {
+			t.Fatalf("seed %d: the candidates aren't sorted or unique", seed)
+		}
+
+		// the candidates that really overlap are exactly the brute force pairs
+		want := bruteForcePairs(bodies, startPositions)
+		overlapping := [][2]int{}
+		for _, pair := range candidates {
+			if slices.Contains(want, pair) {
+				overlapping = append(overlapping, pair)
+			}
+		}
+		if !slices.Equal(overlapping, want) {
+			t.Errorf("seed %d: %d of %d overlapping pairs are candidates", seed, len(overlapping), len(want))
+		}
+
+		// the large body is paired with every other body, but it doesn't
+		// make the small bodies candidates of each other
+		if n := len(bodies); len(candidates) > 2*n {
+			t.Errorf("seed %d: %d candidates for %d bodies", seed, len(candidates), n)
+		}
+	}
+}
+
+func TestSpatialHashCandidatePairs(t *testing.T) {
+	hash := NewSpatialHash(10)
+	hash.Insert(0, 0, 0, 5, 5)
+	hash.Insert(1, 4, 4, 8, 8)
+	// spans four cells and shares them with 0 and 3
+	hash.Insert(2, 5, 5, 25, 25)
+	hash.Insert(3, 21, 21, 22, 22)
+	hash.Insert(4, 100, 100, 101, 101)
+
+	want := [][2]int{{0, 1}, {0, 2}, {1, 2}, {2, 3}}
+	if pairs := hash.CandidatePairs(); !slices.Equal(pairs, want) {
+		t.Errorf("pairs = %v, want %v", pairs, want)
+	}
+}
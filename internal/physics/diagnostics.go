@@ -0,0 +1,97 @@
+package physics
+
+import "math"
+
+// Energy returns the kinetic and potential energy of the system.
+func (system *System) Energy() (float64, float64) {
+	kinetic, potential := 0.0, 0.0
+	for i, body := range system.Bodies {
+		speed := body.Velocity.Length()
+		kinetic += body.Mass * speed * speed / 2
+
+		for _, other := range system.Bodies[i+1:] {
+			potential -= system.GravitationalConstant * body.Mass * other.Mass / other.Position().Sub(body.Position()).Length()
+		}
+	}
+
+	return kinetic, potential
+}
+
+// Momentum returns the total linear momentum.
+func (system *System) Momentum() Vector {
+	momentum := Vector{}
+	for _, body := range system.Bodies {
+		momentum = momentum.Add(body.Velocity.Scale(body.Mass))
+	}
+
+	return momentum
+}
+
+// AngularMomentum returns the total angular momentum around the origin.
+func (system *System) AngularMomentum() float64 {
+	angularMomentum := 0.0
+	for _, body := range system.Bodies {
+		angularMomentum += body.Position().Cross(body.Velocity.Scale(body.Mass))
+	}
+
+	return angularMomentum
+}
+
+func (system *System) CenterOfMass() Vector {
+	center, mass := Vector{}, 0.0
+	for _, body := range system.Bodies {
+		center = center.Add(body.Position().Scale(body.Mass))
+		mass += body.Mass
+	}
+
+	if mass == 0 {
+		return Vector{}
+	}
+
+	return center.Scale(1 / mass)
+}
+
+// RelativeDrift returns how much value changed relative to initial, or the
+// absolute change if initial is zero.
+func RelativeDrift(initial float64, value float64) float64 {
+	if initial == 0 {
+		return math.Abs(value)
+	}
+
+	return math.Abs((value - initial) / initial)
+}
+
+// Unbound returns the bodies farther than distance from the centre of mass
+// that move fast enough to escape the other bodies. The other bodies are
+// treated as a single body at their centre of mass.
+func (system *System) Unbound(distance float64) []*Body {
+	mass, weightedPosition, momentum := 0.0, Vector{}, Vector{}
+	for _, body := range system.Bodies {
+		mass += body.Mass
+		weightedPosition = weightedPosition.Add(body.Position().Scale(body.Mass))
+		momentum = momentum.Add(body.Velocity.Scale(body.Mass))
+	}
+
+	unbound := make([]*Body, 0)
+	if mass == 0 {
+		return unbound
+	}
+	center := weightedPosition.Scale(1 / mass)
+	for _, body := range system.Bodies {
+		otherMass := mass - body.Mass
+		if otherMass <= 0 || body.Position().Sub(center).Length() <= distance {
+			continue
+		}
+		otherCenter := weightedPosition.Sub(body.Position().Scale(body.Mass)).Scale(1 / otherMass)
+		separation := body.Position().Sub(otherCenter).Length()
+
+		otherVelocity := momentum.Sub(body.Velocity.Scale(body.Mass)).Scale(1 / otherMass)
+		speed := body.Velocity.Sub(otherVelocity).Length()
+		// the specific orbital energy of the two bodies
+		if speed*speed/2-system.GravitationalConstant*mass/separation > 0 {
+			unbound = append(unbound, body)
+		}
+	}
+
+	return unbound
+}
@@ -0,0 +1,30 @@
+package physics
+
+type Integrator int
+
+const (
+	// every body is accelerated and moved one after another, so later
+	// bodies already see the new positions of earlier ones
+	Euler Integrator = iota
+	// kick-drift-kick leapfrog, symplectic and time reversible
+	Leapfrog
+)
+
+var IntegratorNames = []string{
+	"euler",
+	"leapfrog",
+}
+
+func (integrator Integrator) String() string {
+	return IntegratorNames[integrator]
+}
+
+func ParseIntegrator(name string) (Integrator, bool) {
+	for i, integratorName := range IntegratorNames {
+		if integratorName == name {
+			return Integrator(i), true
+		}
+	}
+
+	return Euler, false
+}
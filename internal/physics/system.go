@@ -0,0 +1,117 @@
+// Package physics is the simulation core without any graphics, so it can be
+// run headless and tested without a display.
+package physics
+
+import "slices"
+
+// Body is a planet as far as the physics are concerned.
+type Body struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Velocity Vector  `json:"velocity"`
+	Mass     float64 `json:"mass"`
+	Radius   float64 `json:"radius"`
+}
+
+func (body *Body) Position() Vector {
+	return Vector{body.X, body.Y}
+}
+
+func (body *Body) translate(d Vector) {
+	body.X += d.X
+	body.Y += d.Y
+}
+
+// Merge is a collision in which Survivor absorbed the lighter Absorbed.
+type Merge struct {
+	Survivor *Body
+	Absorbed *Body
+}
+
+// System is a set of bodies attracting each other.
+type System struct {
+	Bodies                []*Body
+	GravitationalConstant float64
+	Integrator            Integrator
+	Tick                  int
+	Time                  float64
+}
+
+// Step advances all bodies by dt and merges the ones that touched in
+// between. Absorbed bodies are removed from Bodies and returned with the
+// body that absorbed them, in the order the collisions happened.
+func (system *System) Step(dt float64) []Merge {
+	system.Tick++
+	system.Time += dt
+
+	startPositions := make([]Vector, len(system.Bodies))
+	for i, body := range system.Bodies {
+		startPositions[i] = body.Position()
+	}
+
+	switch system.Integrator {
+	case Leapfrog:
+		system.stepLeapfrog(dt)
+	default:
+		system.stepEuler(dt)
+	}
+
+	merges := system.handleCollisions(startPositions, dt)
+	for _, merge := range merges {
+		system.Bodies = slices.DeleteFunc(system.Bodies, func(body *Body) bool {
+			return body == merge.Absorbed
+		})
+	}
+
+	return merges
+}
+
+// Acceleration returns the acceleration of body caused by all other bodies at
+// their current positions.
+func (system *System) Acceleration(body *Body) Vector {
+	acceleration := Vector{}
+
+	for _, other := range system.Bodies {
+		if other == body {
+			continue
+		}
+
+		// collisions are handled separately, so the distance is never zero
+		// for long
+		d := other.Position().Sub(body.Position())
+		distance := d.Length()
+		acceleration = acceleration.Add(d.Scale(system.GravitationalConstant * other.Mass / (distance * distance * distance)))
+	}
+
+	return acceleration
+}
+
+func (system *System) stepEuler(dt float64) {
+	for _, body := range system.Bodies {
+		body.Velocity = body.Velocity.Add(system.Acceleration(body).Scale(dt))
+		body.translate(body.Velocity.Scale(dt))
+	}
+}
+
+func (system *System) kick(dt float64) {
+	accelerations := make([]Vector, len(system.Bodies))
+	for i, body := range system.Bodies {
+		accelerations[i] = system.Acceleration(body)
+	}
+
+	for i, body := range system.Bodies {
+		body.Velocity = body.Velocity.Add(accelerations[i].Scale(dt))
+	}
+}
+
+func (system *System) stepLeapfrog(dt float64) {
+	system.kick(dt / 2)
+
+	for _, body := range system.Bodies {
+		body.translate(body.Velocity.Scale(dt))
+	}
+
+	system.kick(dt / 2)
+}
@@ -0,0 +1,67 @@
+package physics
+
+import (
+	"math"
+	"testing"
+)
+
+// circularOrbit returns a light body on a circular orbit around a heavy one.
+func circularOrbit(integrator Integrator) *System {
+	const g, mass, distance = 10000.0, 1000.0, 200.0
+	speed := math.Sqrt(g * mass / distance)
+
+	return &System{
+		Bodies: []*Body{
+			{ID: 1, Name: "star", Mass: mass, Radius: 10},
+			{ID: 2, Name: "planet", X: distance, Mass: 1e-6, Radius: 1, Velocity: Vector{0, speed}},
+		},
+		GravitationalConstant: g,
+		Integrator:            integrator,
+	}
+}
+
+func TestLeapfrogConservesEnergy(t *testing.T) {
+	system := circularOrbit(Leapfrog)
+	kinetic, potential := system.Energy()
+	initial := kinetic + potential
+
+	for range 10000 {
+		system.Step(0.001)
+	}
+
+	kinetic, potential = system.Energy()
+	if drift := RelativeDrift(initial, kinetic+potential); drift > 1e-6 {
+		t.Errorf("energy drift = %v", drift)
+	}
+	if distance := system.Bodies[1].Position().Sub(system.Bodies[0].Position()).Length(); math.Abs(distance-200) > 0.1 {
+		t.Errorf("distance = %v, want 200", distance)
+	}
+}
+
+func TestStepCountsTime(t *testing.T) {
+	system := circularOrbit(Euler)
+	for range 10 {
+		system.Step(0.5)
+	}
+
+	if system.Tick != 10 || system.Time != 5 {
+		t.Errorf("tick %d at %v, want 10 at 5", system.Tick, system.Time)
+	}
+}
+
+func TestUnbound(t *testing.T) {
+	system := circularOrbit(Euler)
+	if unbound := system.Unbound(100); len(unbound) != 0 {
+		t.Errorf("a body on a circular orbit escapes: %v", unbound)
+	}
+
+	// faster than the escape speed of √2 times the orbital speed
+	planet := system.Bodies[1]
+	planet.Velocity = planet.Velocity.Scale(1.5)
+	if unbound := system.Unbound(100); len(unbound) != 1 || unbound[0] != planet {
+		t.Errorf("unbound = %v, want the planet", unbound)
+	}
+	if unbound := system.Unbound(300); len(unbound) != 0 {
+		t.Errorf("a body closer than the distance escapes: %v", unbound)
+	}
+}
@@ -0,0 +1,56 @@
+package physics
+
+import "strconv"
+
+// TrajectoryColumns is the header of a trajectory CSV, the recorder of the
+// game and the headless run write the same rows.
+var TrajectoryColumns = []string{"tick", "time", "id", "name", "x", "y", "vx", "vy", "mass"}
+
+// TrajectorySample is the state of one body at a tick, one row of a
+// trajectory CSV or one line of a trajectory NDJSON file.
+type TrajectorySample struct {
+	Tick int     `json:"tick"`
+	Time float64 `json:"time"`
+	ID   int     `json:"id"`
+	Name string  `json:"name"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	VX   float64 `json:"vx"`
+	VY   float64 `json:"vy"`
+	Mass float64 `json:"mass"`
+}
+
+// NewTrajectorySample returns the state of body at tick and time.
+func NewTrajectorySample(tick int, time float64, body *Body) TrajectorySample {
+	return TrajectorySample{
+		Tick: tick,
+		Time: time,
+		ID:   body.ID,
+		Name: body.Name,
+		X:    body.X,
+		Y:    body.Y,
+		VX:   body.Velocity.X,
+		VY:   body.Velocity.Y,
+		Mass: body.Mass,
+	}
+}
+
+// Row returns the sample in the order of TrajectoryColumns. Floats keep all
+// their digits.
+func (sample TrajectorySample) Row() []string {
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	return []string{
+		strconv.Itoa(sample.Tick),
+		formatFloat(sample.Time),
+		strconv.Itoa(sample.ID),
+		sample.Name,
+		formatFloat(sample.X),
+		formatFloat(sample.Y),
+		formatFloat(sample.VX),
+		formatFloat(sample.VY),
+		formatFloat(sample.Mass),
+	}
+}
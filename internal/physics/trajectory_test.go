@@ -0,0 +1,19 @@
+package physics
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTrajectoryRow(t *testing.T) {
+	body := &Body{ID: 3, Name: "moon", X: 1.5, Y: -2, Velocity: Vector{0.1, 1e-9}, Mass: 7}
+	row := NewTrajectorySample(12, 0.125, body).Row()
+
+	want := []string{"12", "0.125", "3", "moon", "1.5", "-2", "0.1", "1e-09", "7"}
+	if !slices.Equal(row, want) {
+		t.Errorf("row = %q, want %q", row, want)
+	}
+	if len(row) != len(TrajectoryColumns) {
+		t.Errorf("%d values for %d columns", len(row), len(TrajectoryColumns))
+	}
+}
@@ -0,0 +1,30 @@
+package physics
+
+import "math"
+
+// Vector is a 2D vector in simulation units, y points down like on screen.
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (v Vector) Add(other Vector) Vector {
+	return Vector{v.X + other.X, v.Y + other.Y}
+}
+
+func (v Vector) Sub(other Vector) Vector {
+	return Vector{v.X - other.X, v.Y - other.Y}
+}
+
+func (v Vector) Scale(factor float64) Vector {
+	return Vector{v.X * factor, v.Y * factor}
+}
+
+func (v Vector) Length() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Cross returns the z component of the cross product.
+func (v Vector) Cross(other Vector) float64 {
+	return v.X*other.Y - v.Y*other.X
+}
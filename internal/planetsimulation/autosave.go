@@ -0,0 +1,187 @@
+package planetsimulation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// autosave periodically writes snapshots to a rotating set of files in the
+// user data directory. The state is captured in Update but encoded and
+// written in the background, and a save is skipped while the previous one
+// is still being written, so saving never stalls the game. A clean exit
+// leaves a marker, so a restore is only offered after a crash.
+type autosave struct {
+	isEnabled bool
+	directory string
+	slots     int
+	nextSlot  int
+	interval  int // seconds between two autosaves
+	lastSave  time.Time
+	isSaving  bool
+	done      chan error
+	status    string
+	err       error
+	// the newest autosave found on start, offered for restoring
+	recoveryPath  string
+	recoveryTime  time.Time
+	showRecovery  bool
+	shouldRestore bool
+}
+
+// newAutosave saves into dataDir, the user data directory if it is empty.
+func newAutosave(dataDir string) *autosave {
+	autosave := &autosave{
+		isEnabled: true,
+		slots:     3,
+		interval:  60,
+		lastSave:  time.Now(),
+		done:      make(chan error, 1),
+	}
+
+	if dataDir == "" {
+		var err error
+		dataDir, err = userDataDir()
+		if err != nil {
+			autosave.isEnabled = false
+			autosave.err = fmt.Errorf("autosave disabled: %w", err)
+			return autosave
+		}
+	}
+	autosave.directory = filepath.Join(dataDir, "autosave")
+	autosave.findRecovery()
+
+	return autosave
+}
+
+const cleanExitFileName = "clean-exit"
+
+func (autosave *autosave) slotPath(slot int) string {
+	return filepath.Join(autosave.directory, fmt.Sprintf("autosave-%d.json", slot+1))
+}
+
+// findRecovery looks for the newest autosave and continues the rotation
+// after it.
+func (autosave *autosave) findRecovery() {
+	for slot := range autosave.slots {
+		info, err := os.Stat(autosave.slotPath(slot))
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().After(autosave.recoveryTime) {
+			autosave.recoveryPath = autosave.slotPath(slot)
+			autosave.recoveryTime = info.ModTime()
+			autosave.nextSlot = (slot + 1) % autosave.slots
+		}
+	}
+
+	// the marker is removed, so a crash of this session offers a restore
+	// again
+	marker := filepath.Join(autosave.directory, cleanExitFileName)
+	_, err := os.Stat(marker)
+	isCleanExit := err == nil
+	if isCleanExit {
+		if err := os.Remove(marker); err != nil {
+			autosave.err = fmt.Errorf("failed to remove %s: %w", marker, err)
+		}
+	}
+
+	autosave.showRecovery = autosave.recoveryPath != "" && !isCleanExit
+}
+
+// save captures the state now and writes it in the background. It reports
+// and returns false if the previous save is still being written.
+func (autosave *autosave) save(sim *simulation) bool {
+	if autosave.directory == "" {
+		return false
+	}
+	if autosave.isSaving {
+		autosave.status = "Skipped an autosave, the last one is still being written"
+		return false
+	}
+
+	snapshot := sim.snapshot()
+	path := autosave.slotPath(autosave.nextSlot)
+	autosave.nextSlot = (autosave.nextSlot + 1) % autosave.slots
+	autosave.lastSave = time.Now()
+	autosave.isSaving = true
+
+	go func() {
+		content, err := json.Marshal(snapshot)
+		if err == nil {
+			err = writeFileAtomic(path, content)
+		}
+		autosave.done <- err
+	}()
+
+	return true
+}
+
+// wait blocks until the save that is being written is done.
+func (autosave *autosave) wait() {
+	if autosave.isSaving {
+		autosave.finish(<-autosave.done)
+	}
+}
+
+func (autosave *autosave) finish(err error) {
+	autosave.isSaving = false
+	if err != nil {
+		autosave.err = fmt.Errorf("autosave failed: %w", err)
+	} else {
+		autosave.err = nil
+		autosave.status = "Autosaved at " + autosave.lastSave.Format(time.TimeOnly)
+	}
+}
+
+// shutdown waits for the last save and marks the exit as clean.
+func (autosave *autosave) shutdown() error {
+	autosave.wait()
+	if autosave.directory == "" {
+		return nil
+	}
+
+	return writeFileAtomic(filepath.Join(autosave.directory, cleanExitFileName), nil)
+}
+
+func (autosave *autosave) restore(sim *simulation) {
+	content, err := readFile(autosave.recoveryPath)
+	if err != nil {
+		autosave.err = fmt.Errorf("failed to read %s: %w", autosave.recoveryPath, err)
+		return
+	}
+	snapshot, err := decodeSnapshot(content)
+	if err != nil {
+		autosave.err = fmt.Errorf("failed to restore %s: %w", autosave.recoveryPath, err)
+		return
+	}
+
+	sim.restore(snapshot)
+	autosave.status = "Restored the session of " + autosave.recoveryTime.Format(time.DateTime)
+}
+
+func (autosave *autosave) Update(sim *simulation) {
+	select {
+	case err := <-autosave.done:
+		autosave.finish(err)
+	default:
+	}
+
+	if autosave.shouldRestore {
+		autosave.shouldRestore = false
+		autosave.showRecovery = false
+		autosave.restore(sim)
+	}
+
+	// don't rotate the offered session away before it was decided on
+	if !autosave.isEnabled || autosave.showRecovery || len(sim.planetHandler.planets) == 0 {
+		return
+	}
+
+	if time.Since(autosave.lastSave) >= time.Duration(autosave.interval)*time.Second {
+		autosave.save(sim)
+	}
+}
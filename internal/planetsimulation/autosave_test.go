@@ -0,0 +1,112 @@
+package planetsimulation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestAutosave(t *testing.T, directory string) *autosave {
+	autosave := newAutosave(directory)
+	if autosave.err != nil {
+		t.Fatal(autosave.err)
+	}
+
+	return autosave
+}
+
+func TestAutosaveSkipsWhileSaving(t *testing.T) {
+	sim := newTestHistory(newTestPlanet("a", 0, 0, 1, 1, vector2{}))
+	sim.autosave = newTestAutosave(t, t.TempDir())
+
+	if !sim.autosave.save(sim) {
+		t.Fatal("the first save is skipped")
+	}
+	if sim.autosave.save(sim) {
+		t.Fatal("a second save is started while the first one is written")
+	}
+	if !strings.Contains(sim.autosave.status, "Skipped") {
+		t.Errorf("status = %q, want the skipped save to be reported", sim.autosave.status)
+	}
+
+	sim.autosave.wait()
+	if sim.autosave.isSaving || sim.autosave.err != nil {
+		t.Fatalf("still saving or failed after wait: %v", sim.autosave.err)
+	}
+	if !sim.autosave.save(sim) {
+		t.Error("the save after wait is skipped")
+	}
+	sim.autosave.wait()
+}
+
+func TestResetWaitsForAutosave(t *testing.T) {
+	directory := t.TempDir()
+	sim := newTestHistory(newTestPlanet("a", 0, 0, 1, 1, vector2{}), newTestPlanet("b", 50, 0, 1, 1, vector2{}))
+	sim.autosave = newTestAutosave(t, directory)
+	sim.scenarioGenerator = newScenarioGenerator()
+	sim.simulationPresets = &simulationPresets{}
+	// the snapshots have to be valid to be restored
+	sim.planetHandler.gravitationalConstant = 1
+	sim.tps = 120
+
+	// a periodic save is still being written when reset is pressed
+	sim.autosave.save(sim)
+	sim.shouldReset = true
+	sim.handleReset()
+	sim.autosave.wait()
+
+	if len(sim.planetHandler.planets) != 0 {
+		t.Fatalf("reset kept %d planets", len(sim.planetHandler.planets))
+	}
+	for slot := range 2 {
+		content, err := os.ReadFile(sim.autosave.slotPath(slot))
+		if err != nil {
+			t.Fatalf("the reset wasn't saved: %v", err)
+		}
+		snapshot, err := decodeSnapshot(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snapshot.Planets) != 2 {
+			t.Errorf("slot %d has %d planets, want the 2 before the reset", slot, len(snapshot.Planets))
+		}
+	}
+}
+
+func TestRecoveryOnlyAfterCrash(t *testing.T) {
+	directory := t.TempDir()
+	sim := newTestHistory(newTestPlanet("a", 0, 0, 1, 1, vector2{}))
+	sim.autosave = newTestAutosave(t, directory)
+	if sim.autosave.showRecovery {
+		t.Fatal("a restore is offered without autosaves")
+	}
+
+	// crash after a save
+	sim.autosave.save(sim)
+	sim.autosave.wait()
+	autosave := newTestAutosave(t, directory)
+	if !autosave.showRecovery || autosave.recoveryPath != sim.autosave.slotPath(0) {
+		t.Fatalf("the autosave isn't offered after a crash")
+	}
+
+	// exit cleanly
+	if err := autosave.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	autosave = newTestAutosave(t, directory)
+	if autosave.showRecovery {
+		t.Error("a restore is offered after a clean exit")
+	}
+	if autosave.nextSlot != 1 {
+		t.Errorf("next slot = %d, want the rotation to continue at 1", autosave.nextSlot)
+	}
+
+	// the marker only counts for the session it was written by
+	if _, err := os.Stat(filepath.Join(directory, "autosave", cleanExitFileName)); !os.IsNotExist(err) {
+		t.Fatalf("the clean exit marker is kept: %v", err)
+	}
+	if autosave = newTestAutosave(t, directory); !autosave.showRecovery {
+		t.Error("the autosave isn't offered after a crash following a clean exit")
+	}
+}
@@ -0,0 +1,315 @@
+package planetsimulation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"PlanetSimulation/internal/schema"
+)
+
+const (
+	columnName = iota
+	columnX
+	columnY
+	columnVX
+	columnVY
+	columnMass
+	columnRadius
+	columnColor
+)
+
+// bodyTableColumns are the fields of a body table. radius and color may be
+// missing from a file, everything else is required.
+var bodyTableColumns = []string{"name", "x", "y", "vx", "vy", "mass", "radius", "color"}
+
+// bodyTable imports and exports the planets as CSV with a header row. Every
+// field can be mapped to a differently named column and values are
+// multiplied by the scales when imported and divided by them when exported.
+type bodyTable struct {
+	filePath string
+	// header names in the file, in the order of bodyTableColumns
+	columns       []string
+	lengthScale   float64
+	velocityScale float64
+	massScale     float64
+	radiusScale   float64
+	defaultRadius float64
+	replaceScene  bool
+	shouldImport  bool
+	shouldExport  bool
+	status        string
+	err           error
+}
+
+// csvError points to the offending cell, line and column are 1-based like in
+// a spreadsheet.
+type csvError struct {
+	line   int
+	column int
+	name   string
+	err    error
+}
+
+func (err *csvError) Error() string {
+	return fmt.Sprintf("line %d, column %d (%s): %v", err.line, err.column, err.name, err.err)
+}
+
+func (err *csvError) Unwrap() error {
+	return err.err
+}
+
+func newBodyTable() *bodyTable {
+	return &bodyTable{
+		columns:       append([]string{}, bodyTableColumns...),
+		lengthScale:   1,
+		velocityScale: 1,
+		massScale:     1,
+		radiusScale:   1,
+		defaultRadius: 5,
+	}
+}
+
+// formatColor formats c as #rrggbbaa.
+func formatColor(c colorRecord) string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// parseColor parses #rrggbb or #rrggbbaa.
+func parseColor(s string) (colorRecord, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return colorRecord{}, fmt.Errorf("expected #rrggbb or #rrggbbaa, got %q", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return colorRecord{}, fmt.Errorf("expected #rrggbb or #rrggbbaa, got %q", s)
+	}
+
+	return colorRecord{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// columnIndices finds the mapped columns in header. Missing optional columns
+// get the index -1.
+func (table *bodyTable) columnIndices(header []string) ([]int, error) {
+	indices := make([]int, len(bodyTableColumns))
+	errs := []error{}
+
+	for i, name := range table.columns {
+		indices[i] = -1
+		for j, column := range header {
+			if strings.EqualFold(strings.TrimSpace(column), strings.TrimSpace(name)) {
+				indices[i] = j
+				break
+			}
+		}
+
+		if indices[i] == -1 && i != columnRadius && i != columnColor {
+			errs = append(errs, fmt.Errorf("line 1: missing column %q for %s", name, bodyTableColumns[i]))
+		}
+	}
+
+	return indices, errors.Join(errs...)
+}
+
+// decode reads a body table. All bad cells are reported, and nothing is
+// returned if there is any.
+func (table *bodyTable) decode(r io.Reader) ([]bodyRecord, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := table.columnIndices(header)
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := []bodyRecord{}
+	errs := []error{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Join(append(errs, err)...)
+		}
+		line, _ := reader.FieldPos(0)
+
+		body := bodyRecord{
+			Radius: table.defaultRadius,
+			Color:  colorRecord{R: 255, G: 255, B: 255, A: 255},
+		}
+
+		// cell returns the trimmed value of field i, ok is false if the
+		// optional column isn't in the file
+		cell := func(i int) (string, bool) {
+			if indices[i] == -1 {
+				return "", false
+			}
+			if indices[i] >= len(row) {
+				return "", true
+			}
+			return strings.TrimSpace(row[indices[i]]), true
+		}
+		cellError := func(i int, err error) {
+			errs = append(errs, &csvError{line, indices[i] + 1, table.columns[i], err})
+		}
+		number := func(i int, scale float64, isPositive bool, target *float64) {
+			value, ok := cell(i)
+			if !ok || value == "" && i == columnRadius {
+				return
+			}
+			if value == "" {
+				cellError(i, errors.New("missing value"))
+				return
+			}
+
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || !schema.IsFinite(v) {
+				cellError(i, fmt.Errorf("%q is not a number", value))
+				return
+			}
+			if isPositive && v <= 0 {
+				cellError(i, fmt.Errorf("must be positive, got %v", v))
+				return
+			}
+			*target = v * scale
+		}
+
+		body.Name, _ = cell(columnName)
+		number(columnX, table.lengthScale, false, &body.X)
+		number(columnY, table.lengthScale, false, &body.Y)
+		number(columnVX, table.velocityScale, false, &body.Velocity.X)
+		number(columnVY, table.velocityScale, false, &body.Velocity.Y)
+		number(columnMass, table.massScale, true, &body.Mass)
+		number(columnRadius, table.radiusScale, true, &body.Radius)
+		if value, ok := cell(columnColor); ok && value != "" {
+			parsed, err := parseColor(value)
+			if err != nil {
+				cellError(columnColor, err)
+			}
+			body.Color = parsed
+		}
+
+		bodies = append(bodies, body)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return bodies, nil
+}
+
+// encode writes planets with the mapped header names and scales.
+func (table *bodyTable) encode(w io.Writer, planets []*Planet) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.columns); err != nil {
+		return err
+	}
+
+	for _, planet := range planets {
+		body := bodyRecordFromPlanet(planet)
+		row := make([]string, len(bodyTableColumns))
+		row[columnName] = body.Name
+		row[columnX] = strconv.FormatFloat(body.X/table.lengthScale, 'g', -1, 64)
+		row[columnY] = strconv.FormatFloat(body.Y/table.lengthScale, 'g', -1, 64)
+		row[columnVX] = strconv.FormatFloat(body.Velocity.X/table.velocityScale, 'g', -1, 64)
+		row[columnVY] = strconv.FormatFloat(body.Velocity.Y/table.velocityScale, 'g', -1, 64)
+		row[columnMass] = strconv.FormatFloat(body.Mass/table.massScale, 'g', -1, 64)
+		row[columnRadius] = strconv.FormatFloat(body.Radius/table.radiusScale, 'g', -1, 64)
+		row[columnColor] = formatColor(body.Color)
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (sim *simulation) handleBodyTable() {
+	table := sim.bodyTable
+	planetHandler := sim.planetHandler
+
+	if table.shouldExport {
+		table.shouldExport = false
+		table.err = nil
+
+		buffer := bytes.Buffer{}
+		err := table.encode(&buffer, planetHandler.planets)
+		if err == nil {
+			err = writeFile(table.filePath, buffer.Bytes())
+		}
+		if err != nil {
+			table.err = fmt.Errorf("failed to export %s: %w", table.filePath, err)
+			return
+		}
+		table.status = fmt.Sprintf("Exported %d bodies", len(planetHandler.planets))
+	}
+
+	if table.shouldImport {
+		table.shouldImport = false
+		table.err = nil
+
+		content, err := readFile(table.filePath)
+		if err == nil && len(content) == 0 {
+			err = errors.New("the file does not exist or is empty")
+		}
+		if err != nil {
+			table.err = fmt.Errorf("failed to read %s: %w", table.filePath, err)
+			return
+		}
+
+		bodies, err := table.decode(bytes.NewReader(content))
+		if err != nil {
+			table.err = fmt.Errorf("failed to import %s:\n%w", table.filePath, err)
+			return
+		}
+
+		// the scene is only replaced once the whole file could be read
+		if table.replaceScene {
+			sim.reset()
+		}
+
+		for _, body := range bodies {
+			planetHandler.planetCounter++
+			if body.Name == "" {
+				body.Name = fmt.Sprintf("Planet %d", planetHandler.planetCounter)
+			}
+
+			planetHandler.addPlanet(newPlanet(
+				body.Name,
+				body.X,
+				body.Y,
+				body.Radius,
+				body.Mass,
+				vector2(body.Velocity),
+				color.NRGBA{body.Color.R, body.Color.G, body.Color.B, body.Color.A},
+				planetHandler.planetsOffset,
+			))
+		}
+
+		planetHandler.running = false
+		table.status = fmt.Sprintf("Imported %d bodies", len(bodies))
+	}
+}
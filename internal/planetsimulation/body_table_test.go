@@ -0,0 +1,175 @@
+package planetsimulation
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+
+	"PlanetSimulation/internal/physics"
+)
+
+func TestBodyTableErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  string
+		errors []csvError
+	}{
+		{
+			"not a number",
+			"name,x,y,vx,vy,mass\na,1,2,3,4,5\nb,1,two,3,4,5\n",
+			[]csvError{{line: 3, column: 3, name: "y"}},
+		},
+		{
+			"every bad cell",
+			"name,x,y,vx,vy,mass,radius,color\na,1,2,3,4,-5,0,#12\n",
+			[]csvError{{line: 2, column: 6, name: "mass"}, {line: 2, column: 7, name: "radius"}, {line: 2, column: 8, name: "color"}},
+		},
+		{
+			"missing value",
+			"mass,name,x,y,vx,vy\n1,a,1,2,3\n",
+			[]csvError{{line: 2, column: 6, name: "vy"}},
+		},
+		{
+			"infinite",
+			"name,x,y,vx,vy,mass\na,1,2,3,+Inf,5\n",
+			[]csvError{{line: 2, column: 5, name: "vy"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bodies, err := newBodyTable().decode(strings.NewReader(test.table))
+			if err == nil || bodies != nil {
+				t.Fatalf("decode = %v, %v, want an error", bodies, err)
+			}
+
+			// the cells are reported in the order of the file
+			var joined interface{ Unwrap() []error }
+			errs := []error{err}
+			if errors.As(err, &joined) {
+				errs = joined.Unwrap()
+			}
+			if len(errs) != len(test.errors) {
+				t.Fatalf("got %d errors, want %d: %v", len(errs), len(test.errors), err)
+			}
+			for i, err := range errs {
+				got, ok := err.(*csvError)
+				want := test.errors[i]
+				if !ok || got.line != want.line || got.column != want.column || got.name != want.name {
+					t.Errorf("error %d = %v, want line %d, column %d (%s)", i, err, want.line, want.column, want.name)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyTableHeader(t *testing.T) {
+	if _, err := newBodyTable().decode(strings.NewReader("")); err == nil {
+		t.Error("an empty file is decoded")
+	}
+
+	_, err := newBodyTable().decode(strings.NewReader("name,x,vx,vy\n"))
+	if err == nil || !strings.Contains(err.Error(), `line 1: missing column "y"`) || !strings.Contains(err.Error(), `missing column "mass"`) {
+		t.Errorf("err = %v, want the missing y and mass columns", err)
+	}
+}
+
+func TestBodyTableColumnMapping(t *testing.T) {
+	table := newBodyTable()
+	table.columns = []string{"Body", "PosX", "PosY", "VelX", "VelY", "M", "R", "Colour"}
+	table.lengthScale = 10
+	table.velocityScale = 2
+	table.massScale = 1000
+	table.radiusScale = 0.5
+	table.defaultRadius = 7
+
+	// any order and case, the radius and color are optional
+	content := "m, velx, vely, posx, posy, body, extra\n" +
+		"3, 1, -1, 0.5, 2, Earth, ignored\n"
+	bodies, err := table.decode(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := bodyRecord{
+		Name:     "Earth",
+		X:        5,
+		Y:        20,
+		Velocity: physics.Vector{X: 2, Y: -2},
+		Mass:     3000,
+		Radius:   7,
+		Color:    colorRecord{R: 255, G: 255, B: 255, A: 255},
+	}
+	if len(bodies) != 1 || bodies[0] != want {
+		t.Errorf("bodies = %+v, want %+v", bodies, want)
+	}
+
+	// an empty radius keeps the default, a given one is scaled
+	content = "Body,PosX,PosY,VelX,VelY,M,R,Colour\n" +
+		"a,0,0,0,0,1,,#102030\n" +
+		"b,0,0,0,0,1,4,#10203040\n"
+	bodies, err = table.decode(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bodies[0].Radius != 7 || bodies[0].Color != (colorRecord{R: 0x10, G: 0x20, B: 0x30, A: 0xff}) {
+		t.Errorf("a = %+v, want radius 7 and an opaque color", bodies[0])
+	}
+	if bodies[1].Radius != 2 || bodies[1].Color != (colorRecord{R: 0x10, G: 0x20, B: 0x30, A: 0x40}) {
+		t.Errorf("b = %+v, want radius 2 and a translucent color", bodies[1])
+	}
+}
+
+func TestBodyTableRoundTrip(t *testing.T) {
+	a := newTestPlanet("Earth, the blue one", 12.5, -3, 4, 1.5e6, vector2{0.25, -7})
+	a.Color = color.NRGBA{80, 140, 255, 200}
+	b := newTestPlanet("Moon", 1e-3, 2e9, 0.5, 1, vector2{})
+	planets := []*Planet{a, b}
+
+	for _, scaled := range []bool{false, true} {
+		table := newBodyTable()
+		table.columns = []string{"n", "px", "py", "vx", "vy", "m", "r", "c"}
+		if scaled {
+			table.lengthScale = 3
+			table.velocityScale = 0.1
+			table.massScale = 1e4
+			table.radiusScale = 7
+		}
+
+		buffer := bytes.Buffer{}
+		if err := table.encode(&buffer, planets); err != nil {
+			t.Fatal(err)
+		}
+		if header, _, _ := strings.Cut(buffer.String(), "\n"); header != "n,px,py,vx,vy,m,r,c" {
+			t.Errorf("header = %q", header)
+		}
+
+		bodies, err := table.decode(&buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bodies) != len(planets) {
+			t.Fatalf("got %d bodies, want %d", len(bodies), len(planets))
+		}
+		for i, planet := range planets {
+			want := bodyRecordFromPlanet(planet)
+			got := bodies[i]
+			if got.Name != want.Name || got.Color != want.Color {
+				t.Errorf("scaled %v: body %d = %+v, want %+v", scaled, i, got, want)
+			}
+			for _, pair := range [][2]float64{{got.X, want.X}, {got.Y, want.Y}, {got.Velocity.X, want.Velocity.X}, {got.Velocity.Y, want.Velocity.Y}, {got.Mass, want.Mass}, {got.Radius, want.Radius}} {
+				if !closeTo(pair[0], pair[1]) {
+					t.Errorf("scaled %v: body %d = %+v, want %+v", scaled, i, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+// closeTo compares the values up to the rounding of a scale.
+func closeTo(a float64, b float64) bool {
+	return a == b || (a-b)*(a-b) <= 1e-24*b*b
+}
@@ -0,0 +1,146 @@
+package planetsimulation
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// chaosAnalysis runs a hidden twin of the simulation that starts epsilon away
+// from it and estimates the maximal Lyapunov exponent with the method of
+// Benettin et al.: whenever the twin has been stepped RenormalizeEveryNTick
+// times its separation d is logged as ln(d / epsilon) and scaled back to
+// epsilon, so the twin never leaves the linear regime.
+type chaosAnalysis struct {
+	twin                  *planetHandler
+	epsilon               float64
+	isRunning             bool
+	status                string
+	TickCount             int
+	RenormalizeEveryNTick int
+	startTime             float64
+	logStretch            float64
+	separation            float64
+	lyapunovExponent      float64
+	// elapsed time and accumulated ln(d / epsilon) at every renormalisation
+	samples    []vector2
+	maxSamples int
+}
+
+func newChaosAnalysis() *chaosAnalysis {
+	return &chaosAnalysis{
+		epsilon:               1e-3,
+		RenormalizeEveryNTick: 60,
+		maxSamples:            500,
+		status:                "Not running",
+	}
+}
+
+// separationOf returns the distance of both systems in position space.
+func separationOf(planetHandler *planetHandler, twin *planetHandler) float64 {
+	sum := 0.0
+	for i, planet := range planetHandler.planets {
+		dx := twin.planets[i].X - planet.X
+		dy := twin.planets[i].Y - planet.Y
+		sum += dx*dx + dy*dy
+	}
+
+	return math.Sqrt(sum)
+}
+
+func (analysis *chaosAnalysis) start(planetHandler *planetHandler) {
+	if len(planetHandler.planets) == 0 {
+		analysis.status = "Nothing to analyse"
+		return
+	}
+
+	analysis.twin = planetHandler.clone()
+
+	// random direction with a total length of epsilon
+	directions := make([]vector2, len(analysis.twin.planets))
+	length := 0.0
+	for i := range directions {
+		directions[i] = vector2{rand.NormFloat64(), rand.NormFloat64()}
+		length += directions[i].X*directions[i].X + directions[i].Y*directions[i].Y
+	}
+	length = math.Sqrt(length)
+
+	for i, planet := range analysis.twin.planets {
+		planet.translate(directions[i].X/length*analysis.epsilon, directions[i].Y/length*analysis.epsilon)
+	}
+
+	analysis.isRunning = true
+	analysis.status = "Running"
+	analysis.TickCount = 0
+	analysis.startTime = planetHandler.simulatedTime
+	analysis.logStretch = 0
+	analysis.lyapunovExponent = 0
+	analysis.separation = analysis.epsilon
+	analysis.samples = analysis.samples[:0]
+}
+
+func (analysis *chaosAnalysis) stop(status string) {
+	analysis.isRunning = false
+	analysis.twin = nil
+	analysis.status = status
+}
+
+// renormalize moves every twin planet back towards its original so their
+// separation is epsilon again, keeping the direction of the deviation.
+func (analysis *chaosAnalysis) renormalize(planetHandler *planetHandler) {
+	factor := analysis.epsilon / analysis.separation
+
+	for i, planet := range planetHandler.planets {
+		twinPlanet := analysis.twin.planets[i]
+		twinPlanet.setPosition(
+			planet.X+(twinPlanet.X-planet.X)*factor,
+			planet.Y+(twinPlanet.Y-planet.Y)*factor,
+		)
+		twinPlanet.Velocity = vector2{
+			X: planet.Velocity.X + (twinPlanet.Velocity.X-planet.Velocity.X)*factor,
+			Y: planet.Velocity.Y + (twinPlanet.Velocity.Y-planet.Velocity.Y)*factor,
+		}
+	}
+}
+
+func (analysis *chaosAnalysis) Update(planetHandler *planetHandler) {
+	if !analysis.isRunning {
+		return
+	}
+
+	// follow the original tick by tick with the same time steps
+	for analysis.twin.tick < planetHandler.tick {
+		analysis.twin.handlePlanetDeletion()
+		analysis.twin.step(planetHandler.lastTimeStep)
+		analysis.TickCount++
+	}
+	analysis.twin.handlePlanetDeletion()
+
+	if len(analysis.twin.planets) != len(planetHandler.planets) || len(planetHandler.planetsToRemove) != 0 {
+		analysis.stop("Stopped, planets were added, removed or merged")
+		return
+	}
+
+	analysis.separation = separationOf(planetHandler, analysis.twin)
+	if analysis.separation == 0 || math.IsNaN(analysis.separation) {
+		analysis.stop("Stopped, separation is no longer measurable")
+		return
+	}
+
+	if analysis.TickCount < analysis.RenormalizeEveryNTick {
+		return
+	}
+
+	analysis.logStretch += math.Log(analysis.separation / analysis.epsilon)
+	elapsed := planetHandler.simulatedTime - analysis.startTime
+	if elapsed > 0 {
+		analysis.lyapunovExponent = analysis.logStretch / elapsed
+	}
+
+	analysis.samples = append(analysis.samples, vector2{elapsed, analysis.logStretch})
+	if len(analysis.samples) > analysis.maxSamples {
+		analysis.samples = analysis.samples[1:]
+	}
+
+	analysis.renormalize(planetHandler)
+	analysis.TickCount = 0
+}
@@ -0,0 +1,95 @@
+package planetsimulation
+
+import (
+	"math"
+	"testing"
+)
+
+// newTestCircularOrbit returns a planet on a circular orbit around a heavy star.
+func newTestCircularOrbit() *planetHandler {
+	planetHandler := newTestPlanetHandler()
+	planetHandler.gravitationalConstant = 1
+	planetHandler.integrator = integratorLeapfrog
+	planetHandler.timeStep = 0.01
+	planetHandler.addPlanet(newTestPlanet("star", 0, 0, 10, 1e6, vector2{}))
+	planetHandler.addPlanet(newTestPlanet("planet", 200, 0, 1, 1, vector2{0, math.Sqrt(1e6 / 200)}))
+
+	return planetHandler
+}
+
+func TestCloneSharesNothing(t *testing.T) {
+	planetHandler := newTestCircularOrbit()
+	clone := planetHandler.clone()
+
+	clone.setCamera([]float64{30, -40})
+	clone.planets[1].translate(5, 5)
+	clone.planets[1].Velocity.Y = 0
+	if offset := planetHandler.planetsOffset; offset[0] != planetHandler.defaultPlanetsOffset[0] || offset[1] != planetHandler.defaultPlanetsOffset[1] {
+		t.Errorf("moving the camera of the clone moved the original to %v", offset)
+	}
+	planet := planetHandler.planets[1]
+	if planet.X != 200 || planet.Y != 0 || planet.Velocity.Y == 0 {
+		t.Errorf("changing the clone changed the planet %+v", planet)
+	}
+
+	for i, planet := range planetHandler.planets {
+		cloned := clone.planets[i]
+		if cloned == planet || cloned.id != planet.id {
+			t.Fatalf("planet %d isn't a copy", i)
+		}
+		if &cloned.Offset[0] == &planet.Offset[0] || &cloned.Offset[0] != &clone.planetsOffset[0] {
+			t.Errorf("planet %d doesn't use the camera of the clone", i)
+		}
+		if cloned.image != nil && cloned.image == planet.image {
+			t.Errorf("planet %d shares its image", i)
+		}
+	}
+}
+
+func TestRegularOrbitIsNotChaotic(t *testing.T) {
+	planetHandler := newTestCircularOrbit()
+	analysis := newChaosAnalysis()
+	analysis.start(planetHandler)
+
+	// about ten orbits, the separation of a regular orbit only grows
+	// linearly so the estimate falls like ln(t) / t
+	for range 20000 {
+		planetHandler.Update()
+		analysis.Update(planetHandler)
+	}
+	if !analysis.isRunning || len(analysis.samples) == 0 {
+		t.Fatalf("the analysis stopped with %q", analysis.status)
+	}
+	if math.Abs(analysis.lyapunovExponent) > 0.05 {
+		t.Errorf("exponent = %v, want about 0", analysis.lyapunovExponent)
+	}
+}
+
+func TestEditStopsChaosAnalysis(t *testing.T) {
+	sim := newTestHistory()
+	sim.planetHandler = newTestCircularOrbit()
+	planet := sim.planetHandler.planets[1]
+	setMass := func(mass float64) func(p *Planet) {
+		return func(p *Planet) { p.Mass = mass }
+	}
+
+	sim.chaosAnalysis.start(sim.planetHandler)
+	sim.history.editPlanet(planet, "mass", setMass(1), setMass(2))
+	sim.handleHistory()
+	if sim.chaosAnalysis.isRunning {
+		t.Error("the analysis kept running after an edit")
+	}
+
+	sim.chaosAnalysis.start(sim.planetHandler)
+	undo(sim)
+	if sim.chaosAnalysis.isRunning || planet.Mass != 1 {
+		t.Errorf("the analysis kept running after undoing the edit to mass %v", planet.Mass)
+	}
+
+	// other commands leave it running
+	sim.chaosAnalysis.start(sim.planetHandler)
+	sim.handleHistory()
+	if !sim.chaosAnalysis.isRunning {
+		t.Errorf("the analysis stopped with %q without an edit", sim.chaosAnalysis.status)
+	}
+}
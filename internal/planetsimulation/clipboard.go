@@ -0,0 +1,96 @@
+package planetsimulation
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The clipboard is accessed through the tools of the system, the first one
+// that is installed is used.
+type clipboardTool struct {
+	write []string
+	read  []string
+}
+
+func clipboardTools() []clipboardTool {
+	switch runtime.GOOS {
+	case "windows":
+		return []clipboardTool{{
+			write: []string{"powershell", "-NoProfile", "-Command", "$input | Set-Clipboard"},
+			read:  []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+		}}
+	case "darwin":
+		return []clipboardTool{{
+			write: []string{"pbcopy"},
+			read:  []string{"pbpaste"},
+		}}
+	}
+
+	tools := []clipboardTool{
+		{write: []string{"xclip", "-selection", "clipboard"}, read: []string{"xclip", "-selection", "clipboard", "-o"}},
+		{write: []string{"xsel", "--clipboard", "--input"}, read: []string{"xsel", "--clipboard", "--output"}},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append([]clipboardTool{{write: []string{"wl-copy"}, read: []string{"wl-paste", "--no-newline"}}}, tools...)
+	}
+
+	return tools
+}
+
+func findClipboardTool() (clipboardTool, error) {
+	names := []string{}
+	for _, tool := range clipboardTools() {
+		if _, err := exec.LookPath(tool.write[0]); err == nil {
+			return tool, nil
+		}
+		names = append(names, tool.write[0])
+	}
+
+	return clipboardTool{}, fmt.Errorf("no clipboard tool found, install one of %s", strings.Join(names, ", "))
+}
+
+func writeClipboard(text string) error {
+	tool, err := findClipboardTool()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(tool.write[0], tool.write[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return runClipboardTool(cmd)
+}
+
+func readClipboard() (string, error) {
+	tool, err := findClipboardTool()
+	if err != nil {
+		return "", err
+	}
+
+	output := bytes.Buffer{}
+	cmd := exec.Command(tool.read[0], tool.read[1:]...)
+	cmd.Stdout = &output
+	if err := runClipboardTool(cmd); err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
+
+// runClipboardTool runs cmd and adds what it printed to errors.
+func runClipboardTool(cmd *exec.Cmd) error {
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if message := strings.TrimSpace(stderr.String()); err != nil && message != "" {
+		return fmt.Errorf("%s: %w: %s", cmd.Args[0], err, message)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+
+	return nil
+}
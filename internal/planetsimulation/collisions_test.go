@@ -0,0 +1,88 @@
+package planetsimulation
+
+import (
+	"math"
+	"testing"
+)
+
+func newTestPlanetHandler(planets ...*Planet) *planetHandler {
+	planetHandler := newPlanetHandler([]int{800, 600}, newMemoryStorage(), newMemoryStorage())
+	// only test the collisions, not the gravitation
+	planetHandler.gravitationalConstant = 0
+	planetHandler.planets = planets
+
+	return planetHandler
+}
+
+func newTestPlanet(name string, x float64, y float64, radius float64, mass float64, velocity vector2) *Planet {
+	return newPlanet(name, x, y, radius, mass, velocity, SetColor(255, 255, 255, 255), []float64{0, 0})
+}
+
+func TestFastImpactorDoesNotTunnel(t *testing.T) {
+	target := newTestPlanet("target", 0, 0, 20, 1000, vector2{0, 0})
+	// travels 1000 units in one step, ending far behind the target
+	impactor := newTestPlanet("impactor", -200, 0, 1, 1, vector2{100000, 0})
+	planetHandler := newTestPlanetHandler(target, impactor)
+
+	planetHandler.step(0.01)
+	planetHandler.handlePlanetDeletion()
+
+	if len(planetHandler.planets) != 1 {
+		t.Fatalf("got %d planets, want 1", len(planetHandler.planets))
+	}
+	if planetHandler.planets[0] != target {
+		t.Fatalf("the impactor survived instead of the target")
+	}
+	if target.Mass <= 1000 {
+		t.Errorf("mass = %v, impactor was not merged", target.Mass)
+	}
+}
+
+func TestFastImpactorHitsFirstPlanetOnItsPath(t *testing.T) {
+	first := newTestPlanet("first", 0, 0, 10, 1000, vector2{0, 0})
+	second := newTestPlanet("second", 300, 0, 10, 1000, vector2{0, 0})
+	impactor := newTestPlanet("impactor", -200, 0, 1, 1, vector2{100000, 0})
+	planetHandler := newTestPlanetHandler(first, second, impactor)
+
+	planetHandler.step(0.01)
+	planetHandler.handlePlanetDeletion()
+
+	if len(planetHandler.planets) != 2 {
+		t.Fatalf("got %d planets, want 2", len(planetHandler.planets))
+	}
+	if first.Mass <= 1000 {
+		t.Errorf("first planet mass = %v, impactor was not merged into it", first.Mass)
+	}
+	if second.Mass != 1000 {
+		t.Errorf("second planet mass = %v, impactor went through the first planet", second.Mass)
+	}
+}
+
+func TestFastImpactorResolvedAtImpactTime(t *testing.T) {
+	target := newTestPlanet("target", 0, 0, 20, 1000, vector2{0, 0})
+	impactor := newTestPlanet("impactor", -200, 0, 1, 1, vector2{100000, 0})
+	planetHandler := newTestPlanetHandler(target, impactor)
+
+	planetHandler.step(0.01)
+
+	// the impact happens at x = -21, the target only keeps the momentum
+	// transferred by the merge for the rest of the step
+	remainingTime := (1 - 179.0/1000) * 0.01
+	want := target.Velocity.X * remainingTime
+	if math.Abs(target.X-want) > 1e-6 {
+		t.Errorf("x = %v, want %v", target.X, want)
+	}
+}
+
+func TestFastImpactorMisses(t *testing.T) {
+	target := newTestPlanet("target", 0, 0, 20, 1000, vector2{0, 0})
+	impactor := newTestPlanet("impactor", -200, 25, 1, 1, vector2{100000, 0})
+	planetHandler := newTestPlanetHandler(target, impactor)
+
+	planetHandler.step(0.01)
+	planetHandler.handlePlanetDeletion()
+
+	if len(planetHandler.planets) != 2 {
+		t.Fatalf("got %d planets, want 2", len(planetHandler.planets))
+	}
+}
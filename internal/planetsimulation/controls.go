@@ -3,6 +3,7 @@ package planetsimulation
 import (
 	"slices"
 
+	"github.com/ebitengine/debugui"
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
@@ -62,12 +63,41 @@ func (controls *controls) isUiFocused(ui *ui) bool {
 	return false
 }
 
-func (controls *controls) Update(planetHandler *planetHandler, ui *ui) {
+func (controls *controls) Update(sim *simulation, ui *ui) {
+	planetHandler := sim.planetHandler
+	// replays are read-only, only the camera can be moved
+	if sim.replay.isPlaying() {
+		if !controls.isUiFocused(ui) {
+			controls.handleMovement(planetHandler, ui)
+			if controls.isKeyJustPressed(ebiten.KeySpace) {
+				sim.replay.player.isPaused = !sim.replay.player.isPaused
+			}
+		}
+		return
+	}
+
+	// the preview of a rewind is read-only as well
+	if sim.rewind.isRewinding() {
+		if !controls.isUiFocused(ui) {
+			controls.handleMovement(planetHandler, ui)
+		}
+		if ui.hasFocus&debugui.InputCapturingStateFocus == 0 {
+			controls.handleRewind(sim.rewind)
+		}
+		return
+	}
+
 	if !controls.isUiFocused(ui) {
 		controls.handlePlanetCreation(planetHandler, ui)
 		controls.handleMovement(planetHandler, ui)
 		controls.handlePausing(planetHandler, ui)
 	}
+	// hotkeys also work while hovering the ui, but not while typing
+	if ui.hasFocus&debugui.InputCapturingStateFocus == 0 {
+		controls.handleQuickSave(sim.snapshots)
+		controls.handleHistory(sim.history)
+		controls.handleRewind(sim.rewind)
+	}
 }
 
 func (controls *controls) checkUIFocusLayouts(ui *ui, mouseX int, mouseY int) bool {
@@ -102,26 +132,75 @@ func (controls *controls) handlePlanetCreation(planetHandler *planetHandler, ui
 	}
 }
 
-func (controls *controls) handlePausing(planetHandler *planetHandler, ui *ui) {
-	if ebiten.IsKeyPressed(ebiten.KeySpace) {
-		if slices.Contains(controls.keysPressed, ebiten.KeySpace) {
-			return
-		}
+// isKeyJustPressed reports whether key went down since the last update.
+func (controls *controls) isKeyJustPressed(key ebiten.Key) bool {
+	if !ebiten.IsKeyPressed(key) {
+		controls.keysPressed = slices.DeleteFunc(controls.keysPressed, func(pressedKey ebiten.Key) bool {
+			return pressedKey == key
+		})
+		return false
+	}
+
+	if slices.Contains(controls.keysPressed, key) {
+		return false
+	}
 
+	controls.keysPressed = append(controls.keysPressed, key)
+	return true
+}
+
+func (controls *controls) handlePausing(planetHandler *planetHandler, ui *ui) {
+	if controls.isKeyJustPressed(ebiten.KeySpace) {
 		planetHandler.running = !planetHandler.running
-		controls.keysPressed = append(controls.keysPressed, ebiten.KeySpace)
 	}
+}
 
-	if !ebiten.IsKeyPressed(ebiten.KeySpace) {
-		controls.keysPressed = slices.DeleteFunc(controls.keysPressed, func(key ebiten.Key) bool {
-			if key == ebiten.KeySpace {
-				return true
-			}
-			return false
-		})
+func (controls *controls) handleQuickSave(snapshots *snapshots) {
+	if controls.isKeyJustPressed(ebiten.KeyF5) {
+		snapshots.save(snapshots.quickSaveName)
+	}
+	if controls.isKeyJustPressed(ebiten.KeyF9) {
+		snapshots.load(snapshots.quickSaveName)
+	}
+}
+
+func (controls *controls) handleHistory(history *history) {
+	isControlPressed := ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)
+	// check both keys every update so their pressed state stays up to date
+	isUndoPressed := controls.isKeyJustPressed(ebiten.KeyZ)
+	isRedoPressed := controls.isKeyJustPressed(ebiten.KeyY)
+	if !isControlPressed {
+		return
+	}
+
+	if isUndoPressed && ebiten.IsKeyPressed(ebiten.KeyShift) || isRedoPressed {
+		history.shouldRedo = true
+	} else if isUndoPressed {
+		history.shouldUndo = true
 	}
 }
 
+// handleRewind starts rewinding with Backspace. While rewinding Backspace
+// and the left arrow step back, the right arrow steps forward, Enter resumes
+// and Escape returns to the newest state.
+func (controls *controls) handleRewind(rewind *rewind) {
+	isBackPressed := controls.isKeyJustPressed(ebiten.KeyBackspace)
+	isLeftPressed := controls.isKeyJustPressed(ebiten.KeyArrowLeft)
+	isRightPressed := controls.isKeyJustPressed(ebiten.KeyArrowRight)
+	isEnterPressed := controls.isKeyJustPressed(ebiten.KeyEnter)
+	isEscapePressed := controls.isKeyJustPressed(ebiten.KeyEscape)
+
+	if !rewind.isRewinding() {
+		rewind.shouldStart = isBackPressed
+		return
+	}
+
+	rewind.shouldStepBackward = isBackPressed || isLeftPressed
+	rewind.shouldStepForward = isRightPressed
+	rewind.shouldResume = isEnterPressed
+	rewind.shouldCancel = isEscapePressed
+}
+
 func (controls *controls) handleMovement(planetHandler *planetHandler, ui *ui) {
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButton1) {
 		ebiten.SetCursorShape(ebiten.CursorShapeMove)
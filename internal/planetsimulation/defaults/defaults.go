@@ -0,0 +1,10 @@
+// Package defaults holds the built-in presets. It doesn't depend on the game,
+// so the presets can also be loaded headless.
+package defaults
+
+import "embed"
+
+// Presets contains planet_presets.json and simulation_presets.json.
+//
+//go:embed *.json
+var Presets embed.FS
@@ -0,0 +1,251 @@
+package planetsimulation
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"PlanetSimulation/internal/physics"
+)
+
+type eventKind int
+
+const (
+	eventCreated eventKind = iota
+	eventDeleted
+	eventMerged
+	// collisions currently always merge, kept so subscribers can already handle it
+	eventFragmented
+	eventEscaped
+	eventCloseApproach
+	eventPeriapsis
+)
+
+func (kind eventKind) String() string {
+	switch kind {
+	case eventCreated:
+		return "created"
+	case eventDeleted:
+		return "deleted"
+	case eventMerged:
+		return "merged"
+	case eventFragmented:
+		return "fragmented"
+	case eventEscaped:
+		return "escaped"
+	case eventCloseApproach:
+		return "close approach"
+	case eventPeriapsis:
+		return "periapsis"
+	}
+
+	return "unknown"
+}
+
+func (kind eventKind) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
+// simulationEvent is emitted by the planetHandler whenever something noteworthy
+// happens. Other is the second planet involved, e.g. the absorbed planet of a
+// merge or the parent of a periapsis passage.
+type simulationEvent struct {
+	Kind     eventKind `json:"kind"`
+	Tick     int       `json:"tick"`
+	Time     float64   `json:"time"`
+	Planet   string    `json:"planet"`
+	Other    string    `json:"other,omitempty"`
+	Distance float64   `json:"distance,omitempty"`
+	planet   *Planet
+	other    *Planet
+}
+
+type eventBus struct {
+	subscribers map[int]func(event simulationEvent)
+	nextID      int
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[int]func(event simulationEvent)),
+	}
+}
+
+// subscribe registers f for all future events and returns an id to unsubscribe.
+func (bus *eventBus) subscribe(f func(event simulationEvent)) int {
+	id := bus.nextID
+	bus.subscribers[id] = f
+	bus.nextID++
+
+	return id
+}
+
+func (bus *eventBus) unsubscribe(id int) {
+	delete(bus.subscribers, id)
+}
+
+func (bus *eventBus) emit(event simulationEvent) {
+	// call in subscription order
+	ids := make([]int, 0, len(bus.subscribers))
+	for id := range bus.subscribers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		bus.subscribers[id](event)
+	}
+}
+
+func (handler *planetHandler) emitEvent(kind eventKind, p *Planet, other *Planet, distance float64) {
+	event := simulationEvent{
+		Kind:     kind,
+		Tick:     handler.tick,
+		Time:     handler.simulatedTime,
+		Planet:   p.Name,
+		Distance: distance,
+		planet:   p,
+		other:    other,
+	}
+	if other != nil {
+		event.Other = other.Name
+	}
+
+	handler.events.emit(event)
+}
+
+type approach struct {
+	distance      float64
+	isApproaching bool
+}
+
+// eventDetector finds events that build up over several steps like close
+// approaches and periapsis passages.
+type eventDetector struct {
+	approaches   map[[2]*Planet]approach
+	radialSpeeds map[*Planet]float64
+	// planets closer than this times their combined radius are a close approach
+	closeApproachFactor float64
+}
+
+func newEventDetector() *eventDetector {
+	return &eventDetector{
+		approaches:          make(map[[2]*Planet]approach),
+		radialSpeeds:        make(map[*Planet]float64),
+		closeApproachFactor: 3,
+	}
+}
+
+func (detector *eventDetector) detect(planetHandler *planetHandler) {
+	detector.detectCloseApproaches(planetHandler)
+	detector.detectPeriapsisPassages(planetHandler)
+}
+
+func (detector *eventDetector) detectCloseApproaches(planetHandler *planetHandler) {
+	planets := planetHandler.planets
+
+	hash := physics.NewSpatialHash(1)
+	for _, planet := range planets {
+		hash.CellSize = max(hash.CellSize, planet.Radius*2*detector.closeApproachFactor)
+	}
+	for i, planet := range planets {
+		radius := planet.Radius * detector.closeApproachFactor
+		hash.Insert(i, planet.X-radius, planet.Y-radius, planet.X+radius, planet.Y+radius)
+	}
+
+	seen := make(map[[2]*Planet]bool)
+	for _, pair := range hash.CandidatePairs() {
+		if slices.Contains(planetHandler.planetsToRemove, pair[0]) || slices.Contains(planetHandler.planetsToRemove, pair[1]) {
+			continue
+		}
+
+		p, otherPlanet := planets[pair[0]], planets[pair[1]]
+		_, _, distance, _ := overlapsCircle(p.X, otherPlanet.X, p.Y, otherPlanet.Y, p.Radius, otherPlanet.Radius)
+		if distance > (p.Radius+otherPlanet.Radius)*detector.closeApproachFactor {
+			continue
+		}
+
+		key := [2]*Planet{p, otherPlanet}
+		seen[key] = true
+
+		previous, ok := detector.approaches[key]
+		if !ok {
+			detector.approaches[key] = approach{distance, true}
+			continue
+		}
+
+		// the distance grows again, so the last one was the minimum
+		if previous.isApproaching && distance > previous.distance {
+			planetHandler.emitEvent(eventCloseApproach, p, otherPlanet, previous.distance)
+		}
+
+		detector.approaches[key] = approach{distance, distance < previous.distance}
+	}
+
+	for key := range detector.approaches {
+		if !seen[key] {
+			delete(detector.approaches, key)
+		}
+	}
+}
+
+func (detector *eventDetector) detectPeriapsisPassages(planetHandler *planetHandler) {
+	planets := planetHandler.planets
+
+	for _, planet := range planets {
+		parent := planetHandler.hierarchy.parent(planet, planets)
+		if parent == nil {
+			delete(detector.radialSpeeds, planet)
+			continue
+		}
+
+		// r·v changes from negative to positive when passing the periapsis
+		rx, ry := planet.X-parent.X, planet.Y-parent.Y
+		radialSpeed := rx*(planet.Velocity.X-parent.Velocity.X) + ry*(planet.Velocity.Y-parent.Velocity.Y)
+
+		previous, ok := detector.radialSpeeds[planet]
+		if ok && previous < 0 && radialSpeed >= 0 {
+			planetHandler.emitEvent(eventPeriapsis, planet, parent, math.Sqrt(rx*rx+ry*ry))
+		}
+
+		detector.radialSpeeds[planet] = radialSpeed
+	}
+
+	for planet := range detector.radialSpeeds {
+		if !slices.Contains(planets, planet) {
+			delete(detector.radialSpeeds, planet)
+		}
+	}
+}
+
+// eventLogWriter writes every event as one JSON object per line.
+type eventLogWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newEventLogWriter(path string) (*eventLogWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventLogWriter{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+func (writer *eventLogWriter) write(event simulationEvent) error {
+	return writer.encoder.Encode(event)
+}
+
+func (writer *eventLogWriter) close() error {
+	return writer.file.Close()
+}
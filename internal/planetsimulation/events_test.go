@@ -0,0 +1,179 @@
+package planetsimulation
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// eventRecorder is a subscriber that keeps every event.
+type eventRecorder struct {
+	events []simulationEvent
+}
+
+func (recorder *eventRecorder) record(event simulationEvent) {
+	recorder.events = append(recorder.events, event)
+}
+
+func (recorder *eventRecorder) ofKind(kind eventKind) []simulationEvent {
+	events := []simulationEvent{}
+	for _, event := range recorder.events {
+		if event.Kind == kind {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// runEvents updates planetHandler like the game for n ticks of dt and returns
+// the events.
+func runEvents(planetHandler *planetHandler, n int, dt float64) *eventRecorder {
+	recorder := &eventRecorder{}
+	planetHandler.events.subscribe(recorder.record)
+	planetHandler.timeStep = dt
+	planetHandler.running = true
+
+	for range n {
+		planetHandler.Update()
+	}
+
+	return recorder
+}
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+	calls := []string{}
+	first := bus.subscribe(func(event simulationEvent) { calls = append(calls, "first "+event.Planet) })
+	bus.subscribe(func(event simulationEvent) { calls = append(calls, "second "+event.Planet) })
+
+	bus.emit(simulationEvent{Kind: eventCreated, Planet: "a"})
+	bus.unsubscribe(first)
+	bus.emit(simulationEvent{Kind: eventDeleted, Planet: "b"})
+
+	if want := []string{"first a", "second a", "second b"}; !slices.Equal(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestPlanetEvents(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{})
+	planetHandler := newTestPlanetHandler(a)
+	recorder := &eventRecorder{}
+	planetHandler.events.subscribe(recorder.record)
+
+	b := newTestPlanet("b", 10, 0, 1, 1, vector2{})
+	planetHandler.addPlanet(b)
+	planetHandler.deletePlanet(0)
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("events = %+v, want created and deleted", recorder.events)
+	}
+	if event := recorder.events[0]; event.Kind != eventCreated || event.planet != b {
+		t.Errorf("first event = %+v, want b created", event)
+	}
+	if event := recorder.events[1]; event.Kind != eventDeleted || event.planet != a {
+		t.Errorf("second event = %+v, want a deleted", event)
+	}
+}
+
+func TestCloseApproachEvent(t *testing.T) {
+	// b passes a 12 apart, inside 3 times their combined radius of 6
+	a := newTestPlanet("a", 0, 0, 5, 1, vector2{})
+	b := newTestPlanet("b", -100, 12, 1, 1, vector2{1000, 0})
+	planetHandler := newTestPlanetHandler(a, b)
+
+	events := runEvents(planetHandler, 200, 0.001).ofKind(eventCloseApproach)
+	if len(events) != 1 {
+		t.Fatalf("got %d close approaches, want 1", len(events))
+	}
+	event := events[0]
+	if event.Distance < 12 || event.Distance > 12.1 {
+		t.Errorf("closest distance = %v, want 12", event.Distance)
+	}
+	if event.Planet != "a" || event.Other != "b" {
+		t.Errorf("close approach of %s and %s, want a and b", event.Planet, event.Other)
+	}
+
+	// passing further away is no close approach
+	a = newTestPlanet("a", 0, 0, 5, 1, vector2{})
+	b = newTestPlanet("b", -100, 30, 1, 1, vector2{1000, 0})
+	if events := runEvents(newTestPlanetHandler(a, b), 200, 0.001).ofKind(eventCloseApproach); len(events) != 0 {
+		t.Errorf("got close approaches %+v at a distance of 30", events)
+	}
+}
+
+// newTestOrbit returns a planet that starts at the apoapsis of an orbit
+// around a heavy star, with a period of about 4s.
+func newTestOrbit() (*planetHandler, *Planet, *Planet) {
+	star := newTestPlanet("star", 0, 0, 10, 1e6, vector2{})
+	planet := newTestPlanet("planet", 100, 0, 1, 1, vector2{0, 80})
+	planetHandler := newTestPlanetHandler(star, planet)
+	planetHandler.gravitationalConstant = 1
+
+	return planetHandler, star, planet
+}
+
+func TestPeriapsisEvent(t *testing.T) {
+	planetHandler, star, planet := newTestOrbit()
+
+	// half an orbit and a bit, the periapsis is 47 from the star
+	events := runEvents(planetHandler, 3000, 0.001).ofKind(eventPeriapsis)
+	if len(events) != 1 {
+		t.Fatalf("got %d periapsis passages, want 1", len(events))
+	}
+	event := events[0]
+	if event.planet != planet || event.other != star {
+		t.Errorf("periapsis of %s around %s, want the planet around the star", event.Planet, event.Other)
+	}
+	if event.Distance < 40 || event.Distance > 55 {
+		t.Errorf("periapsis distance = %v, want about 47", event.Distance)
+	}
+	if event.Time < 1.5 || event.Time > 2.5 {
+		t.Errorf("periapsis at %vs, want about half the period of 4s", event.Time)
+	}
+}
+
+func TestEscapeEvent(t *testing.T) {
+	planetHandler, star, planet := newTestOrbit()
+	recorder := runEvents(planetHandler, 20, 0.001)
+	if planetHandler.hierarchy.parent(planet, planetHandler.planets) != star {
+		t.Fatal("the planet doesn't orbit the star")
+	}
+	if len(recorder.ofKind(eventEscaped)) != 0 {
+		t.Fatal("the planet escaped from a bound orbit")
+	}
+
+	// faster than the escape speed of about 141
+	planet.Velocity = vector2{0, 300}
+	for range planetHandler.hierarchy.UpdateEveryNTick + 1 {
+		planetHandler.Update()
+	}
+
+	events := recorder.ofKind(eventEscaped)
+	if len(events) != 1 || events[0].planet != planet || events[0].other != star {
+		t.Errorf("escapes = %+v, want the planet escaping the star", events)
+	}
+}
+
+func TestEventKindsInTheLog(t *testing.T) {
+	kinds := map[eventKind]string{
+		eventCreated:       "created",
+		eventDeleted:       "deleted",
+		eventMerged:        "merged",
+		eventFragmented:    "fragmented",
+		eventEscaped:       "escaped",
+		eventCloseApproach: "close approach",
+		eventPeriapsis:     "periapsis",
+	}
+
+	for kind, name := range kinds {
+		content, err := json.Marshal(simulationEvent{Kind: kind, Planet: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"kind":"` + name + `","tick":0,"time":0,"planet":"a"}`; string(content) != want {
+			t.Errorf("logged %s, want %s", content, want)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package planetsimulation
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"path/filepath"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -15,7 +20,7 @@ type Game struct {
 
 func (game *Game) Update() error {
 	game.simulation.Update()
-	game.controls.Update(game.simulation.planetHandler, game.ui)
+	game.controls.Update(game.simulation, game.ui)
 	if err := game.ui.Update(game.simulation, game.simulation.planetHandler); err != nil {
 		return err
 	}
@@ -32,10 +37,16 @@ func (game *Game) Draw(screen *ebiten.Image) {
 }
 
 func (game *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+	// the screen follows the size of a resized window, the slice is shared
+	// with the simulation
+	if outsideWidth > 0 && outsideHeight > 0 {
+		game.screenSize[0], game.screenSize[1] = outsideWidth, outsideHeight
+	}
+
 	return game.screenSize[0], game.screenSize[1]
 }
 
-func createGame() *Game {
+func createGame(options *options) (*Game, error) {
 	game := Game{
 		screenSize: make([]int, 2),
 		controls:   newControls(),
@@ -43,18 +54,51 @@ func createGame() *Game {
 	}
 
 	// Window Setup
-	ebiten.SetWindowTitle("Planet Simulation")
-	ebiten.SetFullscreen(true)
-	game.screenSize[0], game.screenSize[1] = ebiten.Monitor().Size()
+	ebiten.SetWindowTitle(options.title)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	if options.windowed {
+		ebiten.SetWindowSize(options.width, options.height)
+		game.screenSize[0], game.screenSize[1] = options.width, options.height
+	} else {
+		ebiten.SetFullscreen(true)
+		game.screenSize[0], game.screenSize[1] = ebiten.Monitor().Size()
+	}
 
 	// new simulation
-	game.simulation = newSimulation(game.screenSize)
+	game.simulation = newSimulation(game.screenSize, options.dataDirectory)
+	game.simulation.planetHandler.events.subscribe(game.ui.logEvent)
+	game.ui.eventLogFilePath = filepath.Join(game.simulation.dataDirectory, "events.jsonl")
+	if err := options.apply(game.simulation); err != nil {
+		return nil, err
+	}
 
-	return &game
+	return &game, nil
 }
 
-func Start() {
-	if err := ebiten.RunGame(createGame()); err != nil {
-		log.Fatal(err)
+// Start runs the game with the command line flags in args, without the
+// program name, and returns the exit code of the process.
+func Start(args []string, output io.Writer) int {
+	options, err := parseOptions(args, output)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(output, err)
+		return 2
+	}
+
+	game, err := createGame(options)
+	if err != nil {
+		fmt.Fprintln(output, err)
+		return 2
+	}
+	if err := ebiten.RunGame(game); err != nil {
+		log.Print(err)
+		return 1
 	}
+	if err := game.simulation.autosave.shutdown(); err != nil {
+		log.Printf("Failed to mark the clean exit: %v", err)
+	}
+
+	return 0
 }
@@ -0,0 +1,239 @@
+package planetsimulation
+
+import (
+	"maps"
+	"math"
+	"slices"
+)
+
+// hierarchy keeps track of which body every planet is gravitationally bound to
+// (star -> planet -> moon). A planet without a parent is a root of the tree.
+type hierarchy struct {
+	parents map[*Planet]*Planet
+	// the planets bound to every planet, built once per update from the
+	// planets of that update
+	childMap         map[*Planet][]*Planet
+	planets          []*Planet
+	planetCount      int
+	TickCount        int
+	UpdateEveryNTick int
+}
+
+type orbit struct {
+	parent        *Planet
+	distance      float64
+	speed         float64
+	semiMajorAxis float64
+	eccentricity  float64
+	period        float64
+	isBound       bool
+}
+
+func newHierarchy() *hierarchy {
+	return &hierarchy{
+		parents:          make(map[*Planet]*Planet),
+		UpdateEveryNTick: 10,
+	}
+}
+
+// hillRadius approximates the sphere of influence of p around its parent
+// using the current distance instead of the semi-major axis.
+func hillRadius(p *Planet, parent *Planet) float64 {
+	if parent == nil {
+		return math.Inf(1)
+	}
+
+	_, _, distance, _ := overlapsCircle(p.X, parent.X, p.Y, parent.Y, p.Radius, parent.Radius)
+
+	return distance * math.Cbrt(p.Mass/(3*parent.Mass))
+}
+
+// isBound reports whether p would stay on a closed orbit around other if they
+// were the only two bodies, i.e. their specific orbital energy is negative.
+func isBound(p *Planet, other *Planet, gravitationalConstant float64) bool {
+	_, _, distance, _ := overlapsCircle(p.X, other.X, p.Y, other.Y, p.Radius, other.Radius)
+	vx := p.Velocity.X - other.Velocity.X
+	vy := p.Velocity.Y - other.Velocity.Y
+
+	energy := (vx*vx+vy*vy)/2 - gravitationalConstant*(p.Mass+other.Mass)/distance
+
+	return energy < 0
+}
+
+func (hierarchy *hierarchy) update(planetHandler *planetHandler) {
+	// always rebuild when planets were added or removed
+	if hierarchy.TickCount < hierarchy.UpdateEveryNTick && hierarchy.planetCount == len(planetHandler.planets) {
+		hierarchy.TickCount++
+		return
+	}
+
+	previousParents := maps.Clone(hierarchy.parents)
+	hierarchy.compute(planetHandler.planets, planetHandler.gravitationalConstant)
+	hierarchy.planets = slices.Clone(planetHandler.planets)
+	hierarchy.childMap = hierarchy.buildChildMap(hierarchy.planets)
+
+	// planets that lost their parent escaped from it
+	for _, planet := range planetHandler.planets {
+		previousParent, ok := previousParents[planet]
+		if ok && hierarchy.parent(planet, planetHandler.planets) == nil && slices.Contains(planetHandler.planets, previousParent) {
+			planetHandler.emitEvent(eventEscaped, planet, previousParent, 0)
+		}
+	}
+
+	hierarchy.planetCount = len(planetHandler.planets)
+	hierarchy.TickCount = 0
+}
+
+func (hierarchy *hierarchy) compute(planets []*Planet, gravitationalConstant float64) {
+	clear(hierarchy.parents)
+
+	// heaviest planets first so that every possible parent is already placed
+	sortedPlanets := slices.Clone(planets)
+	slices.SortStableFunc(sortedPlanets, func(a *Planet, b *Planet) int {
+		if a.Mass > b.Mass {
+			return -1
+		}
+		if a.Mass < b.Mass {
+			return 1
+		}
+		return 0
+	})
+
+	for i, p := range sortedPlanets {
+		var parent *Planet
+		smallestRadius := math.Inf(1)
+
+		for _, candidate := range sortedPlanets[:i] {
+			if candidate.Mass <= p.Mass {
+				continue
+			}
+
+			// roots have an unbounded sphere of influence
+			radius := hillRadius(candidate, hierarchy.parents[candidate])
+
+			_, _, distance, _ := overlapsCircle(p.X, candidate.X, p.Y, candidate.Y, p.Radius, candidate.Radius)
+			if distance > radius {
+				continue
+			}
+
+			// the innermost sphere of influence wins
+			if radius < smallestRadius || parent == nil {
+				if isBound(p, candidate, gravitationalConstant) {
+					parent = candidate
+					smallestRadius = radius
+				}
+			}
+		}
+
+		if parent != nil {
+			hierarchy.parents[p] = parent
+		}
+	}
+}
+
+// parent returns the body p is bound to or nil if p is a root or its parent
+// no longer exists.
+func (hierarchy *hierarchy) parent(p *Planet, planets []*Planet) *Planet {
+	parent, ok := hierarchy.parents[p]
+	if !ok || !slices.Contains(planets, parent) {
+		return nil
+	}
+
+	return parent
+}
+
+func (hierarchy *hierarchy) roots(planets []*Planet) []*Planet {
+	roots := make([]*Planet, 0)
+
+	for _, planet := range planets {
+		if hierarchy.parent(planet, planets) == nil {
+			roots = append(roots, planet)
+		}
+	}
+
+	return roots
+}
+
+// buildChildMap maps every planet to the planets bound to it, in the order
+// of planets.
+func (hierarchy *hierarchy) buildChildMap(planets []*Planet) map[*Planet][]*Planet {
+	exists := make(map[*Planet]bool, len(planets))
+	for _, planet := range planets {
+		exists[planet] = true
+	}
+
+	childMap := make(map[*Planet][]*Planet)
+	for _, planet := range planets {
+		if parent, ok := hierarchy.parents[planet]; ok && exists[parent] {
+			childMap[parent] = append(childMap[parent], planet)
+		}
+	}
+
+	return childMap
+}
+
+// children returns the planets bound to each planet. The map of the last
+// update is reused unless planets changed since.
+func (hierarchy *hierarchy) children(planets []*Planet) map[*Planet][]*Planet {
+	if hierarchy.childMap != nil && slices.Equal(hierarchy.planets, planets) {
+		return hierarchy.childMap
+	}
+
+	return hierarchy.buildChildMap(planets)
+}
+
+// barycenter returns the centre of mass of p and everything orbiting it.
+func (hierarchy *hierarchy) barycenter(p *Planet, planets []*Planet) (float64, float64) {
+	x, y, mass := 0.0, 0.0, 0.0
+	children := hierarchy.children(planets)
+
+	system := []*Planet{p}
+	for i := 0; i < len(system); i++ {
+		current := system[i]
+		x += current.X * current.Mass
+		y += current.Y * current.Mass
+		mass += current.Mass
+
+		system = append(system, children[current]...)
+	}
+
+	return x / mass, y / mass
+}
+
+// orbit calculates the two body orbital elements of p around its parent.
+func (hierarchy *hierarchy) orbit(p *Planet, planets []*Planet, gravitationalConstant float64) (orbit, bool) {
+	parent := hierarchy.parent(p, planets)
+	if parent == nil {
+		return orbit{}, false
+	}
+
+	rx, ry := p.X-parent.X, p.Y-parent.Y
+	vx, vy := p.Velocity.X-parent.Velocity.X, p.Velocity.Y-parent.Velocity.Y
+	distance := math.Sqrt(rx*rx + ry*ry)
+	speed := math.Sqrt(vx*vx + vy*vy)
+	mu := gravitationalConstant * (p.Mass + parent.Mass)
+
+	// eccentricity vector e = ((v² - mu/r) * r - (r·v) * v) / mu
+	radialSpeed := rx*vx + ry*vy
+	ex := ((speed*speed-mu/distance)*rx - radialSpeed*vx) / mu
+	ey := ((speed*speed-mu/distance)*ry - radialSpeed*vy) / mu
+
+	energy := speed*speed/2 - mu/distance
+	semiMajorAxis := -mu / (2 * energy)
+
+	result := orbit{
+		parent:        parent,
+		distance:      distance,
+		speed:         speed,
+		semiMajorAxis: semiMajorAxis,
+		eccentricity:  math.Sqrt(ex*ex + ey*ey),
+		period:        math.Inf(1),
+		isBound:       energy < 0,
+	}
+
+	if result.isBound {
+		result.period = 2 * math.Pi * math.Sqrt(math.Pow(semiMajorAxis, 3)/mu)
+	}
+
+	return result, true
+}
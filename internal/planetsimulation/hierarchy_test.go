@@ -0,0 +1,113 @@
+package planetsimulation
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// newTestSystem returns a star with a planet and the bodies around them. The
+// Hill radius of the planet is about 69.
+func newTestSystem() (*planetHandler, map[string]*Planet) {
+	circular := func(mass float64, distance float64) float64 {
+		return math.Sqrt(mass / distance)
+	}
+	planetSpeed := circular(1e6, 1000)
+
+	bodies := []*Planet{
+		newTestPlanet("star", 0, 0, 10, 1e6, vector2{}),
+		newTestPlanet("planet", 1000, 0, 3, 1e3, vector2{0, planetSpeed}),
+		// inside the Hill sphere of the planet and bound to it
+		newTestPlanet("moon", 1020, 0, 1, 1, vector2{0, planetSpeed + circular(1e3, 20)}),
+		// inside the Hill sphere but too fast for the planet
+		newTestPlanet("passing", 980, 0, 1, 1, vector2{0, planetSpeed + 12}),
+		// outside the Hill sphere of the planet
+		newTestPlanet("neighbour", 1200, 0, 1, 1, vector2{0, circular(1e6, 1200)}),
+		// too fast for the star
+		newTestPlanet("rogue", 5000, 0, 1, 1, vector2{0, 100}),
+	}
+	planetHandler := newTestPlanetHandler(bodies...)
+	planetHandler.gravitationalConstant = 1
+
+	named := map[string]*Planet{}
+	for _, body := range bodies {
+		named[body.Name] = body
+	}
+
+	return planetHandler, named
+}
+
+func TestHierarchyParents(t *testing.T) {
+	planetHandler, named := newTestSystem()
+	hierarchy := planetHandler.hierarchy
+	hierarchy.update(planetHandler)
+
+	want := map[string]string{
+		"star":      "",
+		"planet":    "star",
+		"moon":      "planet",
+		"passing":   "star",
+		"neighbour": "star",
+		"rogue":     "",
+	}
+	for name, parentName := range want {
+		parent := hierarchy.parent(named[name], planetHandler.planets)
+		if parent == nil && parentName != "" || parent != nil && parent.Name != parentName {
+			t.Errorf("parent of %s = %v, want %q", name, parent, parentName)
+		}
+	}
+
+	roots := hierarchy.roots(planetHandler.planets)
+	if !slices.Equal(roots, []*Planet{named["star"], named["rogue"]}) {
+		t.Errorf("roots = %v, want the star and the rogue", roots)
+	}
+	children := hierarchy.children(planetHandler.planets)
+	if want := []*Planet{named["planet"], named["passing"], named["neighbour"]}; !slices.Equal(children[named["star"]], want) {
+		t.Errorf("children of the star = %v, want %v", children[named["star"]], want)
+	}
+}
+
+func TestHierarchyBarycenter(t *testing.T) {
+	planetHandler, named := newTestSystem()
+	hierarchy := planetHandler.hierarchy
+	hierarchy.update(planetHandler)
+
+	planet, moon := named["planet"], named["moon"]
+	x, y := hierarchy.barycenter(planet, planetHandler.planets)
+	wantX := (planet.X*planet.Mass + moon.X*moon.Mass) / (planet.Mass + moon.Mass)
+	if math.Abs(x-wantX) > 1e-9 || y != 0 {
+		t.Errorf("barycenter of the planet = %v, %v, want %v, 0", x, y, wantX)
+	}
+
+	// a removed moon doesn't count before the next update
+	planets := slices.DeleteFunc(slices.Clone(planetHandler.planets), func(p *Planet) bool { return p == moon })
+	if x, _ := hierarchy.barycenter(planet, planets); x != planet.X {
+		t.Errorf("barycenter without the moon = %v, want %v", x, planet.X)
+	}
+	if children := hierarchy.children(planets)[planet]; len(children) != 0 {
+		t.Errorf("children without the moon = %v", children)
+	}
+}
+
+func TestHierarchyUpdatesChildren(t *testing.T) {
+	planetHandler, named := newTestSystem()
+	hierarchy := planetHandler.hierarchy
+	hierarchy.update(planetHandler)
+
+	// the moon escapes, the hierarchy follows at its next rebuild
+	named["moon"].Velocity.Y += 100
+	for range hierarchy.UpdateEveryNTick + 1 {
+		hierarchy.update(planetHandler)
+	}
+	if children := hierarchy.children(planetHandler.planets)[named["planet"]]; len(children) != 0 {
+		t.Errorf("children of the planet = %v after the moon escaped", children)
+	}
+
+	// added planets rebuild it right away
+	moon := newTestPlanet("new moon", 1000, 20, 1, 1, vector2{-math.Sqrt(1e3 / 20), named["planet"].Velocity.Y})
+	planetHandler.addPlanet(moon)
+	hierarchy.update(planetHandler)
+	if children := hierarchy.children(planetHandler.planets)[named["planet"]]; !slices.Equal(children, []*Planet{moon}) {
+		t.Errorf("children of the planet = %v, want the new moon", children)
+	}
+}
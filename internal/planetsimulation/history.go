@@ -0,0 +1,314 @@
+package planetsimulation
+
+import (
+	"slices"
+	"time"
+)
+
+// command is an edit that can be reverted and applied again.
+type command interface {
+	undo(sim *simulation)
+	redo(sim *simulation)
+}
+
+// history keeps the last maxSize commands. Commands are recorded when the
+// edit is made, undo and redo are applied in the simulation update.
+type history struct {
+	undoStack  []command
+	redoStack  []command
+	maxSize    int
+	shouldUndo bool
+	shouldRedo bool
+	// a planet was edited, undone or redone since the last update
+	planetEdited bool
+}
+
+func newHistory() *history {
+	return &history{
+		maxSize: 100,
+	}
+}
+
+func (history *history) push(command command) {
+	history.undoStack = append(history.undoStack, command)
+	if len(history.undoStack) > history.maxSize {
+		history.undoStack = slices.Delete(history.undoStack, 0, len(history.undoStack)-history.maxSize)
+	}
+	history.redoStack = history.redoStack[:0]
+}
+
+func (history *history) clear() {
+	history.undoStack = history.undoStack[:0]
+	history.redoStack = history.redoStack[:0]
+}
+
+func (history *history) canUndo() bool {
+	return len(history.undoStack) > 0
+}
+
+func (history *history) canRedo() bool {
+	return len(history.redoStack) > 0
+}
+
+func (sim *simulation) handleHistory() {
+	history := sim.history
+
+	if history.shouldUndo && history.canUndo() {
+		command := history.undoStack[len(history.undoStack)-1]
+		history.undoStack = history.undoStack[:len(history.undoStack)-1]
+		command.undo(sim)
+		history.redoStack = append(history.redoStack, command)
+		_, isEdit := command.(*planetEditCommand)
+		history.planetEdited = history.planetEdited || isEdit
+	}
+
+	if history.shouldRedo && history.canRedo() {
+		command := history.redoStack[len(history.redoStack)-1]
+		history.redoStack = history.redoStack[:len(history.redoStack)-1]
+		command.redo(sim)
+		history.undoStack = append(history.undoStack, command)
+		_, isEdit := command.(*planetEditCommand)
+		history.planetEdited = history.planetEdited || isEdit
+	}
+
+	history.shouldUndo = false
+	history.shouldRedo = false
+
+	// the twin would count the edit as divergence
+	if history.planetEdited && sim.chaosAnalysis.isRunning {
+		sim.chaosAnalysis.stop("Stopped, a planet was edited")
+	}
+	history.planetEdited = false
+}
+
+// shiftIndex moves an index into the planets after a planet was inserted at
+// (delta 1) or removed from (delta -1) position at.
+func shiftIndex(index *int, at int, delta int) {
+	if *index > at || *index == at && delta > 0 {
+		*index += delta
+	}
+}
+
+// insertPlanet puts a removed planet back at index, keeping its traces.
+func (handler *planetHandler) insertPlanet(index int, planet *Planet) {
+	index = min(index, len(handler.planets))
+	handler.planets = slices.Insert(handler.planets, index, planet)
+	shiftIndex(&handler.selectedPlanet.index, index, 1)
+	shiftIndex(&handler.focusedPlanet.index, index, 1)
+
+	// the camera may have moved since it was removed
+	planet.Offset = handler.planetsOffset
+	planet.setPosition(planet.X, planet.Y)
+	handler.emitEvent(eventCreated, planet, nil, 0)
+}
+
+type deletePlanetCommand struct {
+	planet     *Planet
+	index      int
+	isSelected bool
+}
+
+// deletePlanet deletes the planet at index so it can be undone.
+func (history *history) deletePlanet(planetHandler *planetHandler, index int) {
+	history.push(&deletePlanetCommand{
+		planet:     planetHandler.planets[index],
+		index:      index,
+		isSelected: planetHandler.selectedPlanet.isSelected && planetHandler.selectedPlanet.index == index,
+	})
+	planetHandler.deletePlanet(index)
+}
+
+func (command *deletePlanetCommand) undo(sim *simulation) {
+	planetHandler := sim.planetHandler
+
+	// still waiting for its removal
+	if i := slices.Index(planetHandler.planets, command.planet); i >= 0 {
+		planetHandler.planetsToRemove = slices.DeleteFunc(planetHandler.planetsToRemove, func(index int) bool {
+			return index == i
+		})
+		return
+	}
+
+	planetHandler.insertPlanet(command.index, command.planet)
+	if command.isSelected {
+		planetHandler.selectPlanet(min(command.index, len(planetHandler.planets)-1))
+	}
+}
+
+func (command *deletePlanetCommand) redo(sim *simulation) {
+	planetHandler := sim.planetHandler
+	if i := slices.Index(planetHandler.planets, command.planet); i >= 0 {
+		command.index = i
+		command.isSelected = planetHandler.selectedPlanet.isSelected && planetHandler.selectedPlanet.index == i
+		planetHandler.deletePlanet(i)
+	}
+}
+
+// resetCommand keeps everything a reset removes.
+type resetCommand struct {
+	planets           []*Planet
+	centerOfMassTrace []tracePoint
+	selectedPlanet    selectedPlanet
+	focusedPlanet     focusedPlanet
+	planetCounter     int
+	offset            [2]float64
+	frame             referenceFrame
+}
+
+func newResetCommand(planetHandler *planetHandler) *resetCommand {
+	command := &resetCommand{
+		planets:           slices.Clone(planetHandler.planets),
+		selectedPlanet:    planetHandler.selectedPlanet,
+		focusedPlanet:     planetHandler.focusedPlanet,
+		planetCounter:     planetHandler.planetCounter,
+		offset:            [2]float64{planetHandler.planetsOffset[0], planetHandler.planetsOffset[1]},
+		frame:             planetHandler.frame,
+		centerOfMassTrace: slices.Clone(planetHandler.centerOfMassTrace),
+	}
+
+	return command
+}
+
+// undo puts the removed planets in front of the ones added since the reset.
+func (command *resetCommand) undo(sim *simulation) {
+	planetHandler := sim.planetHandler
+
+	planetHandler.planetsOffset[0], planetHandler.planetsOffset[1] = command.offset[0], command.offset[1]
+	for _, planet := range planetHandler.planets {
+		planet.setPosition(planet.X, planet.Y)
+	}
+	for i, planet := range command.planets {
+		planetHandler.insertPlanet(i, planet)
+	}
+
+	planetHandler.selectedPlanet = command.selectedPlanet
+	planetHandler.focusedPlanet = command.focusedPlanet
+	planetHandler.planetCounter = max(planetHandler.planetCounter, command.planetCounter)
+	planetHandler.frame = command.frame
+	planetHandler.centerOfMassTrace = slices.Clone(command.centerOfMassTrace)
+}
+
+func (command *resetCommand) redo(sim *simulation) {
+	*command = *newResetCommand(sim.planetHandler)
+	sim.reset()
+}
+
+type deletePlanetPresetCommand struct {
+	// index into the presets of the user
+	index  int
+	preset *planetPreset
+}
+
+// deletePlanetPreset removes the preset at index i of all.
+func (history *history) deletePlanetPreset(planetPresets *planetPresets, i int) {
+	index := i - len(planetPresets.builtInPresets)
+	if index < 0 {
+		return
+	}
+
+	history.push(&deletePlanetPresetCommand{index, planetPresets.presets[index]})
+	planetPresets.deletePreset(i)
+}
+
+func (command *deletePlanetPresetCommand) undo(sim *simulation) {
+	planetPresets := sim.planetHandler.planetPresets
+	planetPresets.presets = slices.Insert(planetPresets.presets, min(command.index, len(planetPresets.presets)), command.preset)
+	planetPresets.saveToFile()
+}
+
+func (command *deletePlanetPresetCommand) redo(sim *simulation) {
+	planetPresets := sim.planetHandler.planetPresets
+	if i := slices.Index(planetPresets.presets, command.preset); i >= 0 {
+		planetPresets.deletePreset(len(planetPresets.builtInPresets) + i)
+	}
+}
+
+type deleteSimulationPresetCommand struct {
+	// index into the presets of the user
+	index  int
+	preset *simulationPreset
+}
+
+// deleteSimulationPreset removes the preset at index i of all.
+func (history *history) deleteSimulationPreset(simulationPresets *simulationPresets, i int) {
+	index := i - len(simulationPresets.builtInPresets)
+	if index < 0 {
+		return
+	}
+
+	history.push(&deleteSimulationPresetCommand{index, simulationPresets.Presets[index]})
+	simulationPresets.removeSimulationPreset(i)
+}
+
+func (command *deleteSimulationPresetCommand) undo(sim *simulation) {
+	presets := sim.simulationPresets
+	presets.Presets = slices.Insert(presets.Presets, min(command.index, len(presets.Presets)), command.preset)
+	presets.saveToFile()
+}
+
+func (command *deleteSimulationPresetCommand) redo(sim *simulation) {
+	presets := sim.simulationPresets
+	if i := slices.Index(presets.Presets, command.preset); i >= 0 {
+		presets.removeSimulationPreset(len(presets.builtInPresets) + i)
+	}
+}
+
+// planetEditCommand changes a single property of a planet. Edits of the
+// same property in quick succession, like dragging a slider or typing a
+// name, are merged into one command.
+type planetEditCommand struct {
+	planet   *Planet
+	property string
+	setOld   func(p *Planet)
+	setNew   func(p *Planet)
+	editedAt time.Time
+}
+
+const mergeEditsWithin = time.Second
+
+// editPlanet applies setNew to planet and records it, setOld has to restore
+// the value before the edit.
+func (history *history) editPlanet(planet *Planet, property string, setOld func(p *Planet), setNew func(p *Planet)) {
+	setNew(planet)
+	history.planetEdited = true
+
+	if len(history.undoStack) > 0 {
+		last, ok := history.undoStack[len(history.undoStack)-1].(*planetEditCommand)
+		if ok && last.planet == planet && last.property == property && time.Since(last.editedAt) < mergeEditsWithin {
+			last.setNew = setNew
+			last.editedAt = time.Now()
+			history.redoStack = history.redoStack[:0]
+			return
+		}
+	}
+
+	history.push(&planetEditCommand{
+		planet:   planet,
+		property: property,
+		setOld:   setOld,
+		setNew:   setNew,
+		editedAt: time.Now(),
+	})
+}
+
+// editPlanetColor changes channels of the colour of planet. Edits of all
+// channels are merged into one command, so the whole colour is recorded.
+func (history *history) editPlanetColor(planet *Planet, colorDelta ColorDelta) {
+	oldColor := planet.Color
+	newColor := colorDelta.apply(planet.Color)
+	history.editPlanet(planet, "color", func(p *Planet) {
+		p.Color = oldColor
+		p.updateImage()
+	}, func(p *Planet) {
+		p.Color = newColor
+		p.updateImage()
+	})
+}
+
+func (command *planetEditCommand) undo(sim *simulation) {
+	command.setOld(command.planet)
+}
+
+func (command *planetEditCommand) redo(sim *simulation) {
+	command.setNew(command.planet)
+}
@@ -0,0 +1,185 @@
+package planetsimulation
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+)
+
+// newTestHistory returns a simulation with the planets that undo and redo
+// can be applied to.
+func newTestHistory(planets ...*Planet) *simulation {
+	return &simulation{
+		planetHandler: newTestPlanetHandler(planets...),
+		history:       newHistory(),
+		chaosAnalysis: newChaosAnalysis(),
+	}
+}
+
+func undo(sim *simulation) {
+	sim.history.shouldUndo = true
+	sim.handleHistory()
+}
+
+func redo(sim *simulation) {
+	sim.history.shouldRedo = true
+	sim.handleHistory()
+}
+
+// stepTraces steps the planets until each has a few traces.
+func stepTraces(planetHandler *planetHandler) {
+	for range 20 {
+		planetHandler.step(0.001)
+	}
+}
+
+func TestUndoDeletePlanet(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{10, 0})
+	b := newTestPlanet("b", 100, 0, 1, 1, vector2{0, 10})
+	c := newTestPlanet("c", 200, 0, 1, 1, vector2{0, -10})
+	sim := newTestHistory(a, b, c)
+	planetHandler := sim.planetHandler
+	stepTraces(planetHandler)
+	traces := slices.Clone(b.traces)
+	if len(traces) == 0 {
+		t.Fatal("b has no traces")
+	}
+
+	planetHandler.selectPlanet(1)
+	sim.history.deletePlanet(planetHandler, 1)
+	planetHandler.handlePlanetDeletion()
+	if slices.Contains(planetHandler.planets, b) || planetHandler.selectedPlanet.isSelected {
+		t.Fatalf("b is still there or selected after the deletion")
+	}
+
+	undo(sim)
+	if !slices.Equal(planetHandler.planets, []*Planet{a, b, c}) {
+		t.Fatalf("planets after undo = %v, want a, b, c", planetHandler.planets)
+	}
+	if !slices.Equal(b.traces, traces) {
+		t.Errorf("traces after undo = %v, want %v", b.traces, traces)
+	}
+	if selected := planetHandler.selectedPlanet; !selected.isSelected || selected.index != 1 {
+		t.Errorf("selection after undo = %+v, want b", selected)
+	}
+
+	redo(sim)
+	planetHandler.handlePlanetDeletion()
+	if !slices.Equal(planetHandler.planets, []*Planet{a, c}) || planetHandler.selectedPlanet.isSelected {
+		t.Fatalf("planets after redo = %v, want a, c without a selection", planetHandler.planets)
+	}
+
+	undo(sim)
+	if !slices.Equal(planetHandler.planets, []*Planet{a, b, c}) || planetHandler.selectedPlanet.index != 1 {
+		t.Errorf("planets after the second undo = %v, selection %+v", planetHandler.planets, planetHandler.selectedPlanet)
+	}
+}
+
+func TestUndoDeleteKeepsOtherSelection(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{})
+	b := newTestPlanet("b", 100, 0, 1, 1, vector2{})
+	c := newTestPlanet("c", 200, 0, 1, 1, vector2{})
+	sim := newTestHistory(a, b, c)
+	planetHandler := sim.planetHandler
+
+	// c stays selected while a is deleted and restored in front of it
+	planetHandler.selectPlanet(2)
+	sim.history.deletePlanet(planetHandler, 0)
+	planetHandler.handlePlanetDeletion()
+	if planetHandler.planets[planetHandler.selectedPlanet.index] != c {
+		t.Fatalf("c isn't selected after the deletion")
+	}
+
+	undo(sim)
+	if planetHandler.planets[planetHandler.selectedPlanet.index] != c {
+		t.Errorf("c isn't selected after undo")
+	}
+}
+
+func TestUndoReset(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{10, 0})
+	b := newTestPlanet("b", 100, 0, 1, 1, vector2{0, 10})
+	sim := newTestHistory(a, b)
+	planetHandler := sim.planetHandler
+	stepTraces(planetHandler)
+	traces := slices.Clone(a.traces)
+	centerOfMassTrace := slices.Clone(planetHandler.centerOfMassTrace)
+	planetHandler.selectPlanet(1)
+
+	sim.history.push(newResetCommand(planetHandler))
+	sim.reset()
+	if len(planetHandler.planets) != 0 || planetHandler.selectedPlanet.isSelected {
+		t.Fatalf("reset kept %d planets", len(planetHandler.planets))
+	}
+
+	// a planet added after the reset stays behind the restored ones
+	d := newTestPlanet("d", 50, 50, 1, 1, vector2{})
+	planetHandler.addPlanet(d)
+
+	undo(sim)
+	if !slices.Equal(planetHandler.planets, []*Planet{a, b, d}) {
+		t.Fatalf("planets after undo = %v, want a, b, d", planetHandler.planets)
+	}
+	if !slices.Equal(a.traces, traces) || !slices.Equal(planetHandler.centerOfMassTrace, centerOfMassTrace) {
+		t.Errorf("traces aren't restored by undo")
+	}
+	if selected := planetHandler.selectedPlanet; !selected.isSelected || planetHandler.planets[selected.index] != b {
+		t.Errorf("selection after undo = %+v, want b", selected)
+	}
+
+	redo(sim)
+	if len(planetHandler.planets) != 0 {
+		t.Fatalf("redo kept %d planets", len(planetHandler.planets))
+	}
+
+	undo(sim)
+	if !slices.Equal(planetHandler.planets, []*Planet{a, b, d}) || planetHandler.planets[planetHandler.selectedPlanet.index] != b {
+		t.Errorf("planets after the second undo = %v, selection %+v", planetHandler.planets, planetHandler.selectedPlanet)
+	}
+}
+
+func TestUndoEditPlanet(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{})
+	sim := newTestHistory(a)
+
+	// typing a name is merged into one edit
+	for _, name := range []string{"E", "Ea", "Earth"} {
+		sim.history.editPlanet(a, "name", func(p *Planet) { p.Name = "a" }, func(p *Planet) { p.Name = name })
+	}
+	if len(sim.history.undoStack) != 1 {
+		t.Fatalf("%d commands for one edit", len(sim.history.undoStack))
+	}
+
+	undo(sim)
+	if a.Name != "a" {
+		t.Errorf("name after undo = %q, want a", a.Name)
+	}
+	redo(sim)
+	if a.Name != "Earth" {
+		t.Errorf("name after redo = %q, want Earth", a.Name)
+	}
+}
+
+func TestUndoEditPlanetColor(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{})
+	a.Color = color.NRGBA{10, 20, 30, 255}
+	sim := newTestHistory(a)
+
+	// dragging the red and then the green slider
+	red, green := uint8(100), uint8(200)
+	sim.history.editPlanetColor(a, ColorDelta{R: &red})
+	sim.history.editPlanetColor(a, ColorDelta{G: &green})
+	want := color.NRGBA{100, 200, 30, 255}
+	if a.Color != want {
+		t.Fatalf("color = %v, want %v", a.Color, want)
+	}
+
+	undo(sim)
+	if original := (color.NRGBA{10, 20, 30, 255}); a.Color != original {
+		t.Errorf("color after undo = %v, want %v", a.Color, original)
+	}
+	redo(sim)
+	if a.Color != want {
+		t.Errorf("color after redo = %v, want %v", a.Color, want)
+	}
+}
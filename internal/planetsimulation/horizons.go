@@ -0,0 +1,475 @@
+package planetsimulation
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"PlanetSimulation/internal/physics"
+)
+
+const (
+	astronomicalUnit = 149597870.7 // km
+	secondsPerDay    = 86400.0
+	// obliquity of the ecliptic at J2000.0
+	obliquity = 23.439281 * math.Pi / 180
+)
+
+// ephemeris is the first state vector of a Horizons vector table in AU and
+// AU/day, rotated into the ecliptic frame.
+type ephemeris struct {
+	name      string
+	id        int
+	center    string
+	centerID  int
+	julianDay float64
+	position  [3]float64
+	velocity  [3]float64
+	// AU³/day², 0 if unknown
+	gm float64
+}
+
+// majorBody is used for bodies whose header doesn't contain their GM and for
+// the centre body, which isn't part of the table.
+type majorBody struct {
+	gm    float64 // km³/s²
+	color color.NRGBA
+}
+
+var majorBodies = map[int]majorBody{
+	10:  {132712440041.94, SetColor(255, 220, 80, 255)},
+	199: {22031.87, SetColor(170, 160, 150, 255)},
+	299: {324858.59, SetColor(230, 200, 140, 255)},
+	399: {398600.44, SetColor(80, 140, 255, 255)},
+	301: {4902.80, SetColor(200, 200, 200, 255)},
+	499: {42828.37, SetColor(230, 100, 60, 255)},
+	599: {126686531.9, SetColor(220, 170, 120, 255)},
+	699: {37931206.2, SetColor(230, 210, 150, 255)},
+	799: {5793951.3, SetColor(150, 220, 230, 255)},
+	899: {6835099.5, SetColor(90, 120, 255, 255)},
+	999: {869.6, SetColor(200, 170, 150, 255)},
+	// planetary system barycentres
+	1: {22031.87, SetColor(170, 160, 150, 255)},
+	2: {324858.59, SetColor(230, 200, 140, 255)},
+	3: {403503.24, SetColor(80, 140, 255, 255)},
+	4: {42828.38, SetColor(230, 100, 60, 255)},
+	5: {126712764.1, SetColor(220, 170, 120, 255)},
+	6: {37940584.8, SetColor(230, 210, 150, 255)},
+	7: {5794556.4, SetColor(150, 220, 230, 255)},
+	8: {6836527.1, SetColor(90, 120, 255, 255)},
+	9: {975.5, SetColor(200, 170, 150, 255)},
+}
+
+var (
+	horizonsBodyPattern  = regexp.MustCompile(`^\s*(Target|Center) body name\s*:\s*([^{]*)`)
+	horizonsIDPattern    = regexp.MustCompile(`^(.*?)\s*\(([^)]*)\)$`)
+	horizonsUnitsPattern = regexp.MustCompile(`^\s*Output units\s*:\s*([A-Z]+-[A-Z]+)`)
+	horizonsFramePattern = regexp.MustCompile(`^\s*(Coordinate system|Reference frame|Reference plane)\s*:\s*(.*)`)
+	// "GM, km^3/s^2 = ..." and "GM (km^3/s^2) = ...", but not "GM 1-sigma"
+	horizonsGMPattern     = regexp.MustCompile(`GM,? ?\(?km\^3/s\^2\)?\s*=\s*([-+0-9.eE]+)`)
+	horizonsVectorPattern = regexp.MustCompile(`\b(VX|VY|VZ|X|Y|Z)\s*=\s*([-+]?[0-9.]+(?:[eE][-+]?\d+)?)`)
+)
+
+// parseHorizons reads a Horizons vector table (text or CSV format). Only the
+// first entry between $$SOE and $$EOE is used.
+func parseHorizons(content []byte) (ephemeris, error) {
+	result := ephemeris{}
+	units := ""
+	isEcliptic := false
+	// the last line with commas before $$SOE is the header of the CSV format
+	csvHeader := []string{}
+	entry := []string{}
+	entryLine := 0
+	inTable, hasTable := false, false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+
+		if inTable {
+			if trimmed == "$$EOE" {
+				inTable = false
+				continue
+			}
+			// an entry starts with its Julian day and ends with the next one
+			if len(entry) > 0 && startsWithNumber(trimmed) {
+				break
+			}
+			if len(entry) == 0 {
+				entryLine = line
+			}
+			entry = append(entry, trimmed)
+			continue
+		}
+
+		switch {
+		case trimmed == "$$SOE":
+			inTable, hasTable = true, true
+		case horizonsBodyPattern.MatchString(text):
+			match := horizonsBodyPattern.FindStringSubmatch(text)
+			name, id := parseHorizonsBody(match[2])
+			if match[1] == "Target" {
+				result.name, result.id = name, id
+			} else {
+				result.center, result.centerID = name, id
+			}
+		case horizonsUnitsPattern.MatchString(text):
+			units = horizonsUnitsPattern.FindStringSubmatch(text)[1]
+		case horizonsFramePattern.MatchString(text):
+			plane := strings.ToLower(horizonsFramePattern.FindStringSubmatch(text)[2])
+			isEcliptic = isEcliptic || strings.Contains(plane, "ecliptic")
+		case horizonsGMPattern.MatchString(text) && result.gm == 0:
+			gm, err := strconv.ParseFloat(horizonsGMPattern.FindStringSubmatch(text)[1], 64)
+			if err == nil && gm > 0 {
+				result.gm = gm
+			}
+		case strings.Contains(trimmed, ",") && !strings.HasPrefix(trimmed, "*"):
+			csvHeader = strings.Split(trimmed, ",")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+
+	if !hasTable {
+		return result, errors.New("no $$SOE marker, is this a Horizons vector table?")
+	}
+	if len(entry) == 0 {
+		return result, errors.New("no entries between $$SOE and $$EOE")
+	}
+	if result.name == "" {
+		return result, errors.New(`no "Target body name" in the header`)
+	}
+
+	var values map[string]float64
+	var err error
+	if strings.Contains(entry[0], ",") {
+		values, err = parseHorizonsCSVEntry(csvHeader, entry[0])
+	} else {
+		values, err = parseHorizonsTextEntry(entry)
+	}
+	if err != nil {
+		return result, fmt.Errorf("line %d: %w", entryLine, err)
+	}
+	result.julianDay = values["JD"]
+
+	// convert to AU and AU/day
+	var lengthUnit, timeUnit float64
+	switch units {
+	case "KM-S", "":
+		// the default of Horizons
+		lengthUnit, timeUnit = astronomicalUnit, 1/secondsPerDay
+	case "KM-D":
+		lengthUnit, timeUnit = astronomicalUnit, 1
+	case "AU-D":
+		lengthUnit, timeUnit = 1, 1
+	default:
+		return result, fmt.Errorf("unsupported output units %q, use KM-S, KM-D or AU-D", units)
+	}
+	for i, axis := range []string{"X", "Y", "Z"} {
+		result.position[i] = values[axis] / lengthUnit
+		result.velocity[i] = values["V"+axis] / lengthUnit / timeUnit
+	}
+
+	if !isEcliptic {
+		result.position = equatorialToEcliptic(result.position)
+		result.velocity = equatorialToEcliptic(result.velocity)
+	}
+
+	if result.gm == 0 {
+		result.gm = majorBodies[result.id].gm
+	}
+	result.gm = gmToAstronomicalUnits(result.gm)
+
+	return result, nil
+}
+
+// parseHorizonsBody splits "Earth (399)" into its name and id. Bodies
+// without a numeric id, like "1 Ceres (A801 AA)", get the id -1.
+func parseHorizonsBody(s string) (string, int) {
+	s = strings.TrimSpace(s)
+	match := horizonsIDPattern.FindStringSubmatch(s)
+	if match == nil {
+		return s, -1
+	}
+
+	id, err := strconv.Atoi(match[2])
+	if err != nil {
+		return s, -1
+	}
+
+	return match[1], id
+}
+
+func startsWithNumber(s string) bool {
+	return s != "" && (s[0] >= '0' && s[0] <= '9')
+}
+
+// parseHorizonsTextEntry reads "2460000.5 = A.D. ..." followed by lines of
+// "X = 1.0E+08 Y = ..." pairs.
+func parseHorizonsTextEntry(entry []string) (map[string]float64, error) {
+	values := map[string]float64{}
+
+	julianDay, _, _ := strings.Cut(entry[0], "=")
+	jd, err := strconv.ParseFloat(strings.TrimSpace(julianDay), 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected a Julian day, got %q", entry[0])
+	}
+	values["JD"] = jd
+
+	for _, text := range entry[1:] {
+		for _, match := range horizonsVectorPattern.FindAllStringSubmatch(text, -1) {
+			v, err := strconv.ParseFloat(match[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", match[1], match[2])
+			}
+			values[match[1]] = v
+		}
+	}
+
+	return values, checkHorizonsValues(values)
+}
+
+// parseHorizonsCSVEntry reads a row of the CSV format using its header.
+func parseHorizonsCSVEntry(header []string, row string) (map[string]float64, error) {
+	values := map[string]float64{}
+	fields := strings.Split(row, ",")
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if i >= len(fields) {
+			break
+		}
+		if name == "JDTDB" || name == "JDUT" {
+			name = "JD"
+		}
+		if !slices.Contains([]string{"JD", "X", "Y", "Z", "VX", "VY", "VZ"}, name) {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(fields[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %d (%s): %q is not a number", i+1, name, strings.TrimSpace(fields[i]))
+		}
+		values[name] = v
+	}
+
+	return values, checkHorizonsValues(values)
+}
+
+func checkHorizonsValues(values map[string]float64) error {
+	missing := []string{}
+	for _, name := range []string{"X", "Y", "Z", "VX", "VY", "VZ"} {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s, export a vector table with positions and velocities", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// equatorialToEcliptic rotates v around the x axis (the vernal equinox) by
+// the obliquity.
+func equatorialToEcliptic(v [3]float64) [3]float64 {
+	sin, cos := math.Sincos(obliquity)
+	return [3]float64{v[0], v[1]*cos + v[2]*sin, -v[1]*sin + v[2]*cos}
+}
+
+// gmToAstronomicalUnits converts km³/s² to AU³/day².
+func gmToAstronomicalUnits(gm float64) float64 {
+	return gm * secondsPerDay * secondsPerDay / (astronomicalUnit * astronomicalUnit * astronomicalUnit)
+}
+
+// horizonsImport turns every file in directory into a body. The files have
+// to share the centre body and epoch.
+type horizonsImport struct {
+	directory   string
+	pixelsPerAU float64
+	// simulated days per simulation second
+	daysPerSecond      float64
+	addCenterBody      bool
+	centerOfMassAtRest bool
+	replaceScene       bool
+	shouldImport       bool
+	status             string
+	err                error
+}
+
+func newHorizonsImport() *horizonsImport {
+	return &horizonsImport{
+		pixelsPerAU:        100,
+		daysPerSecond:      10,
+		addCenterBody:      true,
+		centerOfMassAtRest: true,
+		replaceScene:       true,
+	}
+}
+
+// readEphemerides parses every .txt and .csv file of the directory.
+func (importer *horizonsImport) readEphemerides() ([]ephemeris, error) {
+	entries, err := os.ReadDir(importer.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemerides := []ephemeris{}
+	errs := []error{}
+	for _, entry := range entries {
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || extension != ".txt" && extension != ".csv" {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(importer.directory, entry.Name()))
+		if err == nil {
+			var result ephemeris
+			result, err = parseHorizons(content)
+			ephemerides = append(ephemerides, result)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(ephemerides) == 0 {
+		return nil, errors.New("no .txt or .csv files")
+	}
+
+	first := ephemerides[0]
+	for _, other := range ephemerides[1:] {
+		if other.center != first.center {
+			errs = append(errs, fmt.Errorf("%s is relative to %s but %s to %s", other.name, other.center, first.name, first.center))
+		}
+		if math.Abs(other.julianDay-first.julianDay) > 1e-6 {
+			errs = append(errs, fmt.Errorf("%s starts at JD %v but %s at JD %v", other.name, other.julianDay, first.name, first.julianDay))
+		}
+	}
+
+	return ephemerides, errors.Join(errs...)
+}
+
+// bodyRadius grows with the logarithm of the GM in km³/s², so the Sun is a
+// bit bigger than Jupiter which is bigger than the Earth.
+func bodyRadius(gm float64) float64 {
+	gm = gm * math.Pow(astronomicalUnit, 3) / (secondsPerDay * secondsPerDay)
+	if gm <= 1 {
+		return 2
+	}
+	return max(2, 1.5*math.Log10(gm)-2)
+}
+
+// bodies scales the ephemerides to the screen. Screen y points down, so y is
+// mirrored to keep the orbits counterclockwise.
+func (importer *horizonsImport) bodies(ephemerides []ephemeris, gravitationalConstant float64) []bodyRecord {
+	first := ephemerides[0]
+	if center, ok := majorBodies[first.centerID]; ok && importer.addCenterBody && !slices.ContainsFunc(ephemerides, func(e ephemeris) bool {
+		return e.id == first.centerID
+	}) {
+		ephemerides = append([]ephemeris{{name: first.center, id: first.centerID, gm: gmToAstronomicalUnits(center.gm)}}, ephemerides...)
+	}
+
+	// bodies without a known GM, like spacecraft, become test particles
+	minimumGM := gmToAstronomicalUnits(1e-6)
+
+	if importer.centerOfMassAtRest {
+		var momentum [3]float64
+		var position [3]float64
+		total := 0.0
+		for _, e := range ephemerides {
+			gm := max(e.gm, minimumGM)
+			for i := range momentum {
+				momentum[i] += e.velocity[i] * gm
+				position[i] += e.position[i] * gm
+			}
+			total += gm
+		}
+		for j := range ephemerides {
+			for i := range momentum {
+				ephemerides[j].velocity[i] -= momentum[i] / total
+				ephemerides[j].position[i] -= position[i] / total
+			}
+		}
+	}
+
+	length := importer.pixelsPerAU
+	speed := importer.pixelsPerAU * importer.daysPerSecond
+	// G * m has to equal GM in pixels³/s²
+	massUnit := length * length * length * importer.daysPerSecond * importer.daysPerSecond / gravitationalConstant
+
+	palette := []color.NRGBA{SetColor(255, 90, 90, 255), SetColor(90, 200, 255, 255), SetColor(255, 220, 90, 255), SetColor(160, 255, 120, 255)}
+	bodies := make([]bodyRecord, len(ephemerides))
+	for i, e := range ephemerides {
+		c, ok := majorBodies[e.id]
+		if !ok {
+			c.color = palette[i%len(palette)]
+		}
+		gm := max(e.gm, minimumGM)
+
+		bodies[i] = bodyRecord{
+			Name:     e.name,
+			X:        e.position[0] * length,
+			Y:        -e.position[1] * length,
+			Velocity: physics.Vector{X: e.velocity[0] * speed, Y: -e.velocity[1] * speed},
+			Mass:     gm * massUnit,
+			Radius:   bodyRadius(gm),
+			Color:    colorRecord{R: c.color.R, G: c.color.G, B: c.color.B, A: c.color.A},
+		}
+	}
+
+	return bodies
+}
+
+func (sim *simulation) handleHorizonsImport() {
+	importer := sim.horizonsImport
+	if !importer.shouldImport {
+		return
+	}
+	importer.shouldImport = false
+	importer.err = nil
+
+	ephemerides, err := importer.readEphemerides()
+	if err != nil {
+		importer.err = fmt.Errorf("failed to import %s:\n%w", importer.directory, err)
+		return
+	}
+
+	if importer.replaceScene {
+		sim.reset()
+	}
+
+	planetHandler := sim.planetHandler
+	bodies := importer.bodies(ephemerides, planetHandler.gravitationalConstant)
+	for _, body := range bodies {
+		planetHandler.addPlanet(newPlanet(
+			body.Name,
+			body.X,
+			body.Y,
+			body.Radius,
+			body.Mass,
+			vector2(body.Velocity),
+			color.NRGBA{body.Color.R, body.Color.G, body.Color.B, body.Color.A},
+			planetHandler.planetsOffset,
+		))
+		planetHandler.planetCounter++
+	}
+
+	planetHandler.running = false
+	importer.status = fmt.Sprintf("Imported %d bodies at JD %v", len(bodies), ephemerides[0].julianDay)
+}
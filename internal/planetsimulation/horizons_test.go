@@ -0,0 +1,253 @@
+package planetsimulation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// horizonsText is a vector table in the text format, shortened to the lines
+// that are read. The second entry has to be ignored.
+const horizonsText = `*******************************************************************************
+ Revised: July 31, 2013                  Earth                              399
+ GM, km^3/s^2          = 398600.435436  GM 1-sigma, km^3/s^2  = 0.0014
+*******************************************************************************
+Ephemeris / WWW_USER Wed Feb 22 10:00:00 2023 Pasadena, USA      / Horizons
+*******************************************************************************
+Target body name: Earth (399)                     {source: DE441}
+Center body name: Sun (10)                        {source: DE441}
+*******************************************************************************
+Output units    : %s
+Reference frame : ICRF
+Reference plane : %s
+*******************************************************************************
+$$SOE
+2460000.500000000 = A.D. 2023-Feb-25 00:00:00.0000 TDB
+ X =%s Y =%s Z =%s
+ VX=%s VY=%s VZ=%s
+ LT= 4.914271264296476E+02 RG= 1.473264558466018E+08 RR=-5.005637117578136E-02
+2460001.500000000 = A.D. 2023-Feb-26 00:00:00.0000 TDB
+ X = 9.0E+09 Y = 9.0E+09 Z = 9.0E+09
+ VX= 9.0E+09 VY= 9.0E+09 VZ= 9.0E+09
+$$EOE
+*******************************************************************************
+`
+
+// horizonsCSV is the same table in the CSV format.
+const horizonsCSV = `*******************************************************************************
+Target body name: Mars (499)                      {source: mar097}
+Center body name: Sun (10)                        {source: DE441}
+*******************************************************************************
+Output units    : %s
+Reference frame : ICRF
+Reference plane : %s
+*******************************************************************************
+            JDTDB,            Calendar Date (TDB),                      X,                      Y,                      Z,                     VX,                     VY,                     VZ,
+**************************************************************************************************************************************************************************************************
+$$SOE
+2460000.500000000, A.D. 2023-Feb-25 00:00:00.0000, %s, %s, %s, %s, %s, %s,
+2460001.500000000, A.D. 2023-Feb-26 00:00:00.0000, 9.0E+09, 9.0E+09, 9.0E+09, 9.0E+09, 9.0E+09, 9.0E+09,
+$$EOE
+*******************************************************************************
+`
+
+const eclipticPlane = "Ecliptic of J2000.0"
+
+// newHorizonsTable fills format with the units, plane and the position and
+// velocity.
+func newHorizonsTable(format string, units string, plane string, position [3]float64, velocity [3]float64) []byte {
+	values := []any{units, plane}
+	for _, v := range append(position[:], velocity[:]...) {
+		values = append(values, fmt.Sprintf("%.15E", v))
+	}
+
+	return fmt.Appendf(nil, format, values...)
+}
+
+func vectorsAreClose(a [3]float64, b [3]float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9*max(1, math.Abs(b[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestParseHorizonsUnits(t *testing.T) {
+	// 1, 2 and 0.5 AU moving at 0.01, 0.02 and -0.001 AU/day
+	position := [3]float64{1, 2, 0.5}
+	velocity := [3]float64{0.01, 0.02, -0.001}
+	scale := func(v [3]float64, factor float64) [3]float64 {
+		return [3]float64{v[0] * factor, v[1] * factor, v[2] * factor}
+	}
+
+	tests := []struct {
+		units    string
+		position [3]float64
+		velocity [3]float64
+	}{
+		{"KM-S", scale(position, astronomicalUnit), scale(velocity, astronomicalUnit/secondsPerDay)},
+		{"KM-D", scale(position, astronomicalUnit), scale(velocity, astronomicalUnit)},
+		{"AU-D", position, velocity},
+	}
+
+	for _, format := range []string{horizonsText, horizonsCSV} {
+		for _, test := range tests {
+			content := newHorizonsTable(format, test.units, eclipticPlane, test.position, test.velocity)
+			result, err := parseHorizons(content)
+			if err != nil {
+				t.Fatalf("%s: %v", test.units, err)
+			}
+
+			if !vectorsAreClose(result.position, position) || !vectorsAreClose(result.velocity, velocity) {
+				t.Errorf("%s %s: got %v %v, want %v %v", result.name, test.units, result.position, result.velocity, position, velocity)
+			}
+			if result.julianDay != 2460000.5 || result.center != "Sun" || result.centerID != 10 {
+				t.Errorf("%s %s: JD %v relative to %s (%d)", result.name, test.units, result.julianDay, result.center, result.centerID)
+			}
+		}
+	}
+}
+
+func TestParseHorizonsHeader(t *testing.T) {
+	text, err := parseHorizons(newHorizonsTable(horizonsText, "AU-D", eclipticPlane, [3]float64{}, [3]float64{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.name != "Earth" || text.id != 399 {
+		t.Errorf("target = %s (%d), want Earth (399)", text.name, text.id)
+	}
+	// the GM of the header, not the 1-sigma
+	if want := gmToAstronomicalUnits(398600.435436); math.Abs(text.gm-want) > 1e-9*want {
+		t.Errorf("gm = %v, want %v", text.gm, want)
+	}
+
+	csv, err := parseHorizons(newHorizonsTable(horizonsCSV, "AU-D", eclipticPlane, [3]float64{}, [3]float64{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without a GM in the header the one of the major body is used
+	if want := gmToAstronomicalUnits(majorBodies[499].gm); csv.name != "Mars" || csv.gm != want {
+		t.Errorf("target = %s with gm %v, want Mars with %v", csv.name, csv.gm, want)
+	}
+
+	if name, id := parseHorizonsBody("1 Ceres (A801 AA)"); name != "1 Ceres (A801 AA)" || id != -1 {
+		t.Errorf("parseHorizonsBody = %q, %d", name, id)
+	}
+}
+
+func TestParseHorizonsEquatorial(t *testing.T) {
+	sin, cos := math.Sincos(obliquity)
+	// the pole of the ecliptic in equatorial coordinates
+	pole := [3]float64{0, -sin, cos}
+	equinox := [3]float64{1, 0, 0}
+
+	for _, format := range []string{horizonsText, horizonsCSV} {
+		result, err := parseHorizons(newHorizonsTable(format, "AU-D", "Earth mean equator and equinox of reference epoch", pole, equinox))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !vectorsAreClose(result.position, [3]float64{0, 0, 1}) {
+			t.Errorf("%s: the pole of the ecliptic is at %v, want 0, 0, 1", result.name, result.position)
+		}
+		if !vectorsAreClose(result.velocity, equinox) {
+			t.Errorf("%s: the equinox is at %v, want %v", result.name, result.velocity, equinox)
+		}
+	}
+}
+
+func TestParseMalformedHorizons(t *testing.T) {
+	valid := string(newHorizonsTable(horizonsText, "AU-D", eclipticPlane, [3]float64{1, 2, 3}, [3]float64{4, 5, 6}))
+	validCSV := string(newHorizonsTable(horizonsCSV, "AU-D", eclipticPlane, [3]float64{1, 2, 3}, [3]float64{4, 5, 6}))
+
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"no table", strings.Replace(valid, "$$SOE", "", 1), "no $$SOE marker"},
+		{"empty table", valid[:strings.Index(valid, "$$SOE")+len("$$SOE\n")] + "$$EOE\n", "no entries"},
+		{"no target", strings.Replace(valid, "Target body name", "Target", 1), "Target body name"},
+		{"units", strings.Replace(valid, "AU-D", "KM-H", 1), `unsupported output units "KM-H"`},
+		{"julian day", strings.Replace(valid, "2460000.500000000 =", "today =", 1), "line 15: expected a Julian day"},
+		{"missing velocity", strings.Replace(valid, " VZ=", " RR=", 1), "line 15: missing VZ"},
+		{"csv number", strings.Replace(validCSV, "1.000000000000000E+00", "one", 1), "line 12: column 3 (X)"},
+		{"csv columns", strings.Replace(validCSV, "VY,", "LT,", 1), "missing VY"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseHorizons([]byte(test.content))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error %q doesn't contain %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestHorizonsBodies(t *testing.T) {
+	earth := ephemeris{
+		name:      "Earth",
+		id:        399,
+		center:    "Sun",
+		centerID:  10,
+		julianDay: 2460000.5,
+		position:  [3]float64{1, 0.5, 0.1},
+		velocity:  [3]float64{-0.005, 0.017, 0},
+		gm:        gmToAstronomicalUnits(majorBodies[399].gm),
+	}
+	importer := newHorizonsImport()
+	const gravitationalConstant = 1000
+
+	bodies := importer.bodies([]ephemeris{earth}, gravitationalConstant)
+	if len(bodies) != 2 || bodies[0].Name != "Sun" || bodies[1].Name != "Earth" {
+		t.Fatalf("bodies = %+v, want the Sun and the Earth", bodies)
+	}
+	sun := bodies[0]
+	earthBody := bodies[1]
+
+	// the positions and velocities relative to the Sun are scaled, with y
+	// pointing down
+	length := importer.pixelsPerAU
+	speed := importer.pixelsPerAU * importer.daysPerSecond
+	relative := [3]float64{earthBody.X - sun.X, earthBody.Y - sun.Y, 0}
+	if want := [3]float64{length, -0.5 * length, 0}; !vectorsAreClose(relative, want) {
+		t.Errorf("the Earth is at %v from the Sun, want %v", relative, want)
+	}
+	relative = [3]float64{earthBody.Velocity.X - sun.Velocity.X, earthBody.Velocity.Y - sun.Velocity.Y, 0}
+	if want := [3]float64{-0.005 * speed, -0.017 * speed, 0}; !vectorsAreClose(relative, want) {
+		t.Errorf("the Earth moves at %v relative to the Sun, want %v", relative, want)
+	}
+
+	// G m is the GM in pixels³/s²
+	wantGM := earth.gm * length * length * length * importer.daysPerSecond * importer.daysPerSecond
+	if gm := gravitationalConstant * earthBody.Mass; math.Abs(gm-wantGM) > 1e-9*wantGM {
+		t.Errorf("G m = %v, want %v", gm, wantGM)
+	}
+
+	// the centre of mass is at rest in the origin
+	var momentum, position [2]float64
+	for _, body := range bodies {
+		momentum[0] += body.Mass * body.Velocity.X
+		momentum[1] += body.Mass * body.Velocity.Y
+		position[0] += body.Mass * body.X
+		position[1] += body.Mass * body.Y
+	}
+	if math.Hypot(momentum[0], momentum[1]) > 1e-9*sun.Mass || math.Hypot(position[0], position[1]) > 1e-9*sun.Mass {
+		t.Errorf("momentum %v and centre of mass %v aren't zero", momentum, position)
+	}
+
+	// without the centre body the Earth keeps its position relative to the Sun
+	importer.addCenterBody = false
+	importer.centerOfMassAtRest = false
+	bodies = importer.bodies([]ephemeris{earth}, gravitationalConstant)
+	if len(bodies) != 1 || bodies[0].X != length || bodies[0].Y != -0.5*length {
+		t.Errorf("bodies = %+v, want the Earth at %v, %v", bodies, length, -0.5*length)
+	}
+}
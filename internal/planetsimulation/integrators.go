@@ -0,0 +1,18 @@
+package planetsimulation
+
+import "PlanetSimulation/internal/physics"
+
+// integrators are part of the physics, their names are stored in presets and
+// snapshots
+type integrator = physics.Integrator
+
+const (
+	integratorEuler    = physics.Euler
+	integratorLeapfrog = physics.Leapfrog
+)
+
+var integratorNames = physics.IntegratorNames
+
+func parseIntegrator(name string) (integrator, bool) {
+	return physics.ParseIntegrator(name)
+}
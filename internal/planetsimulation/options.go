@@ -0,0 +1,144 @@
+package planetsimulation
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"PlanetSimulation/internal/schema"
+)
+
+// options are the command line flags of the game.
+type options struct {
+	windowed      bool
+	width         int
+	height        int
+	title         string
+	preset        string
+	paused        bool
+	g             float64
+	tps           int
+	timeStep      float64
+	integrator    string
+	dataDirectory string
+}
+
+// the window size if only -windowed is given
+const (
+	defaultWindowWidth  = 1280
+	defaultWindowHeight = 720
+)
+
+func newOptionsFlags(options *options, output io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("planetsimulation", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: planetsimulation [flags]")
+		fmt.Fprintln(output, "       planetsimulation run [flags]")
+		fmt.Fprintln(output, "       planetsimulation sweep [flags]")
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "Starts the game, fullscreen unless -windowed, -width or -height is given.")
+		fmt.Fprintln(output, "See planetsimulation run -help and planetsimulation sweep -help to run")
+		fmt.Fprintln(output, "simulations without a window.")
+		fmt.Fprintln(output)
+		flags.PrintDefaults()
+	}
+
+	flags.BoolVar(&options.windowed, "windowed", false, "start in a resizable window instead of fullscreen")
+	flags.IntVar(&options.width, "width", 0, "window `width`, implies -windowed (default 1280)")
+	flags.IntVar(&options.height, "height", 0, "window `height`, implies -windowed (default 720)")
+	flags.StringVar(&options.title, "title", "Planet Simulation", "window `title`")
+	flags.StringVar(&options.preset, "preset", "", "`name` of the simulation preset to load on start")
+	flags.BoolVar(&options.paused, "paused", false, "start with the simulation paused")
+	flags.Float64Var(&options.g, "g", 0, "gravitational `constant`, defaults to the one of the preset or 10000")
+	flags.IntVar(&options.tps, "tps", 120, "ticks per second")
+	flags.Float64Var(&options.timeStep, "dt", 0, "simulated `seconds` per tick, 0 follows the frame time")
+	flags.StringVar(&options.integrator, "integrator", "", "euler or leapfrog, defaults to the one of the preset or euler")
+	flags.StringVar(&options.dataDirectory, "data-dir", "", "`directory` for presets, autosaves, snapshots, replays and exports instead of the user directories")
+
+	return flags
+}
+
+// parseOptions parses args without the program name. It returns flag.ErrHelp
+// if the usage was asked for.
+func parseOptions(args []string, output io.Writer) (*options, error) {
+	options := &options{}
+	flags := newOptionsFlags(options, output)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	if options.width != 0 || options.height != 0 {
+		options.windowed = true
+	}
+	if options.windowed {
+		if options.width == 0 {
+			options.width = defaultWindowWidth
+		}
+		if options.height == 0 {
+			options.height = defaultWindowHeight
+		}
+	}
+
+	errs := make([]error, 0)
+	if options.width < 0 || options.height < 0 {
+		errs = append(errs, fmt.Errorf("-width and -height must be positive, got %dx%d", options.width, options.height))
+	}
+	if options.tps <= 0 {
+		errs = append(errs, fmt.Errorf("-tps must be positive, got %d", options.tps))
+	}
+	if !schema.IsFinite(options.g) || options.g < 0 {
+		errs = append(errs, fmt.Errorf("-g must be positive, got %v", options.g))
+	}
+	if !schema.IsFinite(options.timeStep) || options.timeStep < 0 {
+		errs = append(errs, fmt.Errorf("-dt must be zero or positive, got %v", options.timeStep))
+	}
+	if _, ok := parseIntegrator(options.integrator); options.integrator != "" && !ok {
+		errs = append(errs, fmt.Errorf("-integrator: unknown integrator %q", options.integrator))
+	}
+	if options.dataDirectory != "" {
+		directory, err := filepath.Abs(options.dataDirectory)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-data-dir: %w", err))
+		}
+		options.dataDirectory = directory
+	}
+
+	return options, errors.Join(errs...)
+}
+
+// apply sets the initial state of sim. The preset is loaded first, so the
+// other flags override its values.
+func (options *options) apply(sim *simulation) error {
+	planetHandler := sim.planetHandler
+
+	if options.preset != "" {
+		presets := sim.simulationPresets
+		i := presets.indexOf(options.preset)
+		if i < 0 {
+			return fmt.Errorf("-preset: unknown simulation preset %q", options.preset)
+		}
+		presets.presetIndex = i
+		presets.shouldLoadSimulation = true
+		presets.handleLoad(planetHandler, i)
+	}
+
+	if options.g != 0 {
+		planetHandler.gravitationalConstant = options.g
+	}
+	if integrator, ok := parseIntegrator(options.integrator); ok && options.integrator != "" {
+		planetHandler.integrator = integrator
+	}
+	if options.timeStep != 0 {
+		planetHandler.timeStep = options.timeStep
+	}
+	planetHandler.running = !options.paused
+	sim.tps = options.tps
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package planetsimulation
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	options, err := parseOptions(nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.windowed || options.tps != 120 || options.title != "Planet Simulation" || options.paused {
+		t.Errorf("defaults = %+v", options)
+	}
+
+	options, err = parseOptions([]string{"-width", "800", "-paused", "-g", "500", "-integrator", "leapfrog", "-dt", "0.01"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !options.windowed || options.width != 800 || options.height != defaultWindowHeight {
+		t.Errorf("window = %v %dx%d, want windowed 800x%d", options.windowed, options.width, options.height, defaultWindowHeight)
+	}
+	if !options.paused || options.g != 500 || options.integrator != "leapfrog" || options.timeStep != 0.01 {
+		t.Errorf("options = %+v", options)
+	}
+}
+
+func TestParseInvalidOptions(t *testing.T) {
+	tests := [][]string{
+		{"-tps", "0"},
+		{"-width", "-5"},
+		{"-g", "-1"},
+		{"-dt", "NaN"},
+		{"-integrator", "rk4"},
+		{"-unknown"},
+		{"extra"},
+	}
+
+	for _, args := range tests {
+		if _, err := parseOptions(args, io.Discard); err == nil {
+			t.Errorf("parseOptions(%q) succeeded", args)
+		}
+	}
+}
+
+func TestHelpListsOptions(t *testing.T) {
+	output := &strings.Builder{}
+	if _, err := parseOptions([]string{"--help"}, output); !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("err = %v, want flag.ErrHelp", err)
+	}
+
+	for _, name := range []string{"-windowed", "-width", "-height", "-preset", "-paused", "-g", "-tps", "-dt", "-integrator", "-data-dir"} {
+		if !strings.Contains(output.String(), name) {
+			t.Errorf("the help doesn't list %s", name)
+		}
+	}
+}
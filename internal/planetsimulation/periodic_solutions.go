@@ -0,0 +1,206 @@
+package planetsimulation
+
+import (
+	"image/color"
+	"math"
+)
+
+// The periodic solutions are given in units with G = m = 1 and scaled to the
+// screen with these units. One time unit equals sqrt(L³ / (G * M)) ≈ 1.84 s.
+const (
+	periodicLengthUnit            = 150.0
+	periodicMassUnit              = 100.0
+	periodicGravitationalConstant = 10000.0
+	periodicPlanetRadius          = 4.0
+)
+
+type initialConditions struct {
+	name   string
+	x, y   float64
+	vx, vy float64
+	mass   float64
+	color  color.NRGBA
+}
+
+var periodicColors = []color.NRGBA{
+	SetColor(255, 90, 90, 255),
+	SetColor(90, 200, 255, 255),
+	SetColor(255, 220, 90, 255),
+}
+
+// periodicPreset scales initial conditions from dimensionless units to the
+// simulation and wraps them in a read-only preset.
+func periodicPreset(name string, description string, timeStep float64, bodies []initialConditions) *simulationPreset {
+	velocityUnit := math.Sqrt(periodicGravitationalConstant * periodicMassUnit / periodicLengthUnit)
+
+	planets := make([]*Planet, len(bodies))
+	for i, body := range bodies {
+		planets[i] = &Planet{
+			Name:   body.name,
+			X:      body.x * periodicLengthUnit,
+			Y:      body.y * periodicLengthUnit,
+			Radius: periodicPlanetRadius,
+			Mass:   body.mass * periodicMassUnit,
+			Velocity: vector2{
+				X: body.vx * velocityUnit,
+				Y: body.vy * velocityUnit,
+			},
+			Color: body.color,
+			// the defaults of newPlanet, a preset code of the preset is
+			// validated like a file
+			TraceEveryNTick: 5,
+			DrawEveryNTick:  1,
+			TraceWidth:      1.5,
+		}
+	}
+
+	return &simulationPreset{
+		Name: name,
+		presetMetadata: presetMetadata{
+			Description: description,
+			Tags:        []string{"three-body"},
+			Folder:      "Periodic orbits",
+		},
+		GravitationalConstant: periodicGravitationalConstant,
+		Integrator:            integratorLeapfrog.String(),
+		TimeStep:              timeStep,
+		Planets:               planets,
+		isBuiltIn:             true,
+	}
+}
+
+// collinearBodies creates three equal masses on the x axis moving along y,
+// the form used for the Broucke orbits.
+func collinearBodies(x [3]float64, vy [3]float64) []initialConditions {
+	bodies := make([]initialConditions, 3)
+	for i := range bodies {
+		bodies[i] = initialConditions{
+			name:  string(rune('A' + i)),
+			x:     x[i],
+			vy:    vy[i],
+			mass:  1,
+			color: periodicColors[i],
+		}
+	}
+
+	return bodies
+}
+
+// rigidRotation places equal masses at the given positions rotating around
+// their centre of mass with angular speed omega.
+func rigidRotation(positions [][2]float64, omega float64) []initialConditions {
+	bodies := make([]initialConditions, len(positions))
+	for i, position := range positions {
+		bodies[i] = initialConditions{
+			name:  string(rune('A' + i)),
+			x:     position[0],
+			y:     position[1],
+			vx:    -omega * position[1],
+			vy:    omega * position[0],
+			mass:  1,
+			color: periodicColors[i%len(periodicColors)],
+		}
+	}
+
+	return bodies
+}
+
+func figureEightPreset() *simulationPreset {
+	// Chenciner and Montgomery (2000), initial conditions by Simó, period 6.3259
+	x, y := 0.97000436, -0.24308753
+	vx, vy := -0.93240737, -0.86473146
+
+	return periodicPreset(
+		"Figure-eight",
+		"Three equal masses chasing each other on a figure eight, period 6.33 (11.6 s)",
+		1.0/240,
+		[]initialConditions{
+			{"A", x, y, -vx / 2, -vy / 2, 1, periodicColors[0]},
+			{"B", -x, -y, -vx / 2, -vy / 2, 1, periodicColors[1]},
+			{"C", 0, 0, vx, vy, 1, periodicColors[2]},
+		},
+	)
+}
+
+func lagrangeTrianglePreset() *simulationPreset {
+	// equilateral triangle with side 1, ω² = G * (m1 + m2 + m3) / s³
+	circumradius := 1 / math.Sqrt(3)
+	positions := make([][2]float64, 3)
+	for i := range positions {
+		angle := float64(i) * 2 * math.Pi / 3
+		positions[i] = [2]float64{circumradius * math.Cos(angle), circumradius * math.Sin(angle)}
+	}
+
+	return periodicPreset(
+		"Lagrange triangle",
+		"Equilateral triangle rotating rigidly, unstable for equal masses, period 3.63 (6.7 s)",
+		1.0/240,
+		rigidRotation(positions, math.Sqrt(3)),
+	)
+}
+
+func eulerCollinearPreset() *simulationPreset {
+	// outer masses at distance d = 1 feel G*m/d² + G*m/(2d)², so ω² = 5/4
+	return periodicPreset(
+		"Euler collinear",
+		"Three masses on a rotating line, unstable, period 5.62 (10.3 s)",
+		1.0/240,
+		rigidRotation([][2]float64{{-1, 0}, {0, 0}, {1, 0}}, math.Sqrt(5.0/4.0)),
+	)
+}
+
+func brouckeA1Preset() *simulationPreset {
+	return periodicPreset(
+		"Broucke A1",
+		"Broucke (1975) orbit A1, close encounters need a small step, period 6.28 (11.5 s)",
+		1.0/1200,
+		collinearBodies(
+			[3]float64{-0.9892620043, 2.2096177241, -1.2203557197},
+			[3]float64{1.9169244185, 0.1910268738, -2.1079512924},
+		),
+	)
+}
+
+func brouckeR1Preset() *simulationPreset {
+	return periodicPreset(
+		"Broucke R1",
+		"Broucke (1975) retrograde orbit R1, period 5.23 (9.6 s)",
+		1.0/1200,
+		collinearBodies(
+			[3]float64{0.8083106230, -0.4954148566, -0.3128957664},
+			[3]float64{0.9901979166, -2.7171431768, 1.7269452602},
+		),
+	)
+}
+
+func hierarchicalTriplePreset() *simulationPreset {
+	// tight inner binary with separation 0.3 and a third body at distance 2
+	innerSeparation, outerDistance := 0.3, 2.0
+	innerSpeed := math.Sqrt(2/innerSeparation) / 2
+	// outer relative speed around the total mass, shared by the centre of mass
+	outerSpeed := math.Sqrt(3 / outerDistance)
+
+	return periodicPreset(
+		"Hierarchical triple",
+		"Inner binary (period 0.73) orbited by a third body (period 10.3)",
+		1.0/480,
+		[]initialConditions{
+			{"A", -outerDistance/3 - innerSeparation/2, 0, 0, innerSpeed - outerSpeed/3, 1, periodicColors[0]},
+			{"B", -outerDistance/3 + innerSeparation/2, 0, 0, -innerSpeed - outerSpeed/3, 1, periodicColors[1]},
+			{"C", outerDistance * 2 / 3, 0, 0, outerSpeed * 2 / 3, 1, periodicColors[2]},
+		},
+	)
+}
+
+// periodicSolutionPresets returns the built-in library of well known periodic
+// three body configurations.
+func periodicSolutionPresets() []*simulationPreset {
+	return []*simulationPreset{
+		figureEightPreset(),
+		lagrangeTrianglePreset(),
+		eulerCollinearPreset(),
+		brouckeA1Preset(),
+		brouckeR1Preset(),
+		hierarchicalTriplePreset(),
+	}
+}
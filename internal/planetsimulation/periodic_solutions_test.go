@@ -0,0 +1,100 @@
+package planetsimulation
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"PlanetSimulation/internal/physics"
+)
+
+// energyOf returns the total energy of the planets.
+func energyOf(planetHandler *planetHandler) float64 {
+	system := &physics.System{GravitationalConstant: planetHandler.gravitationalConstant}
+	for _, planet := range planetHandler.planets {
+		system.Bodies = append(system.Bodies, planet.body())
+	}
+	kinetic, potential := system.Energy()
+
+	return kinetic + potential
+}
+
+// positions returns where the planets are.
+func positions(planets []*Planet) []vector2 {
+	points := make([]vector2, len(planets))
+	for i, planet := range planets {
+		points[i] = vector2{X: planet.X, Y: planet.Y}
+	}
+
+	return points
+}
+
+// outerPositions returns where the outer body and the centre of the inner
+// binary of a hierarchical triple are, the inner period doesn't divide the
+// outer one.
+func outerPositions(planets []*Planet) []vector2 {
+	a, b, c := planets[0], planets[1], planets[2]
+	return []vector2{{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}, {X: c.X, Y: c.Y}}
+}
+
+func TestPeriodicPresetsReturn(t *testing.T) {
+	timeUnit := math.Sqrt(periodicLengthUnit * periodicLengthUnit * periodicLengthUnit / (periodicGravitationalConstant * periodicMassUnit))
+	tests := map[string]struct {
+		// in dimensionless units
+		period float64
+		// the points that return to their start after a period
+		points func(planets []*Planet) []vector2
+		// in screen units, 2 is about 1% of the length unit
+		tolerance float64
+	}{
+		"Figure-eight":      {6.3259, positions, 2},
+		"Lagrange triangle": {2 * math.Pi / math.Sqrt(3), positions, 2},
+		"Euler collinear":   {2 * math.Pi / math.Sqrt(5.0/4.0), positions, 2},
+		"Broucke A1":        {6.283213, positions, 2},
+		"Broucke R1":        {5.2259411, positions, 2},
+		// the third body at distance 2 around the total mass of 3, the
+		// binary makes the orbit precess by a few degrees per period
+		"Hierarchical triple": {2 * math.Pi * math.Sqrt(8.0/3.0), outerPositions, 15},
+	}
+
+	presets := newSimulationPresets(newMemoryStorage(), newMemoryStorage())
+	for _, preset := range periodicSolutionPresets() {
+		t.Run(preset.Name, func(t *testing.T) {
+			test, ok := tests[preset.Name]
+			if !ok {
+				t.Fatal("no period")
+			}
+
+			planetHandler := newTestPlanetHandler()
+			presets.presetIndex = slices.IndexFunc(presets.all(), func(p *simulationPreset) bool { return p.Name == preset.Name })
+			presets.shouldLoadSimulation = true
+			presets.handleLoad(planetHandler, presets.presetIndex)
+			if planetHandler.integrator.String() != preset.Integrator || planetHandler.timeStep != preset.TimeStep {
+				t.Fatalf("loaded with %s and dt %v", planetHandler.integrator, planetHandler.timeStep)
+			}
+
+			start := test.points(planetHandler.planets)
+			count := len(planetHandler.planets)
+			initialEnergy := energyOf(planetHandler)
+
+			planetHandler.running = true
+			maxDrift := 0.0
+			for range int(math.Round(test.period * timeUnit / planetHandler.timeStep)) {
+				planetHandler.Update()
+				maxDrift = max(maxDrift, physics.RelativeDrift(initialEnergy, energyOf(planetHandler)))
+			}
+
+			if len(planetHandler.planets) != count {
+				t.Fatalf("%d of %d bodies are left", len(planetHandler.planets), count)
+			}
+			for i, point := range test.points(planetHandler.planets) {
+				if d := math.Hypot(point.X-start[i].X, point.Y-start[i].Y); d > test.tolerance {
+					t.Errorf("point %d is %v away from its start after a period", i, d)
+				}
+			}
+			if maxDrift > 1e-4 {
+				t.Errorf("energy drifted by up to %v", maxDrift)
+			}
+		})
+	}
+}
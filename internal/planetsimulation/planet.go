@@ -2,14 +2,17 @@ package planetsimulation
 
 import (
 	"image/color"
-	"math"
 	"slices"
 
+	"PlanetSimulation/internal/physics"
+
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 type Planet struct {
+	// unique within a simulation, assigned when added
+	id              int
 	Name            string
 	HasNameChanged  bool
 	X               float64
@@ -21,7 +24,7 @@ type Planet struct {
 	Color           color.NRGBA
 	image           *ebiten.Image
 	geometry        ebiten.GeoM
-	traces          [][]int
+	traces          []tracePoint
 	TraceWidth      float64
 	AntialiasTraces bool
 	TickCount       int
@@ -68,31 +71,7 @@ func (p *Planet) getColor() (int, int, int, int) {
 }
 
 func (p *Planet) changeColor(colorDelta ColorDelta) {
-	r, g, b, a := p.Color.RGBA()
-
-	if colorDelta.R != nil {
-		r = uint32(*colorDelta.R)
-	}
-
-	if colorDelta.G != nil {
-		g = uint32(*colorDelta.G)
-	}
-
-	if colorDelta.B != nil {
-		b = uint32(*colorDelta.B)
-	}
-
-	if colorDelta.A != nil {
-		a = uint32(*colorDelta.A)
-	}
-
-	p.Color = SetColor(
-		uint8(r),
-		uint8(g),
-		uint8(b),
-		uint8(a),
-	)
-
+	p.Color = colorDelta.apply(p.Color)
 	p.updateImage()
 }
 
@@ -100,6 +79,17 @@ func (p *Planet) clearTraces() {
 	p.traces = slices.Delete(p.traces, 0, len(p.traces))
 }
 
+// clone returns a deep copy of p using offset. The copy has no image so it
+// can't be drawn until updateImage is called.
+func (p *Planet) clone(offset []float64) *Planet {
+	clone := *p
+	clone.Offset = offset
+	clone.image = nil
+	clone.traces = slices.Clone(p.traces)
+
+	return &clone
+}
+
 func (p *Planet) focus(planetHandler *planetHandler) {
 	planetHandler.returnToOrigin()
 
@@ -108,9 +98,7 @@ func (p *Planet) focus(planetHandler *planetHandler) {
 	}
 
 	// move to planet
-	focusedPlanet := planetHandler.planets[planetHandler.focusedPlanet.index]
-	planetDx := focusedPlanet.X
-	planetDy := focusedPlanet.Y
+	planetDx, planetDy := planetHandler.focusPosition()
 	planetHandler.planetsOffset[0] -= planetDx
 	planetHandler.planetsOffset[1] -= planetDy
 
@@ -151,79 +139,34 @@ func (p *Planet) handleFocusedPlanet(sim *simulation, dx float64, dy float64) {
 	}
 }
 
-func (p *Planet) Update(handler *planetHandler) {
-	p.handleGravitation(handler)
-}
-
-func (p *Planet) handleGravitation(planetHandler *planetHandler) {
-	forces := make([]vector2, 0)
-
-	for i := 0; i < len(planetHandler.planets); i++ {
-		otherPlanet := planetHandler.planets[i]
-
-		if slices.Contains(planetHandler.planetsToRemove, i) || otherPlanet == p {
-			continue
-
-		}
-
-		// calculate distance
-		dx, dy, distance, overlaps := overlapsCircle(otherPlanet.X, p.X, otherPlanet.Y, p.Y, otherPlanet.Radius, p.Radius)
-		if overlaps {
-			planetHandler.mergePlanets(p, otherPlanet)
-		}
-
-		force := vector2{
-			X: dx,
-			Y: dy,
-		}
-
-		forceAmount := planetHandler.gravitationalConstant * ((p.Mass * otherPlanet.Mass) / math.Pow(distance, 2))
-
-		norForce := force.normalize()
-		force.X = norForce.X * forceAmount
-		force.Y = norForce.Y * forceAmount
-
-		forces = append(forces, vector2{force.X, force.Y})
-	}
-
-	// add all forces
-	resultingForce := vector2{0, 0}
-
-	for _, force := range forces {
-		resultingForce.X += force.X
-		resultingForce.Y += force.Y
-	}
-
-	// F = m * a
-	// calculate acceleration
-	acceleration := vector2{
-		X: resultingForce.X / p.Mass,
-		Y: resultingForce.Y / p.Mass,
-	}
-
-	// v = a * t
-	// calculate new velocity
-	time := 1 / ebiten.ActualFPS()
-
-	newVelocity := vector2{
-		X: acceleration.X * time,
-		Y: acceleration.Y * time,
+// body returns the physical state of p.
+func (p *Planet) body() *physics.Body {
+	return &physics.Body{
+		ID:       p.id,
+		Name:     p.Name,
+		X:        p.X,
+		Y:        p.Y,
+		Velocity: physics.Vector(p.Velocity),
+		Mass:     p.Mass,
+		Radius:   p.Radius,
 	}
+}
 
-	// add velocity
-	p.Velocity = p.Velocity.add(newVelocity)
-
-	// adjust for timestep
-	dx := p.Velocity.X * time
-	dy := p.Velocity.Y * time
-
-	p.translate(dx, dy)
+// setBody takes over the physical state of body.
+func (p *Planet) setBody(body *physics.Body) {
+	p.Velocity = vector2(body.Velocity)
+	p.Mass = body.Mass
+	p.Radius = body.Radius
+	p.setPosition(body.X, body.Y)
+}
 
+func (p *Planet) updateTraces(tick int) {
 	// trace ticks
 	for p.TickCount >= p.TraceEveryNTick {
-		tracePosition := []int{
-			int(p.X),
-			int(p.Y),
+		tracePosition := tracePoint{
+			X:    p.X,
+			Y:    p.Y,
+			Tick: tick,
 		}
 
 		p.traces = append(p.traces, tracePosition)
@@ -234,7 +177,9 @@ func (p *Planet) handleGravitation(planetHandler *planetHandler) {
 	p.TickCount++
 }
 
-func (p *Planet) Draw(screen *ebiten.Image) {
+// Draw draws the planet and its traces, project maps the recorded traces into
+// the selected reference frame.
+func (p *Planet) Draw(screen *ebiten.Image, project func(point tracePoint) (float64, float64)) {
 	screen.DrawImage(p.image, &ebiten.DrawImageOptions{
 		GeoM: p.geometry,
 	})
@@ -248,16 +193,16 @@ func (p *Planet) Draw(screen *ebiten.Image) {
 			continue
 		}
 
-		currentTrace := p.traces[i]
-		nextTrace := p.traces[i+1]
+		currentX, currentY := project(p.traces[i])
+		nextX, nextY := project(p.traces[i+1])
 
 		// adjust for offset
 		vector.StrokeLine(
 			screen,
-			float32(float64(currentTrace[0])+float64(p.Offset[0])),
-			float32(float64(currentTrace[1])+float64(p.Offset[1])),
-			float32(float64(nextTrace[0])+float64(p.Offset[0])),
-			float32(float64(nextTrace[1])+float64(p.Offset[1])),
+			float32(currentX+p.Offset[0]),
+			float32(currentY+p.Offset[1]),
+			float32(nextX+p.Offset[0]),
+			float32(nextY+p.Offset[1]),
 			float32(p.TraceWidth), p.Color, p.AntialiasTraces,
 		)
 	}
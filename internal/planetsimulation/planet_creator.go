@@ -72,7 +72,7 @@ func (planetCreator *planetCreator) spawnPlanet(planetHandler *planetHandler) {
 		planetHandler.planetsOffset,
 	)
 
-	planetHandler.planets = append(planetHandler.planets, newPlanet)
+	planetHandler.addPlanet(newPlanet)
 	planetHandler.planetCounter++
 
 	// make planetCreator planet highlight invisible
@@ -3,6 +3,8 @@ package planetsimulation
 import (
 	"slices"
 
+	"PlanetSimulation/internal/physics"
+
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
@@ -11,18 +13,31 @@ type planetHandler struct {
 	planetPresets         *planetPresets
 	planetsOffset         []float64
 	planetCounter         int
+	lastPlanetID          int
 	planetCreator         *planetCreator
 	planetsToRemove       []int
 	defaultPlanetsOffset  []float64
 	selectedPlanet        selectedPlanet
 	focusedPlanet         focusedPlanet
+	hierarchy             *hierarchy
+	events                *eventBus
+	eventDetector         *eventDetector
 	gravitationalConstant float64
+	integrator            integrator
+	timeStep              float64 // simulated seconds per tick, 0 follows the frame time
+	lastTimeStep          float64
 	running               bool
+	tick                  int
+	simulatedTime         float64
+	frame                 referenceFrame
+	centerOfMassTrace     []tracePoint
 }
 
 type focusedPlanet struct {
 	isFocused bool
 	index     int
+	// follow the planet and its satellites instead of the planet itself
+	isSystem bool
 }
 
 type selectedPlanet struct {
@@ -30,13 +45,16 @@ type selectedPlanet struct {
 	isSelected bool
 }
 
-func newPlanetHandler(gameSize []int) *planetHandler {
+func newPlanetHandler(gameSize []int, storage storage, defaults storage) *planetHandler {
 	// planet that is created by a click
 	planetHandler := &planetHandler{
 		planetCreator:         newPlanetCreator(),
 		defaultPlanetsOffset:  []float64{float64(gameSize[0]) / 2, float64(gameSize[1] / 2)},
-		planetPresets:         newPlanetPresets(),
+		planetPresets:         newPlanetPresets(storage, defaults),
 		planetsToRemove:       make([]int, 0),
+		hierarchy:             newHierarchy(),
+		events:                newEventBus(),
+		eventDetector:         newEventDetector(),
 		planetCounter:         0,
 		gravitationalConstant: 10000.0,
 		running:               true,
@@ -46,8 +64,55 @@ func newPlanetHandler(gameSize []int) *planetHandler {
 	return planetHandler
 }
 
+// clone returns a copy of the simulation state that doesn't share anything
+// with handler. Editing state like the planet creator and the presets as well
+// as event subscribers are not copied.
+func (handler *planetHandler) clone() *planetHandler {
+	clone := &planetHandler{
+		planetsOffset:         slices.Clone(handler.planetsOffset),
+		planetCounter:         handler.planetCounter,
+		lastPlanetID:          handler.lastPlanetID,
+		planetsToRemove:       slices.Clone(handler.planetsToRemove),
+		defaultPlanetsOffset:  slices.Clone(handler.defaultPlanetsOffset),
+		selectedPlanet:        handler.selectedPlanet,
+		focusedPlanet:         handler.focusedPlanet,
+		hierarchy:             newHierarchy(),
+		events:                newEventBus(),
+		eventDetector:         newEventDetector(),
+		gravitationalConstant: handler.gravitationalConstant,
+		integrator:            handler.integrator,
+		timeStep:              handler.timeStep,
+		running:               handler.running,
+		tick:                  handler.tick,
+		simulatedTime:         handler.simulatedTime,
+		lastTimeStep:          handler.lastTimeStep,
+		frame:                 handler.frame,
+		centerOfMassTrace:     slices.Clone(handler.centerOfMassTrace),
+	}
+
+	clone.planets = make([]*Planet, len(handler.planets))
+	for i, planet := range handler.planets {
+		clone.planets[i] = planet.clone(clone.planetsOffset)
+	}
+
+	// the frame has to refer to the cloned planets
+	if i := slices.Index(handler.planets, handler.frame.planet); i >= 0 {
+		clone.frame.planet = clone.planets[i]
+	}
+	if i := slices.Index(handler.planets, handler.frame.otherPlanet); i >= 0 {
+		clone.frame.otherPlanet = clone.planets[i]
+	}
+
+	return clone
+}
+
 func (handler *planetHandler) handlePlanetDeletion() {
 	if len(handler.planetsToRemove) > 0 {
+		// remove from the back so the remaining indices stay valid
+		slices.Sort(handler.planetsToRemove)
+		handler.planetsToRemove = slices.Compact(handler.planetsToRemove)
+		slices.Reverse(handler.planetsToRemove)
+
 		for _, planetIndex := range handler.planetsToRemove {
 			// remove from planets
 			if handler.selectedPlanet.index == planetIndex {
@@ -56,6 +121,9 @@ func (handler *planetHandler) handlePlanetDeletion() {
 			if handler.focusedPlanet.index == planetIndex {
 				handler.focusedPlanet.isFocused = false
 			}
+			// keep pointing to the same planets
+			shiftIndex(&handler.selectedPlanet.index, planetIndex, -1)
+			shiftIndex(&handler.focusedPlanet.index, planetIndex, -1)
 
 			handler.planets = slices.Delete(handler.planets, planetIndex, planetIndex+1)
 		}
@@ -66,37 +134,84 @@ func (handler *planetHandler) handlePlanetDeletion() {
 
 func (handler *planetHandler) deletePlanet(index int) {
 	handler.planetsToRemove = append(handler.planetsToRemove, index)
+	handler.emitEvent(eventDeleted, handler.planets[index], nil, 0)
 }
 
-func (handler *planetHandler) mergePlanets(p *Planet, otherPlanet *Planet) {
-	// merge planets
-	if p.Mass >= otherPlanet.Mass {
-		handler.deletePlanet(slices.Index(handler.planets, otherPlanet))
-		p.Mass += otherPlanet.Mass / 2
-		if p.Radius <= 1000 {
-			p.Radius += otherPlanet.Radius / 4
-		}
-
-		p.Velocity = p.Velocity.add(vector2{
-			((otherPlanet.Velocity.X) / p.Mass),
-			((otherPlanet.Velocity.Y) / p.Mass),
-		})
-		p.updateImage()
+func (handler *planetHandler) addPlanet(planet *Planet) {
+	if planet.id == 0 {
+		handler.lastPlanetID++
+		planet.id = handler.lastPlanetID
 	}
+	handler.planets = append(handler.planets, planet)
+	handler.emitEvent(eventCreated, planet, nil, 0)
 }
 
 func (handler *planetHandler) updatePlanets() {
 	if !handler.running {
 		return
 	}
-	for _, planet := range handler.planets {
-		planet.Update(handler)
-		if handler.focusedPlanet.isFocused {
+
+	dt := handler.timeStep
+	if dt <= 0 {
+		dt = 1 / ebiten.ActualFPS()
+	}
+	handler.step(dt)
+
+	if handler.focusedPlanet.isFocused {
+		for _, planet := range handler.planets {
 			planet.focus(handler)
 		}
 	}
 }
 
+// step advances all planets by dt and resolves the collisions that happened
+// in between. The physics don't depend on a running game so they can be used
+// headless.
+func (handler *planetHandler) step(dt float64) {
+	// the state at the end of this step belongs to the next tick
+	handler.tick++
+	handler.simulatedTime += dt
+	handler.lastTimeStep = dt
+
+	// planets waiting to be removed don't take part anymore
+	planets := make([]*Planet, 0, len(handler.planets))
+	for i, planet := range handler.planets {
+		if !slices.Contains(handler.planetsToRemove, i) {
+			planets = append(planets, planet)
+		}
+	}
+
+	system := &physics.System{
+		Bodies:                make([]*physics.Body, len(planets)),
+		GravitationalConstant: handler.gravitationalConstant,
+		Integrator:            handler.integrator,
+	}
+	planetsByBody := make(map[*physics.Body]*Planet, len(planets))
+	for i, planet := range planets {
+		system.Bodies[i] = planet.body()
+		planetsByBody[system.Bodies[i]] = planet
+	}
+
+	merges := system.Step(dt)
+
+	for body, planet := range planetsByBody {
+		planet.setBody(body)
+	}
+	for _, planet := range planets {
+		planet.updateTraces(handler.tick)
+	}
+	for _, merge := range merges {
+		p, otherPlanet := planetsByBody[merge.Survivor], planetsByBody[merge.Absorbed]
+		handler.planetsToRemove = append(handler.planetsToRemove, slices.Index(handler.planets, otherPlanet))
+		handler.emitEvent(eventMerged, p, otherPlanet, 0)
+		p.updateImage()
+	}
+
+	handler.recordCenterOfMass()
+
+	handler.eventDetector.detect(handler)
+}
+
 func (handler *planetHandler) selectPlanet(planetIndex int) {
 	handler.selectedPlanet.index = planetIndex
 	handler.selectedPlanet.isSelected = true
@@ -105,6 +220,22 @@ func (handler *planetHandler) selectPlanet(planetIndex int) {
 func (handler *planetHandler) focusPlanet(planetIndex int) {
 	handler.focusedPlanet.index = planetIndex
 	handler.focusedPlanet.isFocused = true
+	handler.focusedPlanet.isSystem = false
+}
+
+func (handler *planetHandler) focusSystem(planetIndex int) {
+	handler.focusPlanet(planetIndex)
+	handler.focusedPlanet.isSystem = true
+}
+
+func (handler *planetHandler) focusPosition() (float64, float64) {
+	focusedPlanet := handler.planets[handler.focusedPlanet.index]
+
+	if handler.focusedPlanet.isSystem {
+		return handler.hierarchy.barycenter(focusedPlanet, handler.planets)
+	}
+
+	return focusedPlanet.X, focusedPlanet.Y
 }
 
 func (handler *planetHandler) deleteSelectedPlanet() {
@@ -125,16 +256,26 @@ func (handler *planetHandler) returnToOrigin() {
 	}
 }
 
+// setCamera moves the camera to offset, the planets share the offset.
+func (handler *planetHandler) setCamera(offset []float64) {
+	copy(handler.planetsOffset, offset)
+	for _, planet := range handler.planets {
+		planet.setPosition(planet.X, planet.Y)
+	}
+}
+
 func (handler *planetHandler) Update() {
 	handler.handlePlanetDeletion()
+	handler.hierarchy.update(handler)
 	handler.updatePlanets()
 }
 
 func (handler *planetHandler) Draw(simScreen *ebiten.Image) {
 	// draw planets
+	project := handler.frame.projector(handler)
 	for _, planet := range handler.planets {
 		if planet != nil {
-			planet.Draw(simScreen)
+			planet.Draw(simScreen, project)
 		}
 	}
 
@@ -2,56 +2,195 @@ package planetsimulation
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 	"slices"
+	"time"
+
+	"PlanetSimulation/internal/schema"
 )
 
+const planetPresetsFileName = schema.PlanetPresetsFileName
+
+type planetPreset struct {
+	*Planet
+	presetMetadata
+}
+
 type planetPresets struct {
-	presets  []*Planet
-	filePath string
+	presets []*planetPreset
+	// read-only presets shipped with the game, listed before the ones of the
+	// user
+	builtInPresets []*planetPreset
+	browser        *presetBrowser
+	storage        storage
+	fileName       string
+	// set when the file could not be loaded or saved, the file is not
+	// overwritten after a failed load so nothing gets lost
+	err          error
+	hasLoadError bool
 }
 
-func newPlanetPresets() *planetPresets {
+func newPlanetPresets(storage storage, defaults storage) *planetPresets {
 	planetPresets := &planetPresets{
-		filePath: "assets/data/planet_presets.json",
+		storage:  storage,
+		fileName: planetPresetsFileName,
+		browser:  newPresetBrowser(),
 	}
 
+	planetPresets.loadBuiltIn(defaults)
 	planetPresets.loadFromFile()
 	return planetPresets
 }
 
+func (planetPresets *planetPresets) loadBuiltIn(defaults storage) {
+	content, err := defaults.read(planetPresetsFileName)
+	file := planetPresetsFile{}
+	if err == nil {
+		file, err = schema.DecodePlanetPresets(content)
+	}
+	if err != nil {
+		planetPresets.err = fmt.Errorf("failed to load the built-in planet presets: %w", err)
+		return
+	}
+
+	for _, record := range file.Planets {
+		planetPresets.builtInPresets = append(planetPresets.builtInPresets, planetPresetFromRecord(record))
+	}
+}
+
+// all returns the built-in presets followed by the ones of the user.
+func (planetPresets *planetPresets) all() []*planetPreset {
+	return slices.Concat(planetPresets.builtInPresets, planetPresets.presets)
+}
+
+func (planetPresets *planetPresets) isBuiltIn(i int) bool {
+	return i < len(planetPresets.builtInPresets)
+}
+
 func (planetPresets *planetPresets) saveToFile() {
-	content, err := json.MarshalIndent(planetPresets.filePath, "", " ")
+	if planetPresets.hasLoadError {
+		return
+	}
+
+	file := planetPresetsFile{
+		Version: schema.Version,
+		Planets: make([]planetPresetRecord, len(planetPresets.presets)),
+	}
+	for i, preset := range planetPresets.presets {
+		file.Planets[i] = planetPresetRecord{Body: bodyRecordFromPlanet(preset.Planet), Metadata: preset.presetMetadata}
+	}
+
+	content, err := json.MarshalIndent(file, "", " ")
 	if err != nil {
-		log.Printf("Failed to marshal planet presets json: %v", err)
+		planetPresets.err = fmt.Errorf("failed to marshal planet presets: %w", err)
+		return
 	}
 
-	writeFile(planetPresets.filePath, content)
+	if err := planetPresets.storage.write(planetPresets.fileName, content); err != nil {
+		planetPresets.err = fmt.Errorf("failed to save %s: %w", planetPresets.storage.location(planetPresets.fileName), err)
+		return
+	}
+	planetPresets.err = nil
 }
 
 func (planetPresets *planetPresets) loadFromFile() {
-	content := readFile(planetPresets.filePath)
+	content, err := planetPresets.storage.read(planetPresets.fileName)
+	if err != nil {
+		planetPresets.err = fmt.Errorf("failed to read %s: %w", planetPresets.storage.location(planetPresets.fileName), err)
+		planetPresets.hasLoadError = true
+		return
+	}
 
-	if err := json.Unmarshal(content, &planetPresets); err != nil {
-		log.Printf("Failed to unmarshal planet presets json: %v", err)
+	file, err := schema.DecodePlanetPresets(content)
+	if err != nil {
+		planetPresets.err = fmt.Errorf("failed to load %s: %w", planetPresets.storage.location(planetPresets.fileName), err)
+		planetPresets.hasLoadError = true
+		return
+	}
+
+	planetPresets.presets = make([]*planetPreset, len(file.Planets))
+	for i, record := range file.Planets {
+		planetPresets.presets[i] = planetPresetFromRecord(record)
 	}
 }
 
 func (planetPresets *planetPresets) addPlanet(planetToAdd Planet) {
-	// replace if same name
-	for i, planet := range planetPresets.presets {
-		if planet.Name == planetToAdd.Name {
-			planetPresets.presets[i] = &planetToAdd
+	// store a copy of the values only
+	planet := planetFromRecord(bodyRecordFromPlanet(&planetToAdd))
+
+	// replace if same name, keeping the metadata
+	for _, preset := range planetPresets.presets {
+		if preset.Name == planet.Name {
+			preset.Planet = planet
+			preset.Touch()
 			planetPresets.saveToFile()
 			return
 		}
 	}
 
-	planetPresets.presets = append(planetPresets.presets, &planetToAdd)
+	preset := &planetPreset{Planet: planet}
+	preset.Touch()
+	planetPresets.presets = append(planetPresets.presets, preset)
 
 	planetPresets.saveToFile()
 }
 
-func (planetPresets *planetPresets) deletePreset(index int) {
+// deletePreset removes the preset at index i of all.
+func (planetPresets *planetPresets) deletePreset(i int) {
+	index := i - len(planetPresets.builtInPresets)
+	if index < 0 {
+		return
+	}
+
 	planetPresets.presets = slices.Delete(planetPresets.presets, index, index+1)
+
+	planetPresets.saveToFile()
+}
+
+// duplicatePreset copies the preset at index i of all behind the presets of
+// the user in its folder and returns the index of the copy in all.
+func (planetPresets *planetPresets) duplicatePreset(i int) int {
+	original := planetPresets.all()[i]
+	preset := &planetPreset{
+		Planet:         planetFromRecord(bodyRecordFromPlanet(original.Planet)),
+		presetMetadata: original.presetMetadata.Clone(),
+	}
+	preset.Name = copyName(original.Name, func(name string) bool {
+		return slices.ContainsFunc(planetPresets.all(), func(preset *planetPreset) bool { return preset.Name == name })
+	})
+	preset.Created = time.Time{}
+	preset.Touch()
+
+	index := len(planetPresets.presets)
+	if !planetPresets.isBuiltIn(i) {
+		index = i - len(planetPresets.builtInPresets) + 1
+	}
+	planetPresets.presets = slices.Insert(planetPresets.presets, index, preset)
+	planetPresets.saveToFile()
+
+	return len(planetPresets.builtInPresets) + index
+}
+
+// movePreset moves the preset at index i of all up (-1) or down (1) in its
+// folder and returns its new index in all.
+func (planetPresets *planetPresets) movePreset(i int, delta int) int {
+	if planetPresets.isBuiltIn(i) {
+		return i
+	}
+
+	offset := len(planetPresets.builtInPresets)
+	index := movePreset(planetPresets.presets, i-offset, delta, func(preset *planetPreset) string { return preset.Folder })
+	planetPresets.saveToFile()
+
+	return offset + index
+}
+
+// editPreset saves the changes to the preset at index i of all.
+func (planetPresets *planetPresets) editPreset(i int) {
+	if planetPresets.isBuiltIn(i) {
+		return
+	}
+
+	planetPresets.all()[i].Touch()
+	planetPresets.saveToFile()
 }
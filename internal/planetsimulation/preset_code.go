@@ -0,0 +1,157 @@
+package planetsimulation
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"PlanetSimulation/internal/schema"
+)
+
+// A preset code is a simulation preset as text that can be pasted into a
+// chat: the prefix followed by URL-safe base64 of the deflated JSON record
+// and the CRC-32 of the deflated bytes.
+//
+//	PS1.<base64(deflate(json) + crc32)>
+const presetCodePrefix = "PS1."
+
+// maxPresetCodeSize limits the decompressed JSON so a crafted code can't
+// exhaust the memory.
+const maxPresetCodeSize = 1 << 20
+
+var errInvalidPresetCode = errors.New("invalid preset code")
+
+func encodePresetCode(preset *simulationPreset) (string, error) {
+	content, err := json.Marshal(simulationPresetRecordFromPreset(preset))
+	if err != nil {
+		return "", err
+	}
+
+	compressed := bytes.Buffer{}
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(content); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	data := binary.BigEndian.AppendUint32(compressed.Bytes(), crc32.ChecksumIEEE(compressed.Bytes()))
+	return presetCodePrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodePresetCode returns the preset of a code. Whitespace is ignored since
+// chats tend to wrap long lines.
+func decodePresetCode(code string) (*simulationPreset, error) {
+	code = strings.Join(strings.Fields(code), "")
+	if code == "" {
+		return nil, fmt.Errorf("%w: the code is empty", errInvalidPresetCode)
+	}
+	if !strings.HasPrefix(code, presetCodePrefix) {
+		return nil, fmt.Errorf("%w: it doesn't start with %s", errInvalidPresetCode, presetCodePrefix)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(code, presetCodePrefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPresetCode, err)
+	}
+	if len(data) < crc32.Size {
+		return nil, fmt.Errorf("%w: the code is too short", errInvalidPresetCode)
+	}
+
+	compressed, checksum := data[:len(data)-crc32.Size], binary.BigEndian.Uint32(data[len(data)-crc32.Size:])
+	if crc32.ChecksumIEEE(compressed) != checksum {
+		return nil, fmt.Errorf("%w: the checksum doesn't match, the code was changed or is incomplete", errInvalidPresetCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), maxPresetCodeSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPresetCode, err)
+	}
+	if len(content) > maxPresetCodeSize {
+		return nil, fmt.Errorf("%w: the preset is larger than %d bytes", errInvalidPresetCode, maxPresetCodeSize)
+	}
+
+	record := simulationPresetRecord{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&record); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPresetCode, schema.JSONError(content, err))
+	}
+	if err := record.Validate("preset"); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidPresetCode, err)
+	}
+
+	return simulationPresetFromRecord(record), nil
+}
+
+// handleCodes copies the preset at codeIndex of all as code or adds the
+// preset of a pasted code to the presets of the user.
+func (presets *simulationPresets) handleCodes() {
+	if presets.shouldCopyCode {
+		presets.shouldCopyCode = false
+		presets.codeErr = nil
+
+		all := presets.all()
+		if presets.codeIndex < 0 || presets.codeIndex >= len(all) {
+			return
+		}
+		preset := all[presets.codeIndex]
+
+		code, err := encodePresetCode(preset)
+		if err != nil {
+			presets.codeErr = fmt.Errorf("failed to encode %s: %w", preset.Name, err)
+			return
+		}
+		// the code stays in the field to copy it by hand without a clipboard
+		presets.code = code
+		if err := writeClipboard(code); err != nil {
+			presets.codeErr = fmt.Errorf("failed to copy the code: %w", err)
+			return
+		}
+		presets.codeStatus = fmt.Sprintf("Copied %s (%d characters)", preset.Name, len(code))
+	}
+
+	if presets.shouldPasteCode {
+		presets.shouldPasteCode = false
+		presets.codeErr = nil
+
+		code, err := readClipboard()
+		// without a clipboard the code can be pasted into the field by hand
+		if err != nil && strings.TrimSpace(presets.code) == "" {
+			presets.codeErr = fmt.Errorf("failed to paste the code: %w", err)
+			return
+		}
+		if err == nil {
+			presets.code = code
+		}
+		presets.shouldAddCode = true
+	}
+
+	if presets.shouldAddCode {
+		presets.shouldAddCode = false
+		presets.codeErr = nil
+
+		preset, err := decodePresetCode(presets.code)
+		if err != nil {
+			presets.codeErr = err
+			return
+		}
+
+		preset.Touch()
+		presets.Presets = append(presets.Presets, preset)
+		presets.saveToFile()
+		presets.code = ""
+		presets.codeStatus = fmt.Sprintf("Added %s with %d bodies", preset.Name, len(preset.Planets))
+	}
+}
@@ -0,0 +1,55 @@
+package planetsimulation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPresetCodeRoundTrip(t *testing.T) {
+	preset := figureEightPreset()
+	code, err := encodePresetCode(preset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// chats wrap long lines
+	decoded, err := decodePresetCode(code[:20] + "\n  " + code[20:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != preset.Name || decoded.TimeStep != preset.TimeStep || len(decoded.Planets) != len(preset.Planets) {
+		t.Fatalf("got %+v, want %+v", decoded, preset)
+	}
+	for i, planet := range decoded.Planets {
+		if bodyRecordFromPlanet(planet) != bodyRecordFromPlanet(preset.Planets[i]) {
+			t.Errorf("planet %d = %+v, want %+v", i, bodyRecordFromPlanet(planet), bodyRecordFromPlanet(preset.Planets[i]))
+		}
+	}
+}
+
+func TestInvalidPresetCodes(t *testing.T) {
+	code, err := encodePresetCode(figureEightPreset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(code)
+	tampered[len(presetCodePrefix)+10] ^= 1
+
+	tests := map[string]string{
+		"empty":      "",
+		"no prefix":  strings.TrimPrefix(code, presetCodePrefix),
+		"tampered":   string(tampered),
+		"cut off":    code[:len(code)-3],
+		"not base64": presetCodePrefix + "***",
+		"too short":  presetCodePrefix + "AA",
+	}
+
+	for name, code := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodePresetCode(code); !errors.Is(err, errInvalidPresetCode) {
+				t.Errorf("err = %v, want %v", err, errInvalidPresetCode)
+			}
+		})
+	}
+}
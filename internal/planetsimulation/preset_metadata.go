@@ -0,0 +1,94 @@
+package planetsimulation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// parseTags splits comma separated tags, leading # are dropped.
+func parseTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(tag), "#"))
+		if tag == "" || slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+func formatTags(tags []string) string {
+	return strings.Join(tags, ", ")
+}
+
+// copyName returns a name for a copy of name that isn't taken yet.
+func copyName(name string, isTaken func(name string) bool) string {
+	copied := name + " copy"
+	for n := 2; isTaken(copied); n++ {
+		copied = fmt.Sprintf("%s copy %d", name, n)
+	}
+
+	return copied
+}
+
+// movePreset swaps the preset at index with the next one in direction delta
+// (-1 or 1) that is in the same folder, so the order within a folder changes
+// visibly. It returns the new index.
+func movePreset[T any](presets []T, index int, delta int, folder func(preset T) string) int {
+	for i := index + delta; i >= 0 && i < len(presets); i += delta {
+		if folder(presets[i]) == folder(presets[index]) {
+			presets[i], presets[index] = presets[index], presets[i]
+			return i
+		}
+	}
+
+	return index
+}
+
+// presetBrowser is the state of a presets window.
+type presetBrowser struct {
+	filter string
+	// index into all of the preset with shown details, -1 if none
+	selected int
+	// index into all of the preset waiting for the confirmation to delete
+	// it, -1 if none
+	pendingDelete int
+	// tags of the selected preset while they are edited
+	tags string
+}
+
+func newPresetBrowser() *presetBrowser {
+	return &presetBrowser{
+		selected:      -1,
+		pendingDelete: -1,
+	}
+}
+
+func (browser *presetBrowser) selectPreset(i int, metadata presetMetadata) {
+	browser.selected = i
+	browser.tags = formatTags(metadata.Tags)
+}
+
+// folders groups the indices of the presets that match the filter by
+// folder, in the order the folders first appear. Presets without a folder
+// come first.
+func folders(count int, filter func(i int) bool, folder func(i int) string) ([]string, map[string][]int) {
+	names := []string{""}
+	indices := map[string][]int{}
+	for i := range count {
+		if !filter(i) {
+			continue
+		}
+
+		name := folder(i)
+		if _, ok := indices[name]; !ok && name != "" {
+			names = append(names, name)
+		}
+		indices[name] = append(indices[name], i)
+	}
+
+	return names, indices
+}
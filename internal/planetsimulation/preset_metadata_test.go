@@ -0,0 +1,30 @@
+package planetsimulation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMovePresetStaysInFolder(t *testing.T) {
+	presets := []*simulationPreset{
+		{Name: "a", presetMetadata: presetMetadata{Folder: "x"}},
+		{Name: "b", presetMetadata: presetMetadata{Folder: "y"}},
+		{Name: "c", presetMetadata: presetMetadata{Folder: "x"}},
+	}
+	folder := func(preset *simulationPreset) string { return preset.Folder }
+
+	if index := movePreset(presets, 0, 1, folder); index != 2 {
+		t.Errorf("index = %d, want 2", index)
+	}
+	if index := movePreset(presets, 1, 1, folder); index != 1 {
+		t.Errorf("moved b out of its folder to %d", index)
+	}
+
+	names := []string{}
+	for _, preset := range presets {
+		names = append(names, preset.Name)
+	}
+	if want := []string{"c", "b", "a"}; !slices.Equal(names, want) {
+		t.Errorf("order = %v, want %v", names, want)
+	}
+}
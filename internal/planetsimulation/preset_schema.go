@@ -0,0 +1,97 @@
+package planetsimulation
+
+import (
+	"image/color"
+
+	"PlanetSimulation/internal/physics"
+	"PlanetSimulation/internal/schema"
+)
+
+// the file format of the presets is shared with the headless runs, see
+// internal/schema
+type (
+	colorRecord            = schema.Color
+	traceRecord            = schema.Trace
+	bodyRecord             = schema.Body
+	simulationPresetRecord = schema.SimulationPreset
+	simulationPresetsFile  = schema.SimulationPresetsFile
+	planetPresetRecord     = schema.PlanetPreset
+	planetPresetsFile      = schema.PlanetPresetsFile
+	presetMetadata         = schema.Metadata
+	validationError        = schema.ValidationError
+)
+
+func bodyRecordFromPlanet(p *Planet) bodyRecord {
+	return bodyRecord{
+		Name:     p.Name,
+		X:        p.X,
+		Y:        p.Y,
+		Velocity: physics.Vector(p.Velocity),
+		Mass:     p.Mass,
+		Radius:   p.Radius,
+		Color:    colorRecord{R: p.Color.R, G: p.Color.G, B: p.Color.B, A: p.Color.A},
+		Trace: traceRecord{
+			Width:          p.TraceWidth,
+			EveryNTick:     p.TraceEveryNTick,
+			DrawEveryNTick: p.DrawEveryNTick,
+			Antialias:      p.AntialiasTraces,
+		},
+	}
+}
+
+// planetFromRecord returns a planet holding the values of the record. It has
+// no image, newPlanet has to be used to put it into a simulation.
+func planetFromRecord(record bodyRecord) *Planet {
+	return &Planet{
+		Name:            record.Name,
+		X:               record.X,
+		Y:               record.Y,
+		Velocity:        vector2(record.Velocity),
+		Mass:            record.Mass,
+		Radius:          record.Radius,
+		Color:           color.NRGBA{record.Color.R, record.Color.G, record.Color.B, record.Color.A},
+		TraceWidth:      record.Trace.Width,
+		TraceEveryNTick: record.Trace.EveryNTick,
+		DrawEveryNTick:  record.Trace.DrawEveryNTick,
+		AntialiasTraces: record.Trace.Antialias,
+	}
+}
+
+func planetPresetFromRecord(record planetPresetRecord) *planetPreset {
+	return &planetPreset{
+		Planet:         planetFromRecord(record.Body),
+		presetMetadata: record.Metadata.Clone(),
+	}
+}
+
+func simulationPresetRecordFromPreset(preset *simulationPreset) simulationPresetRecord {
+	record := simulationPresetRecord{
+		Name:                  preset.Name,
+		Metadata:              preset.presetMetadata.Clone(),
+		GravitationalConstant: preset.GravitationalConstant,
+		Integrator:            preset.Integrator,
+		TimeStep:              preset.TimeStep,
+		Bodies:                make([]bodyRecord, len(preset.Planets)),
+	}
+	for i, planet := range preset.Planets {
+		record.Bodies[i] = bodyRecordFromPlanet(planet)
+	}
+
+	return record
+}
+
+func simulationPresetFromRecord(record simulationPresetRecord) *simulationPreset {
+	preset := &simulationPreset{
+		Name:                  record.Name,
+		presetMetadata:        record.Metadata.Clone(),
+		GravitationalConstant: record.GravitationalConstant,
+		Integrator:            record.Integrator,
+		TimeStep:              record.TimeStep,
+		Planets:               make([]*Planet, len(record.Bodies)),
+	}
+	for i, body := range record.Bodies {
+		preset.Planets[i] = planetFromRecord(body)
+	}
+
+	return preset
+}
@@ -0,0 +1,195 @@
+package planetsimulation
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+type frameKind int
+
+const (
+	frameInertial frameKind = iota
+	frameCenterOfMass
+	frameBody
+	frameCoRotating
+)
+
+var frameKindNames = []string{
+	"inertial",
+	"centre of mass",
+	"body centred",
+	"co-rotating pair",
+}
+
+// tracePoint is a position in world space together with the tick it was
+// recorded at, so it can be projected into any reference frame later on.
+type tracePoint struct {
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Tick int     `json:"tick"`
+}
+
+// referenceFrame decides how traces are drawn. Traces are always recorded in
+// the inertial frame and every point is moved by how the frame moved (and
+// rotated) between the tick it was recorded at and now.
+type referenceFrame struct {
+	kind frameKind
+	// reference planet of the body centred frame and first of the pair
+	planet *Planet
+	// second planet of the co-rotating pair
+	otherPlanet *Planet
+}
+
+// positionAt linearly interpolates the recorded trace at tick, clamping to the
+// first and last recorded points.
+func positionAt(trace []tracePoint, tick int) (float64, float64, bool) {
+	if len(trace) == 0 {
+		return 0, 0, false
+	}
+
+	i := sort.Search(len(trace), func(i int) bool {
+		return trace[i].Tick >= tick
+	})
+
+	if i == 0 {
+		return trace[0].X, trace[0].Y, true
+	}
+	if i == len(trace) {
+		last := trace[len(trace)-1]
+		return last.X, last.Y, true
+	}
+
+	previous, next := trace[i-1], trace[i]
+	t := float64(tick-previous.Tick) / float64(next.Tick-previous.Tick)
+
+	return previous.X + (next.X-previous.X)*t, previous.Y + (next.Y-previous.Y)*t, true
+}
+
+// planetPositionAt returns where p was at tick, or where it is now if nothing
+// was recorded.
+func planetPositionAt(p *Planet, tick int) (float64, float64) {
+	if tick >= 0 {
+		if x, y, ok := positionAt(p.traces, tick); ok {
+			return x, y
+		}
+	}
+
+	return p.X, p.Y
+}
+
+// isValid reports whether the planets the frame refers to still exist.
+func (frame *referenceFrame) isValid(planets []*Planet) bool {
+	switch frame.kind {
+	case frameBody:
+		return slices.Contains(planets, frame.planet)
+	case frameCoRotating:
+		return frame.planet != frame.otherPlanet && slices.Contains(planets, frame.planet) && slices.Contains(planets, frame.otherPlanet)
+	}
+
+	return true
+}
+
+// origin returns the origin and rotation of the frame at tick. A negative
+// tick means now.
+func (frame *referenceFrame) origin(planetHandler *planetHandler, tick int) (float64, float64, float64) {
+	switch frame.kind {
+	case frameCenterOfMass:
+		if tick >= 0 {
+			if x, y, ok := positionAt(planetHandler.centerOfMassTrace, tick); ok {
+				return x, y, 0
+			}
+		}
+		x, y := planetHandler.centerOfMass()
+		return x, y, 0
+	case frameBody:
+		x, y := planetPositionAt(frame.planet, tick)
+		return x, y, 0
+	case frameCoRotating:
+		x1, y1 := planetPositionAt(frame.planet, tick)
+		x2, y2 := planetPositionAt(frame.otherPlanet, tick)
+		m1, m2 := frame.planet.Mass, frame.otherPlanet.Mass
+
+		return (x1*m1 + x2*m2) / (m1 + m2), (y1*m1 + y2*m2) / (m1 + m2), math.Atan2(y2-y1, x2-x1)
+	}
+
+	return 0, 0, 0
+}
+
+// projector returns a function that maps a recorded point to the world
+// position it has in the frame, relative to where the frame is now.
+func (frame *referenceFrame) projector(planetHandler *planetHandler) func(point tracePoint) (float64, float64) {
+	if frame.kind == frameInertial || !frame.isValid(planetHandler.planets) {
+		return func(point tracePoint) (float64, float64) {
+			return point.X, point.Y
+		}
+	}
+
+	originX, originY, angle := frame.origin(planetHandler, -1)
+
+	return func(point tracePoint) (float64, float64) {
+		pointOriginX, pointOriginY, pointAngle := frame.origin(planetHandler, point.Tick)
+		dx, dy := point.X-pointOriginX, point.Y-pointOriginY
+
+		// rotate from the orientation back then to the current one
+		rotation := angle - pointAngle
+		sin, cos := math.Sincos(rotation)
+
+		return originX + dx*cos - dy*sin, originY + dx*sin + dy*cos
+	}
+}
+
+func (handler *planetHandler) centerOfMass() (float64, float64) {
+	x, y, mass := 0.0, 0.0, 0.0
+
+	for _, planet := range handler.planets {
+		x += planet.X * planet.Mass
+		y += planet.Y * planet.Mass
+		mass += planet.Mass
+	}
+
+	if mass == 0 {
+		return 0, 0
+	}
+
+	return x / mass, y / mass
+}
+
+// recordCenterOfMass records the centre of mass as often as the planet that
+// traces most often. Points older than the longest planet trace are never
+// looked up and are dropped.
+func (handler *planetHandler) recordCenterOfMass() {
+	interval, oldest := 0, handler.tick
+	for _, planet := range handler.planets {
+		if interval == 0 || planet.TraceEveryNTick < interval {
+			interval = planet.TraceEveryNTick
+		}
+		if len(planet.traces) > 0 {
+			oldest = min(oldest, planet.traces[0].Tick)
+		}
+	}
+
+	trace := handler.centerOfMassTrace
+	if n := len(trace); n > 0 && handler.tick >= trace[n-1].Tick && handler.tick-trace[n-1].Tick < max(interval, 1) {
+		return
+	}
+	x, y := handler.centerOfMass()
+	trace = append(trace, tracePoint{x, y, handler.tick})
+
+	// the last point before the oldest trace point is kept to interpolate
+	i := sort.Search(len(trace), func(i int) bool {
+		return trace[i].Tick > oldest
+	})
+	if i > 1 {
+		trace = slices.Delete(trace, 0, i-1)
+	}
+	handler.centerOfMassTrace = trace
+}
+
+func (handler *planetHandler) clearTraces() {
+	for _, planet := range handler.planets {
+		planet.clearTraces()
+	}
+
+	handler.centerOfMassTrace = handler.centerOfMassTrace[:0]
+}
@@ -0,0 +1,125 @@
+package planetsimulation
+
+import (
+	"math"
+	"testing"
+)
+
+// newRotatingPair returns two planets circling their centre of mass, which
+// drifts along x, with traces of every tick up to tick.
+func newRotatingPair(tick int) *planetHandler {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{})
+	b := newTestPlanet("b", 0, 0, 1, 3, vector2{})
+	positions := func(tick int) (float64, float64, float64, float64) {
+		angle := 0.1 * float64(tick)
+		centerX := 2 * float64(tick)
+		sin, cos := math.Sincos(angle)
+		// the heavier planet is closer to the centre of mass
+		return centerX + 30*cos, 30 * sin, centerX - 10*cos, -10 * sin
+	}
+
+	centerOfMassTrace := []tracePoint{}
+	for i := 0; i <= tick; i++ {
+		ax, ay, bx, by := positions(i)
+		a.traces = append(a.traces, tracePoint{ax, ay, i})
+		b.traces = append(b.traces, tracePoint{bx, by, i})
+		centerOfMassTrace = append(centerOfMassTrace, tracePoint{(ax + 3*bx) / 4, (ay + 3*by) / 4, i})
+	}
+	ax, ay, bx, by := positions(tick)
+	a.setPosition(ax, ay)
+	b.setPosition(bx, by)
+
+	planetHandler := newTestPlanetHandler(a, b)
+	planetHandler.tick = tick
+	planetHandler.centerOfMassTrace = centerOfMassTrace
+
+	return planetHandler
+}
+
+func TestFrameProjector(t *testing.T) {
+	const tick = 40
+	planetHandler := newRotatingPair(tick)
+	a, b := planetHandler.planets[0], planetHandler.planets[1]
+
+	tests := []struct {
+		name  string
+		frame referenceFrame
+		// the planet that stands still in the frame
+		planet *Planet
+	}{
+		{"body centred", referenceFrame{kind: frameBody, planet: a}, a},
+		{"co-rotating", referenceFrame{kind: frameCoRotating, planet: a, otherPlanet: b}, b},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := test.frame.projector(planetHandler)
+			// the pair doesn't move in the co-rotating frame and the body
+			// centred frame keeps the reference planet in place
+			for _, point := range test.planet.traces {
+				x, y := project(point)
+				if math.Hypot(x-test.planet.X, y-test.planet.Y) > 1e-9 {
+					t.Fatalf("point at tick %d is drawn at %v, %v, want %v, %v", point.Tick, x, y, test.planet.X, test.planet.Y)
+				}
+			}
+		})
+	}
+
+	// the centre of mass frame takes away the drift, the planets circle
+	// around where the centre of mass is now
+	project := (&referenceFrame{kind: frameCenterOfMass}).projector(planetHandler)
+	centerX, centerY := planetHandler.centerOfMass()
+	for _, point := range b.traces {
+		x, y := project(point)
+		if distance := math.Hypot(x-centerX, y-centerY); math.Abs(distance-10) > 1e-9 {
+			t.Fatalf("point at tick %d is %v from the centre of mass, want 10", point.Tick, distance)
+		}
+	}
+
+	// the inertial frame draws what was recorded
+	project = (&referenceFrame{kind: frameInertial}).projector(planetHandler)
+	if x, y := project(a.traces[3]); x != a.traces[3].X || y != a.traces[3].Y {
+		t.Errorf("inertial point is drawn at %v, %v, want %v, %v", x, y, a.traces[3].X, a.traces[3].Y)
+	}
+}
+
+func TestInvalidFrameFallsBackToInertial(t *testing.T) {
+	planetHandler := newRotatingPair(10)
+	gone := newTestPlanet("gone", 0, 0, 1, 1, vector2{})
+	frame := referenceFrame{kind: frameBody, planet: gone}
+
+	point := planetHandler.planets[0].traces[2]
+	if x, y := frame.projector(planetHandler)(point); x != point.X || y != point.Y {
+		t.Errorf("point is drawn at %v, %v, want %v, %v", x, y, point.X, point.Y)
+	}
+}
+
+func TestCenterOfMassTraceIsBounded(t *testing.T) {
+	a := newTestPlanet("a", 0, 0, 1, 1, vector2{10, 0})
+	b := newTestPlanet("b", 100, 0, 1, 1, vector2{-10, 0})
+	planetHandler := newTestPlanetHandler(a, b)
+
+	for range 200 {
+		planetHandler.step(0.001)
+	}
+
+	trace := planetHandler.centerOfMassTrace
+	if longest := max(len(a.traces), len(b.traces)); len(trace) > longest+1 {
+		t.Errorf("%d centre of mass points for planet traces of %d points", len(trace), longest)
+	}
+	for i := 1; i < len(trace); i++ {
+		if trace[i].Tick-trace[i-1].Tick < a.TraceEveryNTick {
+			t.Fatalf("points at ticks %d and %d are closer than the trace interval %d", trace[i-1].Tick, trace[i].Tick, a.TraceEveryNTick)
+		}
+	}
+
+	// without older planet traces the older points aren't needed anymore
+	a.clearTraces()
+	b.clearTraces()
+	for range 20 {
+		planetHandler.step(0.001)
+	}
+	if trace := planetHandler.centerOfMassTrace; len(trace) > 6 || trace[0].Tick > a.traces[0].Tick {
+		t.Errorf("centre of mass trace %v doesn't start right before the planet traces at tick %d", trace, a.traces[0].Tick)
+	}
+}
@@ -0,0 +1,319 @@
+package planetsimulation
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+)
+
+// replay records the session into a replay file and plays it back. While a
+// replay plays, the live simulation is put aside and continues unchanged
+// when the replay is stopped.
+type replay struct {
+	filePath             string
+	RecordEveryNTick     int
+	KeyframeEveryNFrames int
+	isRecording          bool
+	lastTick             int
+	frames               int
+	file                 *os.File
+	writer               *bufio.Writer
+	compressor           *flate.Writer
+	encoder              *replayEncoder
+	buffer               []byte
+	player               *replayPlayer
+	shouldStartRecording bool
+	shouldStopRecording  bool
+	shouldPlay           bool
+	shouldStopPlaying    bool
+	status               string
+	err                  error
+}
+
+// replayPlayer shows the frames of a replay file in its own planet handler.
+type replayPlayer struct {
+	file          *replayFile
+	state         *replayState
+	frame         int
+	liveHandler   *planetHandler
+	replayHandler *planetHandler
+	// Position is the frame on the timeline, fractional while playing slower
+	// than real time
+	Position float64
+	// 1 plays at the recorded speed, negative values play backwards
+	Speed    float64
+	isPaused bool
+}
+
+func newReplay() *replay {
+	return &replay{
+		RecordEveryNTick:     2,
+		KeyframeEveryNFrames: 120,
+	}
+}
+
+func (replay *replay) isPlaying() bool {
+	return replay.player != nil
+}
+
+func (replay *replay) startRecording(planetHandler *planetHandler) error {
+	if replay.isRecording {
+		return errors.New("already recording")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(replay.filePath), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(replay.filePath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	compressor, err := flate.NewWriter(writer, flate.DefaultCompression)
+	if err == nil {
+		err = writeReplayHeader(writer)
+	}
+	if err == nil {
+		_, err = compressor.Write(binary.AppendUvarint(nil, uint64(max(replay.RecordEveryNTick, 1))))
+	}
+	if err != nil {
+		return errors.Join(err, file.Close())
+	}
+
+	replay.file = file
+	replay.writer = writer
+	replay.compressor = compressor
+	replay.encoder = newReplayEncoder()
+	replay.isRecording = true
+	replay.frames = 0
+
+	if err := replay.write(planetHandler); err != nil {
+		return errors.Join(err, replay.stopRecording())
+	}
+
+	return nil
+}
+
+func (replay *replay) stopRecording() error {
+	if !replay.isRecording {
+		return nil
+	}
+	replay.isRecording = false
+
+	err := replay.compressor.Close()
+	err = errors.Join(err, replay.writer.Flush())
+	return errors.Join(err, replay.file.Close())
+}
+
+func (replay *replay) write(planetHandler *planetHandler) error {
+	replay.lastTick = planetHandler.tick
+
+	isKeyframe := replay.frames%max(replay.KeyframeEveryNFrames, 1) == 0
+	replay.buffer = replay.encoder.encode(replay.buffer[:0], planetHandler.tick, planetHandler.simulatedTime, planetHandler.planets, isKeyframe)
+	replay.frames++
+	if _, err := replay.compressor.Write(replay.buffer); err != nil {
+		return err
+	}
+
+	// a replay cut off by a crash still plays up to the last keyframe
+	if isKeyframe {
+		if err := replay.compressor.Flush(); err != nil {
+			return err
+		}
+		return replay.writer.Flush()
+	}
+
+	return nil
+}
+
+// record writes a frame if enough ticks have passed since the last one.
+// Errors stop the recording and are kept in err.
+func (replay *replay) record(planetHandler *planetHandler) {
+	if !replay.isRecording || planetHandler.tick == replay.lastTick {
+		return
+	}
+	// a loaded snapshot can go back in time
+	if planetHandler.tick > replay.lastTick && planetHandler.tick-replay.lastTick < max(replay.RecordEveryNTick, 1) {
+		return
+	}
+
+	if err := replay.write(planetHandler); err != nil {
+		replay.err = errors.Join(err, replay.stopRecording())
+	}
+}
+
+// startPlaying puts the live simulation aside and shows the first frame.
+func (sim *simulation) startPlaying() error {
+	replay := sim.replay
+	content, err := os.ReadFile(replay.filePath)
+	if err != nil {
+		return err
+	}
+	file, err := decodeReplay(content)
+	if err != nil {
+		return err
+	}
+
+	live := sim.planetHandler
+	// the replay gets its own camera and no editing state
+	replayHandler := live.clone()
+	replayHandler.planetCreator = newPlanetCreator()
+	replayHandler.planetPresets = live.planetPresets
+	replayHandler.planets = nil
+	replayHandler.running = false
+	replayHandler.selectedPlanet = selectedPlanet{}
+	replayHandler.focusedPlanet = focusedPlanet{}
+	replayHandler.frame = referenceFrame{}
+	replayHandler.centerOfMassTrace = nil
+
+	replay.player = &replayPlayer{
+		file:          file,
+		state:         newReplayState(),
+		frame:         -1,
+		liveHandler:   live,
+		replayHandler: replayHandler,
+		Speed:         1,
+		isPaused:      true,
+	}
+	sim.planetHandler = replayHandler
+
+	return replay.player.seek(0)
+}
+
+func (sim *simulation) stopPlaying() {
+	if player := sim.replay.player; player != nil {
+		sim.planetHandler = player.liveHandler
+		sim.replay.player = nil
+	}
+}
+
+func (player *replayPlayer) lastFrame() int {
+	return len(player.file.index) - 1
+}
+
+// seek shows the frame at index, the traces are only kept while moving
+// forwards frame by frame.
+func (player *replayPlayer) seek(index int) error {
+	index = max(0, min(index, player.lastFrame()))
+	if index == player.frame {
+		return nil
+	}
+
+	isNextFrame := index == player.frame+1
+	if err := player.file.seek(player.state, player.frame, index); err != nil {
+		return err
+	}
+	player.frame = index
+	player.sync(isNextFrame)
+
+	return nil
+}
+
+// sync makes the planets of the replay handler match the state.
+func (player *replayPlayer) sync(keepTraces bool) {
+	handler := player.replayHandler
+	state := player.state
+
+	planetsByID := map[int]*Planet{}
+	for _, planet := range handler.planets {
+		planetsByID[planet.id] = planet
+	}
+
+	planets := make([]*Planet, 0, len(state.order))
+	for _, id := range state.order {
+		body := state.bodies[id]
+		c := color.NRGBA{body.color.R, body.color.G, body.color.B, body.color.A}
+
+		planet, ok := planetsByID[id]
+		if !ok || planet.Radius != body.radius || planet.Color != c {
+			previous := planet
+			planet = newPlanet(body.name, body.position.X, body.position.Y, body.radius, body.mass, body.velocity, c, handler.planetsOffset)
+			planet.id = id
+			if previous != nil {
+				planet.traces = previous.traces
+			}
+		}
+
+		planet.Name = body.name
+		planet.Mass = body.mass
+		planet.Velocity = body.velocity
+		planet.setPosition(body.position.X, body.position.Y)
+		if keepTraces {
+			planet.updateTraces(state.tick)
+		} else {
+			planet.clearTraces()
+		}
+		planets = append(planets, planet)
+	}
+
+	handler.planets = planets
+	handler.tick = state.tick
+	handler.simulatedTime = state.time
+	handler.hierarchy.update(handler)
+}
+
+// Update advances the position by the speed and shows its frame.
+func (player *replayPlayer) Update() error {
+	if !player.isPaused {
+		player.Position += player.Speed / float64(player.file.recordEveryNTick)
+		if player.Position <= 0 || player.Position >= float64(player.lastFrame()) {
+			player.Position = max(0, min(player.Position, float64(player.lastFrame())))
+			player.isPaused = true
+		}
+	}
+
+	return player.seek(int(player.Position))
+}
+
+func (sim *simulation) handleReplay() {
+	replay := sim.replay
+
+	if replay.shouldStartRecording {
+		replay.shouldStartRecording = false
+		replay.err = nil
+		if err := replay.startRecording(sim.planetHandler); err != nil {
+			replay.err = fmt.Errorf("failed to record %s: %w", replay.filePath, err)
+		} else {
+			replay.status = "Recording to " + replay.filePath
+		}
+	}
+
+	if replay.shouldStopRecording {
+		replay.shouldStopRecording = false
+		if err := replay.stopRecording(); err != nil {
+			replay.err = fmt.Errorf("failed to save %s: %w", replay.filePath, err)
+		} else {
+			replay.status = fmt.Sprintf("Recorded %d frames", replay.frames)
+		}
+	}
+
+	if replay.shouldPlay {
+		replay.shouldPlay = false
+		replay.err = nil
+		// the replay would record itself
+		if replay.isRecording {
+			replay.err = errors.New("stop recording before playing")
+		} else if err := sim.startPlaying(); err != nil {
+			sim.stopPlaying()
+			replay.err = fmt.Errorf("failed to play %s: %w", replay.filePath, err)
+		}
+	}
+
+	if replay.shouldStopPlaying {
+		replay.shouldStopPlaying = false
+		sim.stopPlaying()
+	}
+
+	if replay.isPlaying() {
+		if err := replay.player.Update(); err != nil {
+			sim.stopPlaying()
+			replay.err = fmt.Errorf("failed to play %s: %w", replay.filePath, err)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package planetsimulation
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// A replay stores the states of the planets, not the inputs, so it plays the
+// same no matter how the physics change. The file is
+//
+//	magic "PSREPLAY", version uint16, then deflated:
+//	recordEveryNTick uvarint
+//	frames until the end
+//
+// and every frame
+//
+//	kind byte ('K' keyframe or 'D' delta), tick varint, time float64
+//	keyframe: count uvarint, body...
+//	delta:    removed count uvarint, id uvarint...
+//	          changed count uvarint, body...
+//	          moved count uvarint, (id uvarint, dx, dy, dvx, dvy float32)...
+//
+// with a body being id uvarint, name (length uvarint, bytes), x, y, vx, vy,
+// mass, radius float64 and the color as r, g, b, a bytes. Numbers are little
+// endian. Deltas are relative to the decoded state of the previous frame, so
+// the rounding to float32 doesn't add up over many frames.
+const (
+	replayMagic       = "PSREPLAY"
+	replayFileVersion = 1
+	replayKeyframe    = 'K'
+	replayDelta       = 'D'
+)
+
+type replayBody struct {
+	id       int
+	name     string
+	position vector2
+	velocity vector2
+	mass     float64
+	radius   float64
+	color    colorRecord
+}
+
+func replayBodyFromPlanet(planet *Planet) replayBody {
+	return replayBody{
+		id:       planet.id,
+		name:     planet.Name,
+		position: vector2{planet.X, planet.Y},
+		velocity: planet.Velocity,
+		mass:     planet.Mass,
+		radius:   planet.Radius,
+		color:    colorRecord{R: planet.Color.R, G: planet.Color.G, B: planet.Color.B, A: planet.Color.A},
+	}
+}
+
+// replayState is the state of all bodies in one frame.
+type replayState struct {
+	tick   int
+	time   float64
+	order  []int
+	bodies map[int]replayBody
+}
+
+func newReplayState() *replayState {
+	return &replayState{
+		bodies: map[int]replayBody{},
+	}
+}
+
+func (state *replayState) clear() {
+	state.order = state.order[:0]
+	clear(state.bodies)
+}
+
+// set adds or replaces a body, new bodies are put at the end.
+func (state *replayState) set(body replayBody) {
+	if _, ok := state.bodies[body.id]; !ok {
+		state.order = append(state.order, body.id)
+	}
+	state.bodies[body.id] = body
+}
+
+func (state *replayState) remove(id int) {
+	delete(state.bodies, id)
+	state.order = slices.DeleteFunc(state.order, func(other int) bool {
+		return other == id
+	})
+}
+
+// replayEncoder turns the planets into frames.
+type replayEncoder struct {
+	// the state as the decoder will see it
+	state *replayState
+}
+
+func newReplayEncoder() *replayEncoder {
+	return &replayEncoder{
+		state: newReplayState(),
+	}
+}
+
+func appendFloat64(b []byte, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func appendFloat32(b []byte, v float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+func appendReplayBody(b []byte, body replayBody) []byte {
+	b = binary.AppendUvarint(b, uint64(body.id))
+	b = binary.AppendUvarint(b, uint64(len(body.name)))
+	b = append(b, body.name...)
+	for _, v := range []float64{body.position.X, body.position.Y, body.velocity.X, body.velocity.Y, body.mass, body.radius} {
+		b = appendFloat64(b, v)
+	}
+
+	return append(b, body.color.R, body.color.G, body.color.B, body.color.A)
+}
+
+// encode appends the frame of planets to b.
+func (encoder *replayEncoder) encode(b []byte, tick int, time float64, planets []*Planet, isKeyframe bool) []byte {
+	state := encoder.state
+	state.tick = tick
+	state.time = time
+
+	if isKeyframe {
+		state.clear()
+		b = append(b, replayKeyframe)
+		b = binary.AppendVarint(b, int64(tick))
+		b = appendFloat64(b, time)
+		b = binary.AppendUvarint(b, uint64(len(planets)))
+		for _, planet := range planets {
+			body := replayBodyFromPlanet(planet)
+			state.set(body)
+			b = appendReplayBody(b, body)
+		}
+
+		return b
+	}
+
+	b = append(b, replayDelta)
+	b = binary.AppendVarint(b, int64(tick))
+	b = appendFloat64(b, time)
+
+	current := map[int]bool{}
+	for _, planet := range planets {
+		current[planet.id] = true
+	}
+	removed := []int{}
+	for _, id := range state.order {
+		if !current[id] {
+			removed = append(removed, id)
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(removed)))
+	for _, id := range removed {
+		state.remove(id)
+		b = binary.AppendUvarint(b, uint64(id))
+	}
+
+	changed := []replayBody{}
+	moved := []replayBody{}
+	for _, planet := range planets {
+		body := replayBodyFromPlanet(planet)
+		previous, ok := state.bodies[body.id]
+		if !ok || previous.name != body.name || previous.mass != body.mass || previous.radius != body.radius || previous.color != body.color {
+			changed = append(changed, body)
+		} else if previous.position != body.position || previous.velocity != body.velocity {
+			moved = append(moved, body)
+		}
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(changed)))
+	for _, body := range changed {
+		state.set(body)
+		b = appendReplayBody(b, body)
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(moved)))
+	for _, body := range moved {
+		previous := state.bodies[body.id]
+		deltas := []float32{
+			float32(body.position.X - previous.position.X),
+			float32(body.position.Y - previous.position.Y),
+			float32(body.velocity.X - previous.velocity.X),
+			float32(body.velocity.Y - previous.velocity.Y),
+		}
+		b = binary.AppendUvarint(b, uint64(body.id))
+		for _, delta := range deltas {
+			b = appendFloat32(b, delta)
+		}
+		// the same rounding as when decoding
+		previous.position.X += float64(deltas[0])
+		previous.position.Y += float64(deltas[1])
+		previous.velocity.X += float64(deltas[2])
+		previous.velocity.Y += float64(deltas[3])
+		state.bodies[body.id] = previous
+	}
+
+	return b
+}
+
+// writeReplayHeader writes the uncompressed start of a replay file.
+func writeReplayHeader(w io.Writer) error {
+	header := binary.LittleEndian.AppendUint16([]byte(replayMagic), replayFileVersion)
+	_, err := w.Write(header)
+	return err
+}
+
+// replayFrameInfo is where a frame starts in the decompressed frames.
+type replayFrameInfo struct {
+	offset     int
+	isKeyframe bool
+	tick       int
+	time       float64
+}
+
+// replayFile is a decompressed replay with an index of its frames.
+type replayFile struct {
+	recordEveryNTick int
+	frames           []byte
+	index            []replayFrameInfo
+}
+
+var errInvalidReplay = errors.New("invalid replay")
+
+// decodeReplay checks and indexes a replay. A file that was cut off, e.g.
+// because the game crashed while recording, is played up to the last
+// complete frame.
+func decodeReplay(content []byte) (*replayFile, error) {
+	headerSize := len(replayMagic) + 2
+	if len(content) < headerSize || string(content[:len(replayMagic)]) != replayMagic {
+		return nil, fmt.Errorf("%w: not a replay file", errInvalidReplay)
+	}
+	if version := binary.LittleEndian.Uint16(content[len(replayMagic):]); version != replayFileVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d, this build supports %d", errInvalidReplay, version, replayFileVersion)
+	}
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(content[headerSize:])))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", errInvalidReplay, err)
+	}
+
+	reader := bytes.NewReader(data)
+	recordEveryNTick, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the replay is empty", errInvalidReplay)
+	}
+
+	file := &replayFile{
+		recordEveryNTick: max(int(recordEveryNTick), 1),
+		frames:           data,
+	}
+	state := newReplayState()
+	for reader.Len() > 0 {
+		offset := len(data) - reader.Len()
+		isKeyframe, err := decodeReplayFrame(reader, state)
+		if err != nil {
+			break
+		}
+		// deltas need a keyframe before them
+		if len(file.index) == 0 && !isKeyframe {
+			return nil, fmt.Errorf("%w: the first frame is not a keyframe", errInvalidReplay)
+		}
+		file.index = append(file.index, replayFrameInfo{offset, isKeyframe, state.tick, state.time})
+	}
+
+	if len(file.index) == 0 {
+		return nil, fmt.Errorf("%w: the replay has no frames", errInvalidReplay)
+	}
+
+	return file, nil
+}
+
+func readFloat64(r *bytes.Reader) (float64, error) {
+	var b [8]byte
+	_, err := io.ReadFull(r, b[:])
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), err
+}
+
+func readFloat32(r *bytes.Reader) (float32, error) {
+	var b [4]byte
+	_, err := io.ReadFull(r, b[:])
+	return math.Float32frombits(binary.LittleEndian.Uint32(b[:])), err
+}
+
+func readReplayBody(r *bytes.Reader) (replayBody, error) {
+	body := replayBody{}
+	id, err := binary.ReadUvarint(r)
+	if err != nil {
+		return body, err
+	}
+	body.id = int(id)
+
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return body, err
+	}
+	if length > uint64(r.Len()) {
+		return body, io.ErrUnexpectedEOF
+	}
+	name := make([]byte, length)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return body, err
+	}
+	body.name = string(name)
+
+	for _, target := range []*float64{&body.position.X, &body.position.Y, &body.velocity.X, &body.velocity.Y, &body.mass, &body.radius} {
+		if *target, err = readFloat64(r); err != nil {
+			return body, err
+		}
+	}
+
+	var c [4]byte
+	if _, err := io.ReadFull(r, c[:]); err != nil {
+		return body, err
+	}
+	body.color = colorRecord{R: c[0], G: c[1], B: c[2], A: c[3]}
+
+	return body, nil
+}
+
+// decodeReplayFrame applies the next frame to state.
+func decodeReplayFrame(r *bytes.Reader, state *replayState) (bool, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	if kind != replayKeyframe && kind != replayDelta {
+		return false, fmt.Errorf("%w: unknown frame kind %q", errInvalidReplay, kind)
+	}
+	tick, err := binary.ReadVarint(r)
+	if err != nil {
+		return false, err
+	}
+	time, err := readFloat64(r)
+	if err != nil {
+		return false, err
+	}
+	state.tick = int(tick)
+	state.time = time
+
+	count := func() (int, error) {
+		n, err := binary.ReadUvarint(r)
+		// every entry takes at least one byte
+		if err == nil && n > uint64(r.Len()) {
+			err = io.ErrUnexpectedEOF
+		}
+		return int(n), err
+	}
+
+	if kind == replayKeyframe {
+		state.clear()
+		n, err := count()
+		if err != nil {
+			return true, err
+		}
+		for range n {
+			body, err := readReplayBody(r)
+			if err != nil {
+				return true, err
+			}
+			state.set(body)
+		}
+
+		return true, nil
+	}
+
+	n, err := count()
+	if err != nil {
+		return false, err
+	}
+	for range n {
+		id, err := binary.ReadUvarint(r)
+		if err != nil {
+			return false, err
+		}
+		state.remove(int(id))
+	}
+
+	if n, err = count(); err != nil {
+		return false, err
+	}
+	for range n {
+		body, err := readReplayBody(r)
+		if err != nil {
+			return false, err
+		}
+		state.set(body)
+	}
+
+	if n, err = count(); err != nil {
+		return false, err
+	}
+	for range n {
+		id, err := binary.ReadUvarint(r)
+		if err != nil {
+			return false, err
+		}
+		deltas := [4]float32{}
+		for i := range deltas {
+			if deltas[i], err = readFloat32(r); err != nil {
+				return false, err
+			}
+		}
+
+		body, ok := state.bodies[int(id)]
+		if !ok {
+			return false, fmt.Errorf("%w: body %d moved before it was added", errInvalidReplay, id)
+		}
+		body.position.X += float64(deltas[0])
+		body.position.Y += float64(deltas[1])
+		body.velocity.X += float64(deltas[2])
+		body.velocity.Y += float64(deltas[3])
+		state.bodies[int(id)] = body
+	}
+
+	return false, nil
+}
+
+// seek decodes the frame at index into state. state holds the frame at
+// current, -1 if none, which is continued from if possible.
+func (file *replayFile) seek(state *replayState, current int, index int) error {
+	start := index
+	for start > 0 && !file.index[start].isKeyframe {
+		start--
+	}
+	// continue forwards without going back to the keyframe
+	if current >= start && current <= index {
+		start = current + 1
+	}
+
+	for i := start; i <= index; i++ {
+		reader := bytes.NewReader(file.frames[file.index[i].offset:])
+		if _, err := decodeReplayFrame(reader, state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package planetsimulation
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"math"
+	"testing"
+)
+
+// encodeTestReplay records the frames of steps into a replay file.
+func encodeTestReplay(t *testing.T, keyframeEveryNFrames int, steps [][]*Planet) []byte {
+	t.Helper()
+
+	content := bytes.Buffer{}
+	if err := writeReplayHeader(&content); err != nil {
+		t.Fatal(err)
+	}
+	compressor, err := flate.NewWriter(&content, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []byte{1}
+	encoder := newReplayEncoder()
+	for i, planets := range steps {
+		frames = encoder.encode(frames, i, float64(i)/2, planets, i%keyframeEveryNFrames == 0)
+	}
+	if _, err := compressor.Write(frames); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return content.Bytes()
+}
+
+// replaySteps moves two planets, removes one and adds another.
+func replaySteps() [][]*Planet {
+	steps := [][]*Planet{}
+	a := newTestPlanet("a", 0, 0, 5, 10, vector2{1, 0})
+	a.id = 1
+	b := newTestPlanet("b", 100, 0, 5, 10, vector2{0, 1})
+	b.id = 2
+	c := newTestPlanet("c", 50, 50, 3, 1, vector2{0, 0})
+	c.id = 3
+
+	for i := range 10 {
+		a.X += 0.1 * float64(i)
+		b.Velocity.Y += 1.0 / 3
+		switch {
+		case i < 4:
+			steps = append(steps, []*Planet{a.clone(nil), b.clone(nil)})
+		case i < 7:
+			steps = append(steps, []*Planet{a.clone(nil)})
+		default:
+			steps = append(steps, []*Planet{a.clone(nil), c.clone(nil)})
+		}
+	}
+
+	return steps
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	steps := replaySteps()
+	file, err := decodeReplay(encodeTestReplay(t, 3, steps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.index) != len(steps) {
+		t.Fatalf("got %d frames, want %d", len(file.index), len(steps))
+	}
+
+	// forwards frame by frame, then jumping around
+	order := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 2, 8, 0, 5}
+	state := newReplayState()
+	current := -1
+	for _, index := range order {
+		if err := file.seek(state, current, index); err != nil {
+			t.Fatalf("seek %d: %v", index, err)
+		}
+		current = index
+
+		planets := steps[index]
+		if state.tick != index || len(state.order) != len(planets) {
+			t.Fatalf("frame %d: tick %d with %d bodies, want %d bodies", index, state.tick, len(state.order), len(planets))
+		}
+		for i, planet := range planets {
+			body := state.bodies[state.order[i]]
+			want := replayBodyFromPlanet(planet)
+			if body.id != want.id || body.name != want.name || body.mass != want.mass || body.color != want.color {
+				t.Errorf("frame %d: body %+v, want %+v", index, body, want)
+			}
+			if math.Abs(body.position.X-want.position.X) > 1e-4 || math.Abs(body.velocity.Y-want.velocity.Y) > 1e-4 {
+				t.Errorf("frame %d: body %s at %v moving %v, want %v moving %v", index, body.name, body.position, body.velocity, want.position, want.velocity)
+			}
+		}
+	}
+}
+
+func TestTruncatedReplay(t *testing.T) {
+	content := encodeTestReplay(t, 3, replaySteps())
+
+	file, err := decodeReplay(content[:len(content)-4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.index) == 0 || len(file.index) >= len(replaySteps()) {
+		t.Errorf("got %d frames from a cut off replay", len(file.index))
+	}
+
+	if _, err := decodeReplay(content[:len(replayMagic)]); !errors.Is(err, errInvalidReplay) {
+		t.Errorf("err = %v, want %v", err, errInvalidReplay)
+	}
+}
@@ -0,0 +1,352 @@
+package planetsimulation
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"unsafe"
+)
+
+// ringBuffer is a queue that reuses its memory, the oldest items are dropped
+// from the front.
+type ringBuffer[T any] struct {
+	items []T
+	start int
+	count int
+}
+
+func (ring *ringBuffer[T]) len() int {
+	return ring.count
+}
+
+// at returns the item at i, 0 being the oldest.
+func (ring *ringBuffer[T]) at(i int) T {
+	return ring.items[(ring.start+i)%len(ring.items)]
+}
+
+func (ring *ringBuffer[T]) push(item T) {
+	if ring.count == len(ring.items) {
+		// grow and move the items to the start
+		items := make([]T, max(2*len(ring.items), 64))
+		for i := range ring.count {
+			items[i] = ring.at(i)
+		}
+		ring.items = items
+		ring.start = 0
+	}
+
+	ring.items[(ring.start+ring.count)%len(ring.items)] = item
+	ring.count++
+}
+
+func (ring *ringBuffer[T]) popFront() T {
+	var zero T
+	item := ring.items[ring.start]
+	// don't keep the memory of dropped items alive
+	ring.items[ring.start] = zero
+	ring.start = (ring.start + 1) % len(ring.items)
+	ring.count--
+
+	return item
+}
+
+// truncate drops everything after the first n items.
+func (ring *ringBuffer[T]) truncate(n int) {
+	var zero T
+	for i := n; i < ring.count; i++ {
+		ring.items[(ring.start+i)%len(ring.items)] = zero
+	}
+	ring.count = min(ring.count, n)
+}
+
+func (ring *ringBuffer[T]) clear() {
+	ring.truncate(0)
+	ring.start = 0
+}
+
+// rewindState is the simulation at one tick. The planets are copies without
+// images and traces.
+type rewindState struct {
+	tick          int
+	simulatedTime float64
+	planets       []Planet
+	size          int
+}
+
+// estimated memory of a state on top of its planets
+const rewindStateSize = int(unsafe.Sizeof(rewindState{}))
+const rewindPlanetSize = int(unsafe.Sizeof(Planet{}))
+
+func newRewindState(planetHandler *planetHandler) rewindState {
+	state := rewindState{
+		tick:          planetHandler.tick,
+		simulatedTime: planetHandler.simulatedTime,
+		planets:       make([]Planet, len(planetHandler.planets)),
+		size:          rewindStateSize + len(planetHandler.planets)*rewindPlanetSize,
+	}
+	for i, planet := range planetHandler.planets {
+		state.planets[i] = *planet
+		state.planets[i].image = nil
+		state.planets[i].traces = nil
+		state.planets[i].Offset = nil
+		state.size += len(planet.Name)
+	}
+
+	return state
+}
+
+// rewind keeps the recent states of the simulation in memory, so they can be
+// looked at again and continued from without recording in advance. While
+// rewinding, the states are shown in a preview and the live simulation is
+// put aside.
+type rewind struct {
+	states           ringBuffer[rewindState]
+	size             int
+	BudgetMiB        int
+	RecordEveryNTick int
+	lastTick         int
+	live             *planetHandler
+	preview          *planetHandler
+	// index of the shown state
+	Position           int
+	shown              int
+	shouldStart        bool
+	shouldResume       bool
+	shouldCancel       bool
+	shouldStepBackward bool
+	shouldStepForward  bool
+	status             string
+}
+
+func newRewind() *rewind {
+	return &rewind{
+		BudgetMiB:        64,
+		RecordEveryNTick: 1,
+	}
+}
+
+func (rewind *rewind) isRewinding() bool {
+	return rewind.preview != nil
+}
+
+func (rewind *rewind) budget() int {
+	return max(rewind.BudgetMiB, 1) << 20
+}
+
+// record keeps the state of planetHandler, dropping the oldest states that
+// don't fit into the budget.
+func (rewind *rewind) record(planetHandler *planetHandler) {
+	if planetHandler.tick == rewind.lastTick && rewind.states.len() > 0 {
+		return
+	}
+	// a loaded snapshot can go back in time
+	if planetHandler.tick > rewind.lastTick && planetHandler.tick-rewind.lastTick < max(rewind.RecordEveryNTick, 1) {
+		return
+	}
+	rewind.push(planetHandler)
+}
+
+func (rewind *rewind) push(planetHandler *planetHandler) {
+	rewind.lastTick = planetHandler.tick
+	state := newRewindState(planetHandler)
+	rewind.states.push(state)
+	rewind.size += state.size
+	for rewind.size > rewind.budget() && rewind.states.len() > 1 {
+		rewind.size -= rewind.states.popFront().size
+	}
+}
+
+func (rewind *rewind) clear() {
+	rewind.states.clear()
+	rewind.size = 0
+}
+
+// duration returns the simulated time that can be rewound.
+func (rewind *rewind) duration() float64 {
+	if rewind.states.len() == 0 {
+		return 0
+	}
+
+	return rewind.states.at(rewind.states.len()-1).simulatedTime - rewind.states.at(0).simulatedTime
+}
+
+// tracesUntil returns the part of traces recorded up to tick. Appending to it
+// doesn't change traces.
+func tracesUntil(traces []tracePoint, tick int) []tracePoint {
+	n := sort.Search(len(traces), func(i int) bool {
+		return traces[i].Tick > tick
+	})
+
+	return slices.Clip(traces[:n])
+}
+
+// show puts the state at index into the preview.
+func (rewind *rewind) show(index int) {
+	state := rewind.states.at(index)
+	preview := rewind.preview
+
+	livePlanets := map[int]*Planet{}
+	for _, planet := range rewind.live.planets {
+		livePlanets[planet.id] = planet
+	}
+	shownPlanets := map[int]*Planet{}
+	for _, planet := range preview.planets {
+		shownPlanets[planet.id] = planet
+	}
+	selected := planetID(preview.planets, preview.selectedPlanet.index)
+	focused := planetID(preview.planets, preview.focusedPlanet.index)
+	framePlanet, frameOtherPlanet := 0, 0
+	if preview.frame.planet != nil {
+		framePlanet = preview.frame.planet.id
+	}
+	if preview.frame.otherPlanet != nil {
+		frameOtherPlanet = preview.frame.otherPlanet.id
+	}
+
+	planets := make([]*Planet, len(state.planets))
+	for i := range state.planets {
+		planet := state.planets[i]
+		planet.Offset = preview.planetsOffset
+		if live, ok := livePlanets[planet.id]; ok {
+			planet.traces = tracesUntil(live.traces, state.tick)
+		}
+		if shown, ok := shownPlanets[planet.id]; ok && shown.image != nil && shown.Radius == planet.Radius && shown.Color == planet.Color {
+			planet.image = shown.image
+			planet.setPosition(planet.X, planet.Y)
+		} else {
+			planet.updateImage()
+		}
+		planets[i] = &planet
+	}
+
+	preview.planets = planets
+	preview.tick = state.tick
+	preview.simulatedTime = state.simulatedTime
+	preview.centerOfMassTrace = tracesUntil(rewind.live.centerOfMassTrace, state.tick)
+	preview.selectedPlanet.index, preview.selectedPlanet.isSelected = planetIndex(planets, selected, preview.selectedPlanet.isSelected)
+	preview.focusedPlanet.index, preview.focusedPlanet.isFocused = planetIndex(planets, focused, preview.focusedPlanet.isFocused)
+	preview.frame.planet = planetByID(planets, framePlanet)
+	preview.frame.otherPlanet = planetByID(planets, frameOtherPlanet)
+	preview.hierarchy.update(preview)
+
+	rewind.shown = index
+	rewind.Position = index
+}
+
+func planetID(planets []*Planet, index int) int {
+	if index < 0 || index >= len(planets) {
+		return 0
+	}
+
+	return planets[index].id
+}
+
+func planetByID(planets []*Planet, id int) *Planet {
+	if i := slices.IndexFunc(planets, func(planet *Planet) bool { return planet.id == id }); i >= 0 {
+		return planets[i]
+	}
+
+	return nil
+}
+
+// planetIndex returns the index of the planet with id and whether it is
+// still there.
+func planetIndex(planets []*Planet, id int, ok bool) (int, bool) {
+	i := slices.IndexFunc(planets, func(planet *Planet) bool { return planet.id == id })
+	return max(i, 0), ok && i >= 0
+}
+
+// startRewinding puts the live simulation aside and shows its newest state.
+func (sim *simulation) startRewinding() {
+	rewind := sim.rewind
+	live := sim.planetHandler
+	// the newest state is the one to return to
+	if rewind.states.len() == 0 || live.tick != rewind.lastTick {
+		rewind.push(live)
+	}
+
+	preview := live.clone()
+	preview.planetCreator = live.planetCreator
+	preview.planetPresets = live.planetPresets
+	preview.running = false
+	preview.planetsToRemove = nil
+	// subscribers like the event log follow the shown simulation
+	live.events, preview.events = preview.events, live.events
+
+	rewind.live = live
+	rewind.preview = preview
+	sim.planetHandler = preview
+	rewind.show(rewind.states.len() - 1)
+}
+
+// stopRewinding shows the live simulation again. If resume is set it
+// continues from the shown state and the newer states are dropped.
+func (sim *simulation) stopRewinding(resume bool) {
+	rewind := sim.rewind
+	live, preview := rewind.live, rewind.preview
+
+	if resume {
+		rewind.states.truncate(rewind.shown + 1)
+		rewind.size = 0
+		for i := range rewind.states.len() {
+			rewind.size += rewind.states.at(i).size
+		}
+		rewind.lastTick = preview.tick
+		preview.running = live.running
+		sim.timelines.current.handler = preview
+		// the commands refer to planets that are gone now
+		sim.history.clear()
+		if sim.chaosAnalysis.isRunning {
+			sim.chaosAnalysis.stop("Stopped, the simulation was rewound")
+		}
+		rewind.status = fmt.Sprintf("Resumed from tick %d", preview.tick)
+	} else {
+		live.setCamera(preview.planetsOffset)
+		live.events, preview.events = preview.events, live.events
+		sim.planetHandler = live
+		rewind.status = ""
+	}
+
+	rewind.live = nil
+	rewind.preview = nil
+}
+
+func (sim *simulation) handleRewind() {
+	rewind := sim.rewind
+
+	if rewind.shouldStart {
+		rewind.shouldStart = false
+		if !rewind.isRewinding() {
+			sim.startRewinding()
+		}
+	}
+
+	if !rewind.isRewinding() {
+		rewind.shouldStepBackward = false
+		rewind.shouldStepForward = false
+		rewind.shouldResume = false
+		rewind.shouldCancel = false
+		return
+	}
+
+	if rewind.shouldStepBackward {
+		rewind.shouldStepBackward = false
+		rewind.Position--
+	}
+	if rewind.shouldStepForward {
+		rewind.shouldStepForward = false
+		rewind.Position++
+	}
+	rewind.Position = max(0, min(rewind.Position, rewind.states.len()-1))
+	if rewind.Position != rewind.shown {
+		rewind.show(rewind.Position)
+	}
+
+	if rewind.shouldResume {
+		rewind.shouldResume = false
+		sim.stopRewinding(true)
+	} else if rewind.shouldCancel {
+		rewind.shouldCancel = false
+		sim.stopRewinding(false)
+	}
+}
@@ -0,0 +1,71 @@
+package planetsimulation
+
+import (
+	"slices"
+	"testing"
+)
+
+func ringItems(ring *ringBuffer[int]) []int {
+	items := []int{}
+	for i := range ring.len() {
+		items = append(items, ring.at(i))
+	}
+
+	return items
+}
+
+func TestRingBuffer(t *testing.T) {
+	ring := &ringBuffer[int]{}
+	for i := range 100 {
+		ring.push(i)
+		// wrap around the end of the items
+		if i%3 == 0 {
+			ring.popFront()
+		}
+	}
+
+	want := []int{}
+	for i := 34; i < 100; i++ {
+		want = append(want, i)
+	}
+	if got := ringItems(ring); !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	ring.truncate(3)
+	ring.push(7)
+	if got := ringItems(ring); !slices.Equal(got, []int{34, 35, 36, 7}) {
+		t.Fatalf("after truncate got %v", got)
+	}
+
+	ring.clear()
+	if ring.len() != 0 {
+		t.Fatalf("len = %d after clear", ring.len())
+	}
+}
+
+func TestRewindBudget(t *testing.T) {
+	planetHandler := newTestPlanetHandler(
+		newTestPlanet("a", 0, 0, 5, 10, vector2{1, 0}),
+		newTestPlanet("b", 100, 0, 5, 10, vector2{0, 1}),
+	)
+	rewind := newRewind()
+	rewind.BudgetMiB = 1
+
+	for range 10000 {
+		planetHandler.step(0.01)
+		rewind.record(planetHandler)
+	}
+
+	if rewind.size > rewind.budget() {
+		t.Errorf("size %d is over the budget of %d", rewind.size, rewind.budget())
+	}
+	newest := rewind.states.at(rewind.states.len() - 1)
+	if newest.tick != planetHandler.tick || newest.planets[1].X != planetHandler.planets[1].X {
+		t.Errorf("newest state is at tick %d, want %d", newest.tick, planetHandler.tick)
+	}
+	// the oldest states were dropped
+	if oldest := rewind.states.at(0); oldest.tick <= 1 {
+		t.Errorf("oldest state is at tick %d", oldest.tick)
+	}
+}
@@ -0,0 +1,232 @@
+package planetsimulation
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand/v2"
+)
+
+type scenario struct {
+	name     string
+	generate func(generator *scenarioGenerator, rng *rand.Rand, planetHandler *planetHandler) []*Planet
+}
+
+// scenarioGenerator creates whole scenes from a few parameters. The same seed
+// and parameters always create the same scene.
+type scenarioGenerator struct {
+	scenarios      []scenario
+	scenarioIndex  int
+	seed           int
+	count          int
+	centralMass    float64
+	planetMass     float64
+	innerRadius    float64
+	outerRadius    float64
+	replaceScene   bool
+	shouldGenerate bool
+}
+
+func newScenarioGenerator() *scenarioGenerator {
+	return &scenarioGenerator{
+		scenarios: []scenario{
+			{"Star with planets", generateStarSystem},
+			{"Protoplanetary disk", generateProtoplanetaryDisk},
+			{"Binary star", generateBinaryStar},
+			{"Plummer sphere cluster", generatePlummerSphere},
+			{"Spiral galaxy", generateSpiralGalaxy},
+		},
+		seed:         1,
+		count:        8,
+		centralMass:  1000,
+		planetMass:   1,
+		innerRadius:  100,
+		outerRadius:  500,
+		replaceScene: true,
+	}
+}
+
+func (generator *scenarioGenerator) scenarioNames() []string {
+	names := make([]string, len(generator.scenarios))
+	for i, scenario := range generator.scenarios {
+		names[i] = scenario.name
+	}
+
+	return names
+}
+
+func (generator *scenarioGenerator) handleGenerate(planetHandler *planetHandler) {
+	if !generator.shouldGenerate {
+		return
+	}
+
+	rng := rand.New(rand.NewPCG(uint64(generator.seed), uint64(generator.seed)))
+	scenario := generator.scenarios[generator.scenarioIndex]
+
+	for _, planet := range scenario.generate(generator, rng, planetHandler) {
+		planetHandler.addPlanet(planet)
+		planetHandler.planetCounter++
+	}
+
+	planetHandler.running = false
+	generator.shouldGenerate = false
+}
+
+// circularSpeed is the speed needed for a circular orbit at distance around mass.
+func circularSpeed(gravitationalConstant float64, mass float64, distance float64) float64 {
+	return math.Sqrt(gravitationalConstant * mass / distance)
+}
+
+// orbitingPlanet creates a planet at the given distance and angle around the
+// origin moving counterclockwise on screen with the given speed.
+func orbitingPlanet(planetHandler *planetHandler, name string, distance float64, angle float64, speed float64, radius float64, mass float64, color color.NRGBA) *Planet {
+	x, y := distance*math.Cos(angle), distance*math.Sin(angle)
+	velocity := vector2{
+		X: speed * math.Sin(angle),
+		Y: -speed * math.Cos(angle),
+	}
+
+	return newPlanet(name, x, y, radius, mass, velocity, color, planetHandler.planetsOffset)
+}
+
+func randomColor(rng *rand.Rand) color.NRGBA {
+	return SetColor(uint8(100+rng.IntN(156)), uint8(100+rng.IntN(156)), uint8(100+rng.IntN(156)), 255)
+}
+
+func generateStarSystem(generator *scenarioGenerator, rng *rand.Rand, planetHandler *planetHandler) []*Planet {
+	planets := []*Planet{
+		newPlanet("Star", 0, 0, 25, generator.centralMass, vector2{0, 0}, SetColor(255, 220, 80, 255), planetHandler.planetsOffset),
+	}
+
+	for i := 0; i < generator.count; i++ {
+		// evenly spaced with a bit of jitter
+		distance := generator.innerRadius + (generator.outerRadius-generator.innerRadius)*(float64(i)+0.5+(rng.Float64()-0.5)*0.5)/float64(generator.count)
+		angle := rng.Float64() * 2 * math.Pi
+		// near circular, up to 2% off
+		speed := circularSpeed(planetHandler.gravitationalConstant, generator.centralMass, distance) * (1 + (rng.Float64()-0.5)*0.04)
+		mass := generator.planetMass * (0.5 + rng.Float64())
+
+		planets = append(planets, orbitingPlanet(planetHandler, fmt.Sprintf("Planet %d", i+1), distance, angle, speed, 3+rng.Float64()*5, mass, randomColor(rng)))
+	}
+
+	return planets
+}
+
+func generateProtoplanetaryDisk(generator *scenarioGenerator, rng *rand.Rand, planetHandler *planetHandler) []*Planet {
+	planets := []*Planet{
+		newPlanet("Protostar", 0, 0, 20, generator.centralMass, vector2{0, 0}, SetColor(255, 180, 60, 255), planetHandler.planetsOffset),
+	}
+
+	for i := 0; i < generator.count; i++ {
+		// uniform over the area of the ring
+		inner2, outer2 := generator.innerRadius*generator.innerRadius, generator.outerRadius*generator.outerRadius
+		distance := math.Sqrt(inner2 + rng.Float64()*(outer2-inner2))
+		angle := rng.Float64() * 2 * math.Pi
+		speed := circularSpeed(planetHandler.gravitationalConstant, generator.centralMass, distance) * (1 + rng.NormFloat64()*0.01)
+
+		planets = append(planets, orbitingPlanet(planetHandler, fmt.Sprintf("Planetesimal %d", i+1), distance, angle, speed, 2, generator.planetMass*(0.5+rng.Float64()), SetColor(190, 150, 110, 255)))
+	}
+
+	return planets
+}
+
+func generateBinaryStar(generator *scenarioGenerator, rng *rand.Rand, planetHandler *planetHandler) []*Planet {
+	// circumbinary orbits are only stable well outside the binary
+	separation := generator.innerRadius / 3
+	starMass := generator.centralMass / 2
+	// each star moves with half the relative speed around the barycentre
+	starSpeed := circularSpeed(planetHandler.gravitationalConstant, generator.centralMass, separation) / 2
+	angle := rng.Float64() * 2 * math.Pi
+
+	planets := []*Planet{
+		orbitingPlanet(planetHandler, "Star A", separation/2, angle, starSpeed, 15, starMass, SetColor(255, 220, 80, 255)),
+		orbitingPlanet(planetHandler, "Star B", separation/2, angle+math.Pi, starSpeed, 15, starMass, SetColor(255, 140, 60, 255)),
+	}
+
+	for i := 0; i < generator.count; i++ {
+		distance := generator.innerRadius + (generator.outerRadius-generator.innerRadius)*(float64(i)+0.5)/float64(generator.count)
+		speed := circularSpeed(planetHandler.gravitationalConstant, generator.centralMass, distance)
+
+		planets = append(planets, orbitingPlanet(planetHandler, fmt.Sprintf("Planet %d", i+1), distance, rng.Float64()*2*math.Pi, speed, 3+rng.Float64()*4, generator.planetMass*(0.5+rng.Float64()), randomColor(rng)))
+	}
+
+	return planets
+}
+
+// generatePlummerSphere samples a Plummer model in 3D (Aarseth, Hénon and
+// Wielen 1974) and projects it onto the screen plane.
+func generatePlummerSphere(generator *scenarioGenerator, rng *rand.Rand, planetHandler *planetHandler) []*Planet {
+	scaleRadius := generator.outerRadius / 3
+	totalMass := generator.planetMass * float64(generator.count)
+	planets := make([]*Planet, 0, generator.count)
+
+	randomDirection := func(length float64) (float64, float64) {
+		z := 2*rng.Float64() - 1
+		angle := rng.Float64() * 2 * math.Pi
+		r := math.Sqrt(1 - z*z)
+		return length * r * math.Cos(angle), length * r * math.Sin(angle)
+	}
+
+	centerX, centerY, centerVelocity := 0.0, 0.0, vector2{0, 0}
+	for i := 0; i < generator.count; i++ {
+		// cut off the far tail of the distribution
+		distance := math.Inf(1)
+		for distance > 10*scaleRadius {
+			distance = scaleRadius / math.Sqrt(math.Pow(rng.Float64(), -2.0/3.0)-1)
+		}
+		x, y := randomDirection(distance)
+
+		// von Neumann rejection for q = v / v_escape with g(q) = q²(1 - q²)^(7/2)
+		q := 0.0
+		for {
+			q = rng.Float64()
+			if rng.Float64()*0.1 < q*q*math.Pow(1-q*q, 3.5) {
+				break
+			}
+		}
+		escapeSpeed := math.Sqrt(2*planetHandler.gravitationalConstant*totalMass) * math.Pow(distance*distance+scaleRadius*scaleRadius, -0.25)
+		vx, vy := randomDirection(q * escapeSpeed)
+
+		centerX += x / float64(generator.count)
+		centerY += y / float64(generator.count)
+		centerVelocity = centerVelocity.add(vector2{vx / float64(generator.count), vy / float64(generator.count)})
+
+		planets = append(planets, newPlanet(fmt.Sprintf("Star %d", i+1), x, y, 2, generator.planetMass, vector2{vx, vy}, randomColor(rng), planetHandler.planetsOffset))
+	}
+
+	// keep the cluster at rest at the origin
+	for _, planet := range planets {
+		planet.setPosition(planet.X-centerX, planet.Y-centerY)
+		planet.Velocity = planet.Velocity.add(vector2{-centerVelocity.X, -centerVelocity.Y})
+	}
+
+	return planets
+}
+
+func generateSpiralGalaxy(generator *scenarioGenerator, rng *rand.Rand, planetHandler *planetHandler) []*Planet {
+	planets := []*Planet{
+		newPlanet("Core", 0, 0, 15, generator.centralMass, vector2{0, 0}, SetColor(255, 240, 200, 255), planetHandler.planetsOffset),
+	}
+
+	// logarithmic spirals with a pitch angle of 15°
+	pitch := math.Tan(15 * math.Pi / 180)
+	starsMass := generator.planetMass * float64(generator.count)
+
+	for i := 0; i < generator.count; i++ {
+		arm := float64(i % 2)
+		distance := generator.innerRadius + (generator.outerRadius-generator.innerRadius)*rng.Float64()
+		angle := arm*math.Pi + math.Log(distance/generator.innerRadius)/pitch + rng.NormFloat64()*0.2
+
+		// mass inside the orbit, assuming the stars are spread evenly over the
+		// radius. Without a span they all are.
+		enclosedMass := generator.centralMass + starsMass
+		if span := generator.outerRadius - generator.innerRadius; span > 0 {
+			enclosedMass = generator.centralMass + starsMass*(distance-generator.innerRadius)/span
+		}
+		speed := circularSpeed(planetHandler.gravitationalConstant, enclosedMass, distance)
+
+		planets = append(planets, orbitingPlanet(planetHandler, fmt.Sprintf("Star %d", i+1), distance, angle, speed, 2, generator.planetMass, SetColor(150, 180, 255, 255)))
+	}
+
+	return planets
+}
@@ -0,0 +1,60 @@
+package planetsimulation
+
+import (
+	"slices"
+	"testing"
+)
+
+// generateBodies generates the scenario at index with seed into an empty
+// scene.
+func generateBodies(generator *scenarioGenerator, index int, seed int) []bodyRecord {
+	generator.scenarioIndex = index
+	generator.seed = seed
+	generator.count = 20
+	generator.shouldGenerate = true
+
+	planetHandler := newTestPlanetHandler()
+	planetHandler.gravitationalConstant = 1
+	generator.handleGenerate(planetHandler)
+
+	bodies := make([]bodyRecord, len(planetHandler.planets))
+	for i, planet := range planetHandler.planets {
+		bodies[i] = bodyRecordFromPlanet(planet)
+	}
+
+	return bodies
+}
+
+func TestScenarioSeedIsReproducible(t *testing.T) {
+	for i, name := range newScenarioGenerator().scenarioNames() {
+		t.Run(name, func(t *testing.T) {
+			bodies := generateBodies(newScenarioGenerator(), i, 42)
+			if len(bodies) == 0 {
+				t.Fatal("no bodies")
+			}
+
+			if again := generateBodies(newScenarioGenerator(), i, 42); !slices.Equal(again, bodies) {
+				t.Errorf("the same seed generated different bodies:\n%+v\n%+v", bodies, again)
+			}
+			if other := generateBodies(newScenarioGenerator(), i, 43); slices.Equal(other, bodies) {
+				t.Error("another seed generated the same bodies")
+			}
+		})
+	}
+}
+
+func TestScenarioWithoutRadiusSpan(t *testing.T) {
+	for i, name := range newScenarioGenerator().scenarioNames() {
+		t.Run(name, func(t *testing.T) {
+			generator := newScenarioGenerator()
+			generator.innerRadius = 200
+			generator.outerRadius = 200
+
+			for _, body := range generateBodies(generator, i, 42) {
+				if err := body.ValidatePhysics(body.Name); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package planetsimulation
 
 import (
 	"image/color"
+	"log"
+	"path/filepath"
 	"slices"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -16,9 +18,22 @@ type simulation struct {
 	screen            *SimulationScreen
 	gameSize          []int
 	simulationPresets *simulationPresets
+	scenarioGenerator *scenarioGenerator
+	chaosAnalysis     *chaosAnalysis
+	snapshots         *snapshots
+	bodyTable         *bodyTable
+	horizonsImport    *horizonsImport
+	recorder          *trajectoryRecorder
+	autosave          *autosave
+	history           *history
+	replay            *replay
+	timelines         *timelines
+	rewind            *rewind
 	planetHandler     *planetHandler
-	shouldReset       bool
-	tps               int
+	// where snapshots, replays, imports and exports are kept
+	dataDirectory string
+	shouldReset   bool
+	tps           int
 }
 
 func newSimulationScreen(gameSize []int) *SimulationScreen {
@@ -30,21 +45,78 @@ func newSimulationScreen(gameSize []int) *SimulationScreen {
 	return screen
 }
 
-func newSimulation(gameSize []int) *simulation {
+// newSimulation keeps its files in dataDirectory or, if it is empty, in the
+// user directories.
+func newSimulation(gameSize []int, dataDirectory string) *simulation {
 	screen := newSimulationScreen(gameSize)
+	var storage *fileStorage
+	if dataDirectory != "" {
+		storage = newFileStorage(dataDirectory)
+	} else {
+		var err error
+		storage, err = newUserStorage(planetPresetsFileName, simulationPresetsFileName)
+		if err != nil {
+			log.Printf("Failed to set up the preset storage: %v", err)
+		}
+	}
+	defaults := newDefaultStorage()
 
 	sim := &simulation{
 		screen:            screen,
 		gameSize:          gameSize,
-		simulationPresets: newSimulationPresets(),
-		planetHandler:     newPlanetHandler(gameSize),
+		simulationPresets: newSimulationPresets(storage, defaults),
+		scenarioGenerator: newScenarioGenerator(),
+		chaosAnalysis:     newChaosAnalysis(),
+		snapshots:         newSnapshots(),
+		bodyTable:         newBodyTable(),
+		horizonsImport:    newHorizonsImport(),
+		recorder:          newTrajectoryRecorder(),
+		autosave:          newAutosave(dataDirectory),
+		history:           newHistory(),
+		replay:            newReplay(),
+		rewind:            newRewind(),
+		planetHandler:     newPlanetHandler(gameSize, storage, defaults),
 		shouldReset:       false,
 		tps:               120,
 	}
 
+	sim.timelines = newTimelines(sim.planetHandler, sim.history)
+	if dataDirectory == "" {
+		// next to the autosaves, the working directory if there is no user
+		// data directory
+		var err error
+		dataDirectory, err = userDataDir()
+		if err != nil {
+			log.Printf("Failed to find the user data directory: %v", err)
+			dataDirectory = legacyDataDirectory
+		}
+	}
+	sim.useDataDirectory(dataDirectory)
+
 	return sim
 }
 
+// useDataDirectory keeps the snapshots, replays, imports and exports in
+// directory.
+func (sim *simulation) useDataDirectory(directory string) {
+	sim.dataDirectory = directory
+	sim.snapshots.directory = filepath.Join(directory, "snapshots")
+	sim.horizonsImport.directory = filepath.Join(directory, "horizons")
+	sim.bodyTable.filePath = filepath.Join(directory, "bodies.csv")
+	sim.recorder.filePath = filepath.Join(directory, "trajectory")
+	sim.replay.filePath = filepath.Join(directory, "replay.psr")
+}
+
+// resize follows a resized window. The origin point stays where it is.
+func (screen *SimulationScreen) resize(gameSize []int) {
+	if size := screen.image.Bounds().Size(); size.X == gameSize[0] && size.Y == gameSize[1] {
+		return
+	}
+
+	screen.image.Deallocate()
+	screen.image = ebiten.NewImage(gameSize[0], gameSize[1])
+}
+
 func (sim *simulation) getCoords(planetHandler *planetHandler) []float64 {
 	return []float64{
 		-(planetHandler.planetsOffset[0] - planetHandler.defaultPlanetsOffset[0]),
@@ -53,37 +125,80 @@ func (sim *simulation) getCoords(planetHandler *planetHandler) []float64 {
 }
 
 func (sim *simulation) handleReset() {
-	if sim.shouldReset || sim.simulationPresets.shouldLoadSimulation {
-		sim.planetHandler.selectedPlanet.isSelected = false
-		sim.planetHandler.focusedPlanet.isFocused = false
-		sim.planetHandler.planetCreator.showPlanet = false
-		sim.planetHandler.planetCounter = 0
-		sim.planetHandler.planets = slices.Delete(sim.planetHandler.planets, 0, len(sim.planetHandler.planets))
-		dx := sim.planetHandler.planetsOffset[0] - sim.planetHandler.defaultPlanetsOffset[0]
-		dy := sim.planetHandler.planetsOffset[1] - sim.planetHandler.defaultPlanetsOffset[1]
-		sim.planetHandler.planetsOffset[0] -= dx
-		sim.planetHandler.planetsOffset[1] -= dy
-
-		for _, planet := range sim.planetHandler.planets {
-			planet.geometry.Translate(-dx, -dy)
+	generatesScene := sim.scenarioGenerator.shouldGenerate && sim.scenarioGenerator.replaceScene
+	if sim.shouldReset || sim.simulationPresets.shouldLoadSimulation || generatesScene {
+		// a reset by accident can be undone with the autosave, a save that
+		// is still being written would skip it
+		if sim.shouldReset && sim.autosave.isEnabled && len(sim.planetHandler.planets) > 0 {
+			sim.autosave.wait()
+			sim.autosave.save(sim)
 		}
-
+		if sim.shouldReset {
+			sim.history.push(newResetCommand(sim.planetHandler))
+		}
+		sim.reset()
 		sim.shouldReset = false
 	}
 }
 
+// reset removes all planets and moves the camera back to the origin point.
+func (sim *simulation) reset() {
+	sim.planetHandler.selectedPlanet.isSelected = false
+	sim.planetHandler.focusedPlanet.isFocused = false
+	sim.planetHandler.planetCreator.showPlanet = false
+	sim.planetHandler.planetCounter = 0
+	for _, planet := range sim.planetHandler.planets {
+		sim.planetHandler.emitEvent(eventDeleted, planet, nil, 0)
+	}
+	sim.planetHandler.planets = slices.Delete(sim.planetHandler.planets, 0, len(sim.planetHandler.planets))
+	sim.planetHandler.clearTraces()
+	dx := sim.planetHandler.planetsOffset[0] - sim.planetHandler.defaultPlanetsOffset[0]
+	dy := sim.planetHandler.planetsOffset[1] - sim.planetHandler.defaultPlanetsOffset[1]
+	sim.planetHandler.planetsOffset[0] -= dx
+	sim.planetHandler.planetsOffset[1] -= dy
+
+	for _, planet := range sim.planetHandler.planets {
+		planet.geometry.Translate(-dx, -dy)
+	}
+}
+
 func (sim *simulation) Update() {
 	ebiten.SetTPS(sim.tps)
 
+	sim.handleReplay()
+	// the live simulation waits while a replay plays
+	if sim.replay.isPlaying() {
+		return
+	}
+	sim.handleRewind()
+	if sim.rewind.isRewinding() {
+		return
+	}
+
+	sim.handleHistory()
+	sim.handleTimelines()
+	sim.handleSnapshots()
+	sim.autosave.Update(sim)
 	sim.handleReset()
 	sim.planetHandler.Update()
+	sim.timelines.Update()
+	sim.recorder.record(sim.planetHandler)
+	sim.replay.record(sim.planetHandler)
+	sim.rewind.record(sim.planetHandler)
+	sim.chaosAnalysis.Update(sim.planetHandler)
 	sim.simulationPresets.handleLoad(sim.planetHandler, sim.simulationPresets.presetIndex)
+	sim.simulationPresets.handleCodes()
+	sim.scenarioGenerator.handleGenerate(sim.planetHandler)
+	sim.handleBodyTable()
+	sim.handleHorizonsImport()
 }
 
 func (sim *simulation) Draw(gameScreen *ebiten.Image) {
+	sim.screen.resize(sim.gameSize)
 	sim.screen.image.Fill(color.Black)
 
 	sim.planetHandler.Draw(sim.screen.image)
+	sim.timelines.drawComparison(sim.screen.image, sim.planetHandler)
 
 	gameScreen.DrawImage(sim.screen.image, &ebiten.DrawImageOptions{
 		GeoM: sim.screen.geometry,
@@ -2,46 +2,175 @@ package planetsimulation
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 	"slices"
+	"strings"
+	"time"
+
+	"PlanetSimulation/internal/schema"
 )
 
+const simulationPresetsFileName = schema.SimulationPresetsFileName
+
 type simulationPresets struct {
 	Presets              []*simulationPreset
+	builtInPresets       []*simulationPreset
 	newPresetName        string
 	presetIndex          int
 	shouldLoadSimulation bool
-	filePath             string
+	storage              storage
+	fileName             string
+	// set when the file could not be loaded or saved, the file is not
+	// overwritten after a failed load so nothing gets lost
+	err          error
+	hasLoadError bool
+	// sharing presets as text
+	code            string
+	codeIndex       int
+	shouldCopyCode  bool
+	shouldPasteCode bool
+	shouldAddCode   bool
+	codeStatus      string
+	codeErr         error
+	browser         *presetBrowser
 }
 
 type simulationPreset struct {
-	Name    string
-	Planets []*Planet
+	Name string
+	presetMetadata
+	GravitationalConstant float64
+	// presets without an integrator keep the current integrator and time step
+	Integrator string
+	TimeStep   float64
+	Planets    []*Planet
+	isBuiltIn  bool
 }
 
-func newSimulationPresets() *simulationPresets {
+func newSimulationPresets(storage storage, defaults storage) *simulationPresets {
 	simulationPresets := &simulationPresets{
-		filePath: "assets/data/simulation_presets.json",
+		storage:  storage,
+		fileName: simulationPresetsFileName,
+		browser:  newPresetBrowser(),
 	}
+	simulationPresets.loadBuiltIn(defaults)
+	simulationPresets.builtInPresets = append(simulationPresets.builtInPresets, periodicSolutionPresets()...)
 	simulationPresets.loadFromFile()
 
 	return simulationPresets
 }
 
 func (presets *simulationPresets) saveSimulationPreset(planetHandler *planetHandler) {
+	// copy the values, the planets keep moving
 	planets := []*Planet{}
 	for _, planet := range planetHandler.planets {
-		planets = append(planets, *&planet)
+		planets = append(planets, planetFromRecord(bodyRecordFromPlanet(planet)))
+	}
+
+	preset := &simulationPreset{
+		Name:                  presets.newPresetName,
+		GravitationalConstant: planetHandler.gravitationalConstant,
+		Integrator:            planetHandler.integrator.String(),
+		TimeStep:              planetHandler.timeStep,
+		Planets:               planets,
+	}
+	preset.Touch()
+	presets.Presets = append(presets.Presets, preset)
+	presets.saveToFile()
+}
+
+func (presets *simulationPresets) loadBuiltIn(defaults storage) {
+	content, err := defaults.read(simulationPresetsFileName)
+	file := simulationPresetsFile{}
+	if err == nil {
+		file, err = schema.DecodeSimulationPresets(content)
+	}
+	if err != nil {
+		presets.err = fmt.Errorf("failed to load the built-in simulation presets: %w", err)
+		return
+	}
+
+	for _, record := range file.Presets {
+		preset := simulationPresetFromRecord(record)
+		preset.isBuiltIn = true
+		presets.builtInPresets = append(presets.builtInPresets, preset)
+	}
+}
+
+// all returns the built-in presets followed by the ones of the user.
+func (presets *simulationPresets) all() []*simulationPreset {
+	return slices.Concat(presets.builtInPresets, presets.Presets)
+}
+
+func (presets *simulationPresets) isBuiltIn(i int) bool {
+	return i < len(presets.builtInPresets)
+}
+
+// indexOf returns the index in all of the preset named name, ignoring case,
+// or -1. Your own presets come before built-in presets of the same name.
+func (presets *simulationPresets) indexOf(name string) int {
+	isNamed := func(preset *simulationPreset) bool {
+		return strings.EqualFold(strings.TrimSpace(preset.Name), strings.TrimSpace(name))
+	}
+	if i := slices.IndexFunc(presets.Presets, isNamed); i >= 0 {
+		return len(presets.builtInPresets) + i
 	}
 
-	presets.Presets = append(presets.Presets, &simulationPreset{
-		Name:    presets.newPresetName,
-		Planets: planets,
+	return slices.IndexFunc(presets.builtInPresets, isNamed)
+}
+
+// duplicateSimulationPreset copies the preset at index i of all behind it, or
+// behind the presets of the user if it is built-in, and returns the index of
+// the copy in all.
+func (presets *simulationPresets) duplicateSimulationPreset(i int) int {
+	original := presets.all()[i]
+	preset := simulationPresetFromRecord(simulationPresetRecordFromPreset(original))
+	preset.Name = copyName(original.Name, func(name string) bool {
+		return slices.ContainsFunc(presets.all(), func(preset *simulationPreset) bool { return preset.Name == name })
 	})
+	preset.Created = time.Time{}
+	preset.Touch()
+
+	index := len(presets.Presets)
+	if !presets.isBuiltIn(i) {
+		index = i - len(presets.builtInPresets) + 1
+	}
+	presets.Presets = slices.Insert(presets.Presets, index, preset)
 	presets.saveToFile()
+
+	return len(presets.builtInPresets) + index
 }
 
+// moveSimulationPreset moves the preset at index i of all up (-1) or down (1)
+// in its folder and returns its new index in all.
+func (presets *simulationPresets) moveSimulationPreset(i int, delta int) int {
+	if presets.isBuiltIn(i) {
+		return i
+	}
+
+	offset := len(presets.builtInPresets)
+	index := movePreset(presets.Presets, i-offset, delta, func(preset *simulationPreset) string { return preset.Folder })
+	presets.saveToFile()
+
+	return offset + index
+}
+
+// editSimulationPreset saves the changes to the preset at index i of all.
+func (presets *simulationPresets) editSimulationPreset(i int) {
+	if presets.isBuiltIn(i) {
+		return
+	}
+
+	presets.all()[i].Touch()
+	presets.saveToFile()
+}
+
+// removeSimulationPreset removes the preset at index i of all.
 func (presets *simulationPresets) removeSimulationPreset(i int) {
+	i -= len(presets.builtInPresets)
+	if i < 0 {
+		return
+	}
+
 	presets.Presets = slices.Delete(presets.Presets, i, i+1)
 
 	presets.saveToFile()
@@ -49,8 +178,17 @@ func (presets *simulationPresets) removeSimulationPreset(i int) {
 
 func (presets *simulationPresets) handleLoad(planetHandler *planetHandler, i int) {
 	if presets.shouldLoadSimulation {
-		for _, planet := range presets.Presets[i].Planets {
-			planetHandler.planets = append(planetHandler.planets, newPlanet(
+		preset := presets.all()[i]
+		if preset.GravitationalConstant != 0 {
+			planetHandler.gravitationalConstant = preset.GravitationalConstant
+		}
+		if integrator, ok := parseIntegrator(preset.Integrator); ok {
+			planetHandler.integrator = integrator
+			planetHandler.timeStep = preset.TimeStep
+		}
+
+		for _, planet := range preset.Planets {
+			planetHandler.addPlanet(newPlanet(
 				planet.Name,
 				planet.X,
 				planet.Y,
@@ -68,19 +206,48 @@ func (presets *simulationPresets) handleLoad(planetHandler *planetHandler, i int
 }
 
 func (presets *simulationPresets) loadFromFile() {
-	content := readFile(presets.filePath)
+	content, err := presets.storage.read(presets.fileName)
+	if err != nil {
+		presets.err = fmt.Errorf("failed to read %s: %w", presets.storage.location(presets.fileName), err)
+		presets.hasLoadError = true
+		return
+	}
+
+	file, err := schema.DecodeSimulationPresets(content)
+	if err != nil {
+		presets.err = fmt.Errorf("failed to load %s: %w", presets.storage.location(presets.fileName), err)
+		presets.hasLoadError = true
+		return
+	}
 
-	if err := json.Unmarshal(content, &presets); err != nil {
-		log.Printf("Failed to unmarshal planet presets json: %v", err)
+	presets.Presets = make([]*simulationPreset, len(file.Presets))
+	for i, preset := range file.Presets {
+		presets.Presets[i] = simulationPresetFromRecord(preset)
 	}
 }
 
 func (presets *simulationPresets) saveToFile() {
-	content, err := json.MarshalIndent(presets, "", " ")
+	if presets.hasLoadError {
+		return
+	}
+
+	file := simulationPresetsFile{
+		Version: schema.Version,
+		Presets: make([]simulationPresetRecord, len(presets.Presets)),
+	}
+	for i, preset := range presets.Presets {
+		file.Presets[i] = simulationPresetRecordFromPreset(preset)
+	}
 
+	content, err := json.MarshalIndent(file, "", " ")
 	if err != nil {
-		log.Printf("Failed to marshal planet presets json: %v", err)
+		presets.err = fmt.Errorf("failed to marshal simulation presets: %w", err)
+		return
 	}
 
-	writeFile(presets.filePath, content)
+	if err := presets.storage.write(presets.fileName, content); err != nil {
+		presets.err = fmt.Errorf("failed to save %s: %w", presets.storage.location(presets.fileName), err)
+		return
+	}
+	presets.err = nil
 }
@@ -0,0 +1,313 @@
+package planetsimulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"slices"
+
+	"PlanetSimulation/internal/schema"
+)
+
+// Snapshots hold the whole state of a simulation, not just the bodies of a
+// preset, so loading one resumes exactly where it was saved. Version 1:
+//
+//	{
+//	  "version": 1,
+//	  "gravitationalConstant": 10000,
+//	  "integrator": "leapfrog",
+//	  "timeStep": 0,
+//	  "tps": 120,
+//	  "running": false,
+//	  "tick": 1200, "simulatedTime": 10.1, "lastTimeStep": 0.0083,
+//	  "planetCounter": 3,
+//	  "camera": {"x": 0, "y": 0},          // offset from the origin point
+//	  "selectedPlanet": -1,                // index into planets, -1 for none
+//	  "focusedPlanet": 0, "focusSystem": false,
+//	  "frame": {"kind": "inertial", "planet": -1, "otherPlanet": -1},
+//	  "centerOfMassTrace": [{"x": 0, "y": 0, "tick": 1}, ...],
+//	  "planets": [body with "id", "tickCount" and "traces", ...]
+//	}
+const snapshotFileVersion = 1
+
+type planetSnapshot struct {
+	bodyRecord
+	ID        int          `json:"id"`
+	TickCount int          `json:"tickCount"`
+	Traces    []tracePoint `json:"traces"`
+}
+
+type frameSnapshot struct {
+	Kind        string `json:"kind"`
+	Planet      int    `json:"planet"`
+	OtherPlanet int    `json:"otherPlanet"`
+}
+
+type simulationSnapshot struct {
+	Version               int              `json:"version"`
+	GravitationalConstant float64          `json:"gravitationalConstant"`
+	Integrator            string           `json:"integrator"`
+	TimeStep              float64          `json:"timeStep"`
+	TPS                   int              `json:"tps"`
+	Running               bool             `json:"running"`
+	Tick                  int              `json:"tick"`
+	SimulatedTime         float64          `json:"simulatedTime"`
+	LastTimeStep          float64          `json:"lastTimeStep"`
+	PlanetCounter         int              `json:"planetCounter"`
+	Camera                vector2          `json:"camera"`
+	SelectedPlanet        int              `json:"selectedPlanet"`
+	FocusedPlanet         int              `json:"focusedPlanet"`
+	FocusSystem           bool             `json:"focusSystem"`
+	Frame                 frameSnapshot    `json:"frame"`
+	CenterOfMassTrace     []tracePoint     `json:"centerOfMassTrace"`
+	Planets               []planetSnapshot `json:"planets"`
+}
+
+// snapshots saves and loads snapshots in directory. Both happen in the
+// simulation update so the state is never captured in the middle of a tick.
+type snapshots struct {
+	directory     string
+	name          string
+	quickSaveName string
+	fileName      string
+	shouldSave    bool
+	shouldLoad    bool
+	status        string
+	err           error
+}
+
+func newSnapshots() *snapshots {
+	return &snapshots{
+		name:          "snapshot",
+		quickSaveName: "quicksave",
+	}
+}
+
+func (snapshots *snapshots) filePath(name string) string {
+	// only a file name, snapshots stay in their directory
+	return filepath.Join(snapshots.directory, filepath.Base(name)+".json")
+}
+
+func (snapshots *snapshots) save(name string) {
+	snapshots.fileName = name
+	snapshots.shouldSave = true
+}
+
+func (snapshots *snapshots) load(name string) {
+	snapshots.fileName = name
+	snapshots.shouldLoad = true
+}
+
+func (sim *simulation) snapshot() simulationSnapshot {
+	planetHandler := sim.planetHandler
+	coords := sim.getCoords(planetHandler)
+
+	snapshot := simulationSnapshot{
+		Version:               snapshotFileVersion,
+		GravitationalConstant: planetHandler.gravitationalConstant,
+		Integrator:            planetHandler.integrator.String(),
+		TimeStep:              planetHandler.timeStep,
+		TPS:                   sim.tps,
+		Running:               planetHandler.running,
+		Tick:                  planetHandler.tick,
+		SimulatedTime:         planetHandler.simulatedTime,
+		LastTimeStep:          planetHandler.lastTimeStep,
+		PlanetCounter:         planetHandler.planetCounter,
+		Camera:                vector2{-coords[0], coords[1]},
+		SelectedPlanet:        -1,
+		FocusedPlanet:         -1,
+		FocusSystem:           planetHandler.focusedPlanet.isSystem,
+		Frame: frameSnapshot{
+			Kind:        frameKindNames[planetHandler.frame.kind],
+			Planet:      slices.Index(planetHandler.planets, planetHandler.frame.planet),
+			OtherPlanet: slices.Index(planetHandler.planets, planetHandler.frame.otherPlanet),
+		},
+		CenterOfMassTrace: slices.Clone(planetHandler.centerOfMassTrace),
+		Planets:           make([]planetSnapshot, len(planetHandler.planets)),
+	}
+
+	if planetHandler.selectedPlanet.isSelected {
+		snapshot.SelectedPlanet = planetHandler.selectedPlanet.index
+	}
+	if planetHandler.focusedPlanet.isFocused {
+		snapshot.FocusedPlanet = planetHandler.focusedPlanet.index
+	}
+
+	for i, planet := range planetHandler.planets {
+		snapshot.Planets[i] = planetSnapshot{
+			bodyRecord: bodyRecordFromPlanet(planet),
+			ID:         planet.id,
+			TickCount:  planet.TickCount,
+			Traces:     slices.Clone(planet.traces),
+		}
+	}
+
+	return snapshot
+}
+
+func validateIndex(path string, i int, length int) error {
+	if i < -1 || i >= length {
+		return &validationError{Path: path, Message: fmt.Sprintf("must be -1 or an index below %d, got %d", length, i)}
+	}
+	return nil
+}
+
+func (snapshot simulationSnapshot) validate() error {
+	errs := []error{
+		schema.ValidatePositive("gravitationalConstant", snapshot.GravitationalConstant),
+		schema.ValidateFinite("timeStep", snapshot.TimeStep),
+		schema.ValidateFinite("simulatedTime", snapshot.SimulatedTime),
+		schema.ValidateFinite("lastTimeStep", snapshot.LastTimeStep),
+		schema.ValidateFinite("camera.x", snapshot.Camera.X),
+		schema.ValidateFinite("camera.y", snapshot.Camera.Y),
+		validateIndex("selectedPlanet", snapshot.SelectedPlanet, len(snapshot.Planets)),
+		validateIndex("focusedPlanet", snapshot.FocusedPlanet, len(snapshot.Planets)),
+		validateIndex("frame.planet", snapshot.Frame.Planet, len(snapshot.Planets)),
+		validateIndex("frame.otherPlanet", snapshot.Frame.OtherPlanet, len(snapshot.Planets)),
+	}
+
+	if _, ok := parseIntegrator(snapshot.Integrator); !ok {
+		errs = append(errs, &validationError{Path: "integrator", Message: fmt.Sprintf("unknown integrator %q", snapshot.Integrator)})
+	}
+	if !slices.Contains(frameKindNames, snapshot.Frame.Kind) {
+		errs = append(errs, &validationError{Path: "frame.kind", Message: fmt.Sprintf("unknown reference frame %q", snapshot.Frame.Kind)})
+	}
+	if snapshot.TimeStep < 0 {
+		errs = append(errs, &validationError{Path: "timeStep", Message: fmt.Sprintf("must not be negative, got %v", snapshot.TimeStep)})
+	}
+	if snapshot.TPS <= 0 {
+		errs = append(errs, &validationError{Path: "tps", Message: fmt.Sprintf("must be positive, got %d", snapshot.TPS)})
+	}
+
+	for i, planet := range snapshot.Planets {
+		errs = append(errs, planet.Validate(fmt.Sprintf("planets[%d]", i)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// decodeSnapshot parses and validates a snapshot file.
+func decodeSnapshot(content []byte) (simulationSnapshot, error) {
+	snapshot := simulationSnapshot{}
+	if len(content) == 0 {
+		return snapshot, errors.New("no snapshot saved yet")
+	}
+
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return snapshot, schema.JSONError(content, err)
+	}
+	if snapshot.Version != snapshotFileVersion {
+		return snapshot, fmt.Errorf("unsupported version %d, this build supports %d", snapshot.Version, snapshotFileVersion)
+	}
+
+	return snapshot, snapshot.validate()
+}
+
+// restore replaces the current simulation with snapshot.
+func (sim *simulation) restore(snapshot simulationSnapshot) {
+	sim.reset()
+	if sim.chaosAnalysis.isRunning {
+		sim.chaosAnalysis.stop("Stopped, a snapshot was loaded")
+	}
+
+	planetHandler := sim.planetHandler
+	planetHandler.gravitationalConstant = snapshot.GravitationalConstant
+	planetHandler.integrator, _ = parseIntegrator(snapshot.Integrator)
+	planetHandler.timeStep = snapshot.TimeStep
+	planetHandler.running = snapshot.Running
+	planetHandler.tick = snapshot.Tick
+	planetHandler.simulatedTime = snapshot.SimulatedTime
+	planetHandler.lastTimeStep = snapshot.LastTimeStep
+	planetHandler.planetCounter = snapshot.PlanetCounter
+	planetHandler.centerOfMassTrace = slices.Clone(snapshot.CenterOfMassTrace)
+	// the old parents and approaches refer to the removed planets
+	planetHandler.hierarchy = newHierarchy()
+	planetHandler.eventDetector = newEventDetector()
+	sim.tps = snapshot.TPS
+
+	// the planets share the offset slice, so it has to be changed in place
+	// before they are created
+	planetHandler.planetsOffset[0] = planetHandler.defaultPlanetsOffset[0] + snapshot.Camera.X
+	planetHandler.planetsOffset[1] = planetHandler.defaultPlanetsOffset[1] + snapshot.Camera.Y
+
+	for _, saved := range snapshot.Planets {
+		record := saved.bodyRecord
+		planet := newPlanet(
+			record.Name,
+			record.X,
+			record.Y,
+			record.Radius,
+			record.Mass,
+			vector2(record.Velocity),
+			color.NRGBA{record.Color.R, record.Color.G, record.Color.B, record.Color.A},
+			planetHandler.planetsOffset,
+		)
+		planet.TraceWidth = record.Trace.Width
+		planet.TraceEveryNTick = record.Trace.EveryNTick
+		planet.DrawEveryNTick = record.Trace.DrawEveryNTick
+		planet.AntialiasTraces = record.Trace.Antialias
+		planet.id = saved.ID
+		planetHandler.lastPlanetID = max(planetHandler.lastPlanetID, saved.ID)
+		planet.TickCount = saved.TickCount
+		planet.traces = slices.Clone(saved.Traces)
+
+		planetHandler.addPlanet(planet)
+	}
+
+	if snapshot.SelectedPlanet >= 0 {
+		planetHandler.selectPlanet(snapshot.SelectedPlanet)
+	}
+	if snapshot.FocusedPlanet >= 0 {
+		planetHandler.focusPlanet(snapshot.FocusedPlanet)
+		planetHandler.focusedPlanet.isSystem = snapshot.FocusSystem
+	}
+
+	planetHandler.frame = referenceFrame{kind: frameKind(slices.Index(frameKindNames, snapshot.Frame.Kind))}
+	if snapshot.Frame.Planet >= 0 {
+		planetHandler.frame.planet = planetHandler.planets[snapshot.Frame.Planet]
+	}
+	if snapshot.Frame.OtherPlanet >= 0 {
+		planetHandler.frame.otherPlanet = planetHandler.planets[snapshot.Frame.OtherPlanet]
+	}
+}
+
+func (sim *simulation) handleSnapshots() {
+	snapshots := sim.snapshots
+	filePath := snapshots.filePath(snapshots.fileName)
+
+	if snapshots.shouldSave {
+		snapshots.shouldSave = false
+		snapshots.err = nil
+
+		content, err := json.MarshalIndent(sim.snapshot(), "", "  ")
+		if err == nil {
+			err = writeFile(filePath, content)
+		}
+		if err != nil {
+			snapshots.err = fmt.Errorf("failed to save %s: %w", filePath, err)
+			return
+		}
+		snapshots.status = fmt.Sprintf("Saved %s at tick %d", snapshots.fileName, sim.planetHandler.tick)
+	}
+
+	if snapshots.shouldLoad {
+		snapshots.shouldLoad = false
+		snapshots.err = nil
+
+		content, err := readFile(filePath)
+		if err != nil {
+			snapshots.err = fmt.Errorf("failed to read %s: %w", filePath, err)
+			return
+		}
+		snapshot, err := decodeSnapshot(content)
+		if err != nil {
+			snapshots.err = fmt.Errorf("failed to load %s: %w", filePath, err)
+			return
+		}
+
+		sim.restore(snapshot)
+		snapshots.status = fmt.Sprintf("Loaded %s at tick %d", snapshots.fileName, snapshot.Tick)
+	}
+}
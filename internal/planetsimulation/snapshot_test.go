@@ -0,0 +1,127 @@
+package planetsimulation
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestSnapshotSimulation returns a simulation of three planets that ran
+// for a while, with the camera moved, a planet selected and one focused in a
+// co-rotating frame.
+func newTestSnapshotSimulation() *simulation {
+	sim := newTestHistory()
+	sim.tps = 90
+	planetHandler := sim.planetHandler
+	planetHandler.gravitationalConstant = 1
+	planetHandler.integrator = integratorLeapfrog
+	planetHandler.timeStep = 0.01
+
+	planetHandler.addPlanet(newPlanet("star", 0, 0, 10, 1e6, vector2{}, SetColor(255, 255, 0, 255), planetHandler.planetsOffset))
+	planetHandler.addPlanet(newPlanet("planet", 100, 0, 2, 10, vector2{0, 100}, SetColor(0, 0, 255, 255), planetHandler.planetsOffset))
+	planetHandler.addPlanet(newPlanet("moon", 0, -200, 1, 1, vector2{70, 0}, SetColor(128, 128, 128, 255), planetHandler.planetsOffset))
+
+	planetHandler.running = true
+	for range 100 {
+		planetHandler.Update()
+	}
+
+	planetHandler.planetsOffset[0] += 30
+	planetHandler.planetsOffset[1] -= 40
+	planetHandler.selectPlanet(2)
+	planetHandler.focusSystem(1)
+	planetHandler.frame = referenceFrame{kind: frameCoRotating, planet: planetHandler.planets[0], otherPlanet: planetHandler.planets[1]}
+
+	return sim
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	sim := newTestSnapshotSimulation()
+	content, err := json.Marshal(sim.snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := decodeSnapshot(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestHistory()
+	restored.restore(snapshot)
+	saved, loaded := sim.planetHandler, restored.planetHandler
+
+	if restored.tps != sim.tps {
+		t.Errorf("tps = %d, want %d", restored.tps, sim.tps)
+	}
+	if loaded.tick != saved.tick || loaded.simulatedTime != saved.simulatedTime || loaded.lastTimeStep != saved.lastTimeStep {
+		t.Errorf("resumed at tick %d and %vs, want tick %d and %vs", loaded.tick, loaded.simulatedTime, saved.tick, saved.simulatedTime)
+	}
+	if loaded.integrator != saved.integrator || loaded.timeStep != saved.timeStep || loaded.gravitationalConstant != saved.gravitationalConstant {
+		t.Errorf("%s with dt %v and G %v, want %s with dt %v and G %v", loaded.integrator, loaded.timeStep, loaded.gravitationalConstant, saved.integrator, saved.timeStep, saved.gravitationalConstant)
+	}
+	if !slices.Equal(loaded.planetsOffset, saved.planetsOffset) {
+		t.Errorf("camera offset = %v, want %v", loaded.planetsOffset, saved.planetsOffset)
+	}
+	if loaded.selectedPlanet != saved.selectedPlanet {
+		t.Errorf("selection = %+v, want %+v", loaded.selectedPlanet, saved.selectedPlanet)
+	}
+	if loaded.focusedPlanet != saved.focusedPlanet {
+		t.Errorf("focus = %+v, want %+v", loaded.focusedPlanet, saved.focusedPlanet)
+	}
+	if loaded.frame.kind != frameCoRotating || loaded.frame.planet != loaded.planets[0] || loaded.frame.otherPlanet != loaded.planets[1] {
+		t.Errorf("frame = %s of %v and %v, want the pair of the first two planets", frameKindNames[loaded.frame.kind], loaded.frame.planet, loaded.frame.otherPlanet)
+	}
+	if len(saved.centerOfMassTrace) == 0 || !slices.Equal(loaded.centerOfMassTrace, saved.centerOfMassTrace) {
+		t.Errorf("centre of mass trace of %d points, want %d", len(loaded.centerOfMassTrace), len(saved.centerOfMassTrace))
+	}
+
+	if len(loaded.planets) != len(saved.planets) {
+		t.Fatalf("%d planets, want %d", len(loaded.planets), len(saved.planets))
+	}
+	for i, planet := range saved.planets {
+		other := loaded.planets[i]
+		if other.id != planet.id || other.TickCount != planet.TickCount {
+			t.Errorf("planet %d has id %d at tick %d, want id %d at tick %d", i, other.id, other.TickCount, planet.id, planet.TickCount)
+		}
+		if bodyRecordFromPlanet(other) != bodyRecordFromPlanet(planet) {
+			t.Errorf("planet %d = %+v, want %+v", i, bodyRecordFromPlanet(other), bodyRecordFromPlanet(planet))
+		}
+		if len(planet.traces) == 0 || !slices.Equal(other.traces, planet.traces) {
+			t.Errorf("planet %d has %d trace points, want %d", i, len(other.traces), len(planet.traces))
+		}
+	}
+
+	// both go on the same way
+	for range 10 {
+		saved.Update()
+		loaded.Update()
+	}
+	if !slices.Equal(positions(loaded.planets), positions(saved.planets)) {
+		t.Errorf("resumed at %v, want %v", positions(loaded.planets), positions(saved.planets))
+	}
+}
+
+func TestSnapshotRejectsIndices(t *testing.T) {
+	tests := map[string]func(snapshot *simulationSnapshot){
+		"selectedPlanet":    func(snapshot *simulationSnapshot) { snapshot.SelectedPlanet = 3 },
+		"focusedPlanet":     func(snapshot *simulationSnapshot) { snapshot.FocusedPlanet = -2 },
+		"frame.planet":      func(snapshot *simulationSnapshot) { snapshot.Frame.Planet = 7 },
+		"frame.otherPlanet": func(snapshot *simulationSnapshot) { snapshot.Frame.OtherPlanet = 3 },
+	}
+
+	for path, change := range tests {
+		t.Run(path, func(t *testing.T) {
+			snapshot := newTestSnapshotSimulation().snapshot()
+			if err := snapshot.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			change(&snapshot)
+			err := snapshot.validate()
+			if err == nil || !strings.Contains(err.Error(), path+": must be -1 or an index below 3") {
+				t.Errorf("err = %v, want %s out of range", err, path)
+			}
+		})
+	}
+}
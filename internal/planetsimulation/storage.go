@@ -0,0 +1,151 @@
+package planetsimulation
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"PlanetSimulation/internal/planetsimulation/defaults"
+
+	"PlanetSimulation/internal/schema"
+)
+
+// storage keeps named files like the preset files.
+type storage interface {
+	// read returns nothing if name doesn't exist
+	read(name string) ([]byte, error)
+	write(name string, content []byte) error
+	// location describes where name is kept, for messages
+	location(name string) string
+}
+
+var errReadOnly = errors.New("the storage is read-only")
+
+// legacyDataDirectory is where the presets were kept relative to the working
+// directory before they moved to the user config directory.
+const legacyDataDirectory = "assets/data"
+
+// fileStorage keeps the files in a directory. Files are written atomically so
+// a crash while saving never leaves a broken file behind.
+type fileStorage struct {
+	directory string
+}
+
+func newFileStorage(directory string) *fileStorage {
+	return &fileStorage{
+		directory: directory,
+	}
+}
+
+// newUserStorage returns the storage in the config directory of the user:
+// $XDG_CONFIG_HOME (or ~/.config) on Linux, %AppData% on Windows and
+// ~/Library/Application Support on macOS. Files of the legacy data directory
+// are copied over the first time. Without a config directory the legacy data
+// directory is used.
+func newUserStorage(names ...string) (*fileStorage, error) {
+	directory, err := schema.UserDirectory()
+	if err != nil {
+		return newFileStorage(legacyDataDirectory), fmt.Errorf("using %s: %w", legacyDataDirectory, err)
+	}
+
+	storage := newFileStorage(directory)
+	return storage, storage.migrate(newFileStorage(legacyDataDirectory), names...)
+}
+
+func (storage *fileStorage) path(name string) string {
+	return filepath.Join(storage.directory, filepath.FromSlash(name))
+}
+
+func (storage *fileStorage) read(name string) ([]byte, error) {
+	return readFile(storage.path(name))
+}
+
+func (storage *fileStorage) write(name string, content []byte) error {
+	return writeFileAtomic(storage.path(name), content)
+}
+
+func (storage *fileStorage) location(name string) string {
+	return storage.path(name)
+}
+
+// migrate copies the named files of from that don't exist yet.
+func (storage *fileStorage) migrate(from storage, names ...string) error {
+	errs := []error{}
+	for _, name := range names {
+		if _, err := os.Stat(storage.path(name)); !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		content, err := from.read(name)
+		if err == nil && len(content) > 0 {
+			err = storage.write(name, content)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to copy %s: %w", from.location(name), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// memoryStorage keeps the files in memory only.
+type memoryStorage struct {
+	files map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		files: map[string][]byte{},
+	}
+}
+
+func (storage *memoryStorage) read(name string) ([]byte, error) {
+	return slices.Clone(storage.files[name]), nil
+}
+
+func (storage *memoryStorage) write(name string, content []byte) error {
+	storage.files[name] = slices.Clone(content)
+	return nil
+}
+
+func (storage *memoryStorage) location(name string) string {
+	return "memory:" + name
+}
+
+// newDefaultStorage returns the storage of the built-in presets shipped with
+// the binary.
+func newDefaultStorage() *embeddedStorage {
+	return newEmbeddedStorage(defaults.Presets)
+}
+
+// embeddedStorage reads files of a file system like an embed.FS, writing is
+// not possible.
+type embeddedStorage struct {
+	fsys fs.FS
+}
+
+func newEmbeddedStorage(fsys fs.FS) *embeddedStorage {
+	return &embeddedStorage{
+		fsys: fsys,
+	}
+}
+
+func (storage *embeddedStorage) read(name string) ([]byte, error) {
+	content, err := fs.ReadFile(storage.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []byte{}, nil
+	}
+
+	return content, err
+}
+
+func (storage *embeddedStorage) write(name string, content []byte) error {
+	return fmt.Errorf("failed to write %s: %w", name, errReadOnly)
+}
+
+func (storage *embeddedStorage) location(name string) string {
+	return "embedded:" + name
+}
@@ -0,0 +1,123 @@
+package planetsimulation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPlanetPresetsRoundTrip(t *testing.T) {
+	storage := newMemoryStorage()
+	planetPresets := newPlanetPresets(storage, newMemoryStorage())
+	planetPresets.addPlanet(*newTestPlanet("Earth", 10, 20, 5, 50, vector2{1, 2}))
+	if planetPresets.err != nil {
+		t.Fatal(planetPresets.err)
+	}
+
+	loaded := newPlanetPresets(storage, newMemoryStorage())
+	if loaded.err != nil {
+		t.Fatal(loaded.err)
+	}
+	if len(loaded.presets) != 1 {
+		t.Fatalf("got %d presets, want 1", len(loaded.presets))
+	}
+	if preset := loaded.presets[0]; preset.Name != "Earth" || preset.X != 10 || preset.Mass != 50 || preset.Velocity != (vector2{1, 2}) {
+		t.Errorf("got %+v", bodyRecordFromPlanet(preset.Planet))
+	}
+}
+
+func TestBrokenPresetsAreNotOverwritten(t *testing.T) {
+	storage := newMemoryStorage()
+	storage.write(simulationPresetsFileName, []byte("{broken"))
+
+	presets := newSimulationPresets(storage, newMemoryStorage())
+	if !presets.hasLoadError {
+		t.Fatal("expected a load error")
+	}
+	presets.saveSimulationPreset(newTestPlanetHandler())
+
+	content, _ := storage.read(simulationPresetsFileName)
+	if string(content) != "{broken" {
+		t.Errorf("the file was overwritten with %q", content)
+	}
+}
+
+func TestEmbeddedStorageIsReadOnly(t *testing.T) {
+	storage := newEmbeddedStorage(fstest.MapFS{
+		planetPresetsFileName: {Data: []byte(`{"version": 1, "planets": []}`)},
+	})
+
+	planetPresets := newPlanetPresets(storage, newMemoryStorage())
+	if planetPresets.err != nil {
+		t.Fatal(planetPresets.err)
+	}
+	planetPresets.addPlanet(*newTestPlanet("Earth", 0, 0, 5, 50, vector2{}))
+	if !errors.Is(planetPresets.err, errReadOnly) {
+		t.Errorf("err = %v, want %v", planetPresets.err, errReadOnly)
+	}
+
+	content, err := storage.read("missing.json")
+	if err != nil || len(content) != 0 {
+		t.Errorf("read of a missing file = %q, %v", content, err)
+	}
+}
+
+func TestFileStorageMigratesLegacyFiles(t *testing.T) {
+	legacy := newFileStorage(t.TempDir())
+	legacy.write(planetPresetsFileName, []byte("old"))
+	storage := newFileStorage(filepath.Join(t.TempDir(), "config"))
+	storage.write(simulationPresetsFileName, []byte("new"))
+
+	if err := storage.migrate(legacy, planetPresetsFileName, simulationPresetsFileName); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{planetPresetsFileName: "old", simulationPresetsFileName: "new"} {
+		content, err := os.ReadFile(storage.path(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", name, content, want)
+		}
+	}
+}
+
+func TestDefaultPresetsAreBuiltIn(t *testing.T) {
+	storage := newMemoryStorage()
+	planetPresets := newPlanetPresets(storage, newDefaultStorage())
+	simulationPresets := newSimulationPresets(storage, newDefaultStorage())
+	if planetPresets.err != nil {
+		t.Fatal(planetPresets.err)
+	}
+	if simulationPresets.err != nil {
+		t.Fatal(simulationPresets.err)
+	}
+
+	names := []string{}
+	for _, preset := range simulationPresets.all() {
+		if !preset.isBuiltIn {
+			t.Errorf("%s is not built-in", preset.Name)
+		}
+		names = append(names, preset.Name)
+	}
+	for _, name := range []string{"Solar System", "Earth-Moon", "Binary stars", "Figure-eight"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("missing the built-in preset %s, got %v", name, names)
+		}
+	}
+
+	// deleting a built-in preset does nothing
+	count := len(planetPresets.all())
+	planetPresets.deletePreset(0)
+	simulationPresets.removeSimulationPreset(0)
+	if len(planetPresets.all()) != count || len(simulationPresets.all()) != len(names) {
+		t.Error("a built-in preset was deleted")
+	}
+	if len(storage.files) != 0 {
+		t.Error("the built-in presets were written to the user storage")
+	}
+}
@@ -0,0 +1,256 @@
+package planetsimulation
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// comparisonColor draws the compared path so it stands out from the traces.
+var comparisonColor = color.NRGBA{255, 0, 255, 255}
+
+// timelineBranch is one version of the simulation. A fork starts with a copy
+// of the state of its parent.
+type timelineBranch struct {
+	name     string
+	parent   *timelineBranch
+	forkTick int
+	forkTime float64
+	handler  *planetHandler
+	// undo commands refer to the planets of their branch
+	history *history
+}
+
+// timelines keeps the branches forked from the simulation. The current branch
+// is shown and edited, the others keep running in the background unless they
+// are paused, so all of them can be compared at the same tick.
+type timelines struct {
+	branches []*timelineBranch
+	current  *timelineBranch
+	forkName string
+	// the path of the body with compareBodyID in compare is drawn over the
+	// current branch
+	compare       *timelineBranch
+	compareBodyID int
+	shouldFork    bool
+	shouldSwitch  bool
+	switchTarget  *timelineBranch
+	shouldDelete  bool
+	deleteTarget  *timelineBranch
+	status        string
+}
+
+func newTimelines(planetHandler *planetHandler, history *history) *timelines {
+	root := &timelineBranch{
+		name:    "Main",
+		handler: planetHandler,
+		history: history,
+	}
+
+	return &timelines{
+		branches: []*timelineBranch{root},
+		current:  root,
+	}
+}
+
+func (timelines *timelines) children(parent *timelineBranch) []*timelineBranch {
+	children := []*timelineBranch{}
+	for _, branch := range timelines.branches {
+		if branch.parent == parent {
+			children = append(children, branch)
+		}
+	}
+
+	return children
+}
+
+// fork copies the current branch into a new branch and switches to it.
+func (sim *simulation) fork() {
+	timelines := sim.timelines
+	parent := timelines.current
+
+	handler := sim.planetHandler.clone()
+	// the editing state is shared by all branches
+	handler.planetCreator = sim.planetHandler.planetCreator
+	handler.planetPresets = sim.planetHandler.planetPresets
+	for _, planet := range handler.planets {
+		planet.updateImage()
+	}
+
+	name := strings.TrimSpace(timelines.forkName)
+	if name == "" {
+		name = fmt.Sprintf("Branch %d", len(timelines.branches))
+	}
+
+	branch := &timelineBranch{
+		name:     name,
+		parent:   parent,
+		forkTick: handler.tick,
+		forkTime: handler.simulatedTime,
+		handler:  handler,
+		history:  newHistory(),
+	}
+	timelines.branches = append(timelines.branches, branch)
+	timelines.forkName = ""
+
+	sim.switchTimeline(branch)
+	// the parent is the obvious branch to compare with
+	if timelines.compare == nil {
+		timelines.compare = parent
+	}
+	timelines.status = fmt.Sprintf("Forked %s from %s at tick %d", branch.name, parent.name, branch.forkTick)
+}
+
+// switchTimeline shows branch instead of the current branch. The camera stays
+// where it is.
+func (sim *simulation) switchTimeline(branch *timelineBranch) {
+	timelines := sim.timelines
+	current := timelines.current
+	if branch == current {
+		return
+	}
+
+	from, to := sim.planetHandler, branch.handler
+	to.setCamera(from.planetsOffset)
+	// subscribers like the event log follow the shown branch
+	from.events, to.events = to.events, from.events
+
+	current.handler, current.history = from, sim.history
+	sim.planetHandler, sim.history = to, branch.history
+	timelines.current = branch
+
+	// keep comparing the same two branches
+	if timelines.compare == branch {
+		timelines.compare = current
+	}
+	// the recent states belong to the other branch
+	sim.rewind.clear()
+	// the twin belongs to the other branch
+	if sim.chaosAnalysis.isRunning {
+		sim.chaosAnalysis.stop("Stopped, switched to another timeline")
+	}
+}
+
+func (timelines *timelines) deleteBranch(branch *timelineBranch) error {
+	if branch == timelines.current || branch.parent == nil {
+		return fmt.Errorf("%s can't be deleted while it is shown or the main branch", branch.name)
+	}
+	if len(timelines.children(branch)) > 0 {
+		return fmt.Errorf("delete the branches forked from %s first", branch.name)
+	}
+
+	timelines.branches = slices.DeleteFunc(timelines.branches, func(other *timelineBranch) bool {
+		return other == branch
+	})
+	if timelines.compare == branch {
+		timelines.compare = nil
+	}
+
+	return nil
+}
+
+func (sim *simulation) handleTimelines() {
+	timelines := sim.timelines
+
+	if timelines.shouldFork {
+		timelines.shouldFork = false
+		sim.fork()
+	}
+
+	if timelines.shouldSwitch {
+		timelines.shouldSwitch = false
+		if slices.Contains(timelines.branches, timelines.switchTarget) {
+			sim.switchTimeline(timelines.switchTarget)
+			timelines.status = "Switched to " + timelines.current.name
+		}
+		timelines.switchTarget = nil
+	}
+
+	if timelines.shouldDelete {
+		timelines.shouldDelete = false
+		if err := timelines.deleteBranch(timelines.deleteTarget); err != nil {
+			timelines.status = err.Error()
+		} else {
+			timelines.status = "Deleted " + timelines.deleteTarget.name
+		}
+		timelines.deleteTarget = nil
+	}
+}
+
+// Update steps the branches in the background.
+func (timelines *timelines) Update() {
+	for _, branch := range timelines.branches {
+		if branch != timelines.current {
+			branch.handler.Update()
+		}
+	}
+}
+
+// comparedPlanet returns the compared body in the compared branch, nil if
+// there is none.
+func (timelines *timelines) comparedPlanet() *Planet {
+	if timelines.compare == nil || timelines.compare == timelines.current {
+		return nil
+	}
+
+	for _, planet := range timelines.compare.handler.planets {
+		if planet.id == timelines.compareBodyID {
+			return planet
+		}
+	}
+
+	return nil
+}
+
+// divergence returns how far apart the compared body is in both branches.
+func (timelines *timelines) divergence(planetHandler *planetHandler) (float64, bool) {
+	compared := timelines.comparedPlanet()
+	if compared == nil {
+		return 0, false
+	}
+
+	for _, planet := range planetHandler.planets {
+		if planet.id == compared.id {
+			return math.Hypot(planet.X-compared.X, planet.Y-compared.Y), true
+		}
+	}
+
+	return 0, false
+}
+
+// drawComparison draws the path of the compared body over the current
+// branch, projected into the reference frame of the current branch.
+func (timelines *timelines) drawComparison(screen *ebiten.Image, planetHandler *planetHandler) {
+	planet := timelines.comparedPlanet()
+	if planet == nil {
+		return
+	}
+
+	project := planetHandler.frame.projector(planetHandler)
+	offset := planetHandler.planetsOffset
+	for i := 0; i < len(planet.traces)-1; i++ {
+		if i%planet.DrawEveryNTick != 0 {
+			continue
+		}
+
+		currentX, currentY := project(planet.traces[i])
+		nextX, nextY := project(planet.traces[i+1])
+		vector.StrokeLine(
+			screen,
+			float32(currentX+offset[0]),
+			float32(currentY+offset[1]),
+			float32(nextX+offset[0]),
+			float32(nextY+offset[1]),
+			float32(planet.TraceWidth), comparisonColor, planet.AntialiasTraces,
+		)
+	}
+
+	// where the body is now in the other branch
+	x, y := project(tracePoint{planet.X, planet.Y, -1})
+	vector.StrokeCircle(screen, float32(x+offset[0]), float32(y+offset[1]), float32(planet.Radius), 2, comparisonColor, true)
+}
@@ -0,0 +1,170 @@
+package planetsimulation
+
+import (
+	"testing"
+)
+
+// newTestTimelines returns a running simulation with two planets that can be
+// forked.
+func newTestTimelines() *simulation {
+	planetHandler := newTestPlanetHandler()
+	planetHandler.addPlanet(newTestPlanet("a", 0, 0, 1, 1, vector2{10, 0}))
+	planetHandler.addPlanet(newTestPlanet("b", 100, 0, 1, 1, vector2{0, 10}))
+	planetHandler.timeStep = 0.01
+	planetHandler.running = true
+
+	sim := &simulation{
+		planetHandler: planetHandler,
+		history:       newHistory(),
+		rewind:        newRewind(),
+		chaosAnalysis: newChaosAnalysis(),
+	}
+	sim.timelines = newTimelines(planetHandler, sim.history)
+
+	return sim
+}
+
+// updateTimelines steps the shown branch and the ones in the background n
+// times.
+func updateTimelines(sim *simulation, n int) {
+	for range n {
+		sim.planetHandler.Update()
+		sim.timelines.Update()
+	}
+}
+
+func TestForkedBranchesDiverge(t *testing.T) {
+	sim := newTestTimelines()
+	timelines := sim.timelines
+	root := timelines.current
+	rootHandler := sim.planetHandler
+	rootHistory := sim.history
+	updateTimelines(sim, 10)
+
+	sim.fork()
+	branch := timelines.current
+	if branch == root || branch.parent != root || sim.planetHandler == rootHandler || sim.history == rootHistory {
+		t.Fatal("fork didn't switch to a new branch")
+	}
+	if branch.forkTick != 10 || sim.planetHandler.tick != 10 {
+		t.Errorf("forked at tick %d with the handler at %d, want 10", branch.forkTick, sim.planetHandler.tick)
+	}
+
+	// change a body of the fork only
+	a := sim.planetHandler.planets[0]
+	if a == rootHandler.planets[0] || a.id != rootHandler.planets[0].id {
+		t.Fatal("the fork doesn't have a copy of a")
+	}
+	a.Velocity = vector2{-10, 0}
+	updateTimelines(sim, 10)
+
+	// the root kept running in the background
+	rootA := rootHandler.planets[0]
+	if rootHandler.tick != 20 || sim.planetHandler.tick != 20 {
+		t.Fatalf("ticks = %d and %d, want both at 20", rootHandler.tick, sim.planetHandler.tick)
+	}
+	if rootA.X < 1.9 || a.X > 0.1 {
+		t.Errorf("a is at %v in the root and %v in the fork, want 2 and 0", rootA.X, a.X)
+	}
+
+	sim.switchTimeline(root)
+	if timelines.current != root || sim.planetHandler != rootHandler || sim.history != rootHistory {
+		t.Fatal("switching back doesn't show the root")
+	}
+	if branch.handler.planets[0] != a || branch.handler.tick != 20 {
+		t.Error("the fork lost its state when switching away")
+	}
+
+	// the fork keeps running in the background now
+	updateTimelines(sim, 5)
+	if branch.handler.tick != 25 || rootHandler.tick != 25 {
+		t.Errorf("ticks = %d and %d, want both at 25", rootHandler.tick, branch.handler.tick)
+	}
+	if rootA.X <= a.X {
+		t.Errorf("a is at %v in the root and %v in the fork", rootA.X, a.X)
+	}
+}
+
+func TestSwitchTimelineHandsOver(t *testing.T) {
+	sim := newTestTimelines()
+	timelines := sim.timelines
+	root := timelines.current
+	recorder := &eventRecorder{}
+	sim.planetHandler.events.subscribe(recorder.record)
+
+	sim.fork()
+	branch := timelines.current
+
+	// the subscribers follow the shown branch
+	branch.handler.addPlanet(newTestPlanet("c", 50, 50, 1, 1, vector2{}))
+	root.handler.addPlanet(newTestPlanet("d", 50, 50, 1, 1, vector2{}))
+	if len(recorder.events) != 1 || recorder.events[0].Planet != "c" {
+		t.Fatalf("events = %+v, want only c of the shown branch", recorder.events)
+	}
+
+	// the camera stays where it is
+	sim.planetHandler.setCamera([]float64{30, -40})
+	sim.switchTimeline(root)
+	if offset := sim.planetHandler.planetsOffset; offset[0] != 30 || offset[1] != -40 {
+		t.Errorf("camera = %v after switching, want 30, -40", offset)
+	}
+	root.handler.addPlanet(newTestPlanet("e", 50, 50, 1, 1, vector2{}))
+	if last := recorder.events[len(recorder.events)-1]; last.Planet != "e" {
+		t.Errorf("last event = %+v, want e of the root", last)
+	}
+
+	// the compared branch swaps with the shown one
+	if timelines.compare != branch {
+		t.Fatalf("comparing with %v after switching, want the fork", timelines.compare)
+	}
+	timelines.compareBodyID = root.handler.planets[0].id
+	if compared := timelines.comparedPlanet(); compared != branch.handler.planets[0] {
+		t.Errorf("compared planet = %v, want a of the fork", compared)
+	}
+	sim.switchTimeline(branch)
+	if timelines.compare != root {
+		t.Errorf("comparing with %v after switching back, want the root", timelines.compare)
+	}
+
+	branch.handler.planets[0].Velocity = vector2{-10, 0}
+	updateTimelines(sim, 10)
+	if distance, ok := timelines.divergence(sim.planetHandler); !ok || distance < 1 {
+		t.Errorf("divergence = %v, %v, want the paths of a apart", distance, ok)
+	}
+}
+
+func TestDeleteBranch(t *testing.T) {
+	sim := newTestTimelines()
+	timelines := sim.timelines
+	root := timelines.current
+	sim.fork()
+	parent := timelines.current
+	sim.fork()
+	child := timelines.current
+	sim.switchTimeline(root)
+
+	for _, branch := range []*timelineBranch{root, parent} {
+		if err := timelines.deleteBranch(branch); err == nil {
+			t.Errorf("%s was deleted", branch.name)
+		}
+	}
+	sim.switchTimeline(child)
+	if err := timelines.deleteBranch(child); err == nil {
+		t.Error("the shown branch was deleted")
+	}
+
+	sim.switchTimeline(parent)
+	timelines.compare = child
+	if err := timelines.deleteBranch(child); err != nil {
+		t.Fatal(err)
+	}
+	if len(timelines.branches) != 2 || timelines.compare != nil {
+		t.Errorf("%d branches comparing with %v, want 2 without a comparison", len(timelines.branches), timelines.compare)
+	}
+
+	// without its child the parent can be deleted once it isn't shown
+	sim.switchTimeline(root)
+	if err := timelines.deleteBranch(parent); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,161 @@
+package planetsimulation
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"PlanetSimulation/internal/physics"
+)
+
+type recordingFormat int
+
+const (
+	recordingCSV recordingFormat = iota
+	// one JSON object per line
+	recordingNDJSON
+)
+
+var recordingFormatNames = []string{
+	"csv",
+	"ndjson",
+}
+
+// the columns and rows are shared with the headless run
+var trajectoryColumns = physics.TrajectoryColumns
+
+type trajectorySample = physics.TrajectorySample
+
+// trajectoryRecorder streams the state of every planet to a file whenever
+// RecordEveryNTick ticks have passed. Every recorded tick is flushed so
+// nothing is lost if the game is closed while recording.
+//
+// It belongs to the game: the Trajectory Recorder window starts and stops it
+// and the simulation calls record once per update. Scripts record with the
+// headless run instead, its trajectories.csv has the same columns.
+type trajectoryRecorder struct {
+	// file name without extension, the extension follows the format
+	filePath         string
+	format           recordingFormat
+	RecordEveryNTick int
+	isRecording      bool
+	lastTick         int
+	samples          int
+	file             *os.File
+	writer           *bufio.Writer
+	csvWriter        *csv.Writer
+	encoder          *json.Encoder
+	err              error
+}
+
+func newTrajectoryRecorder() *trajectoryRecorder {
+	return &trajectoryRecorder{
+		RecordEveryNTick: 10,
+	}
+}
+
+func (recorder *trajectoryRecorder) fileName() string {
+	return recorder.filePath + "." + recordingFormatNames[recorder.format]
+}
+
+// start creates or truncates the file and records the current state.
+func (recorder *trajectoryRecorder) start(planetHandler *planetHandler) error {
+	if recorder.isRecording {
+		return errors.New("already recording")
+	}
+
+	fileName := recorder.fileName()
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+
+	recorder.file = file
+	recorder.writer = bufio.NewWriter(file)
+	recorder.csvWriter = nil
+	recorder.encoder = nil
+	switch recorder.format {
+	case recordingNDJSON:
+		recorder.encoder = json.NewEncoder(recorder.writer)
+	default:
+		recorder.csvWriter = csv.NewWriter(recorder.writer)
+		if err := recorder.csvWriter.Write(trajectoryColumns); err != nil {
+			return errors.Join(err, file.Close())
+		}
+	}
+
+	recorder.isRecording = true
+	recorder.samples = 0
+	recorder.err = nil
+
+	if err := recorder.write(planetHandler); err != nil {
+		return errors.Join(err, recorder.stop())
+	}
+
+	return nil
+}
+
+// stop flushes and closes the file.
+func (recorder *trajectoryRecorder) stop() error {
+	if !recorder.isRecording {
+		return nil
+	}
+	recorder.isRecording = false
+
+	err := recorder.flush()
+	return errors.Join(err, recorder.file.Close())
+}
+
+func (recorder *trajectoryRecorder) flush() error {
+	if recorder.csvWriter != nil {
+		recorder.csvWriter.Flush()
+		if err := recorder.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return recorder.writer.Flush()
+}
+
+func (recorder *trajectoryRecorder) write(planetHandler *planetHandler) error {
+	recorder.lastTick = planetHandler.tick
+
+	for _, planet := range planetHandler.planets {
+		sample := physics.NewTrajectorySample(planetHandler.tick, planetHandler.simulatedTime, planet.body())
+
+		var err error
+		if recorder.encoder != nil {
+			err = recorder.encoder.Encode(sample)
+		} else {
+			err = recorder.csvWriter.Write(sample.Row())
+		}
+		if err != nil {
+			return err
+		}
+		recorder.samples++
+	}
+
+	return recorder.flush()
+}
+
+// record writes the state if enough ticks have passed since the last one.
+// Errors stop the recording and are kept in err.
+func (recorder *trajectoryRecorder) record(planetHandler *planetHandler) {
+	if !recorder.isRecording || planetHandler.tick == recorder.lastTick {
+		return
+	}
+	// a loaded snapshot can go back in time
+	if planetHandler.tick > recorder.lastTick && planetHandler.tick-recorder.lastTick < max(recorder.RecordEveryNTick, 1) {
+		return
+	}
+
+	if err := recorder.write(planetHandler); err != nil {
+		recorder.err = errors.Join(err, recorder.stop())
+	}
+}
@@ -0,0 +1,135 @@
+package planetsimulation
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// recordTrajectories records two moving planets for 10 ticks, every third
+// one, and returns the file.
+func recordTrajectories(t *testing.T, format recordingFormat) string {
+	t.Helper()
+	planetHandler := newTestPlanetHandler()
+	planetHandler.addPlanet(newTestPlanet("a", 0, 0, 1, 1, vector2{10, 0}))
+	planetHandler.addPlanet(newTestPlanet("b", 100, 0, 1, 2, vector2{0, 10}))
+	planetHandler.timeStep = 0.01
+	planetHandler.running = true
+
+	recorder := newTrajectoryRecorder()
+	recorder.filePath = filepath.Join(t.TempDir(), "trajectory")
+	recorder.format = format
+	recorder.RecordEveryNTick = 3
+	if err := recorder.start(planetHandler); err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		planetHandler.Update()
+		recorder.record(planetHandler)
+	}
+	if err := recorder.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.err != nil {
+		t.Fatal(recorder.err)
+	}
+
+	return recorder.fileName()
+}
+
+// checkSamples compares the recorded ticks and planets with the interval.
+func checkSamples(t *testing.T, samples []trajectorySample) {
+	t.Helper()
+	if len(samples) != 8 {
+		t.Fatalf("%d samples, want 2 planets at 4 ticks", len(samples))
+	}
+
+	for i, sample := range samples {
+		wantTick := i / 2 * 3
+		wantName, wantMass := "a", 1.0
+		if i%2 == 1 {
+			wantName, wantMass = "b", 2
+		}
+		if sample.Tick != wantTick || sample.Name != wantName || sample.Mass != wantMass {
+			t.Errorf("sample %d = %+v, want %s at tick %d", i, sample, wantName, wantTick)
+		}
+		if wantTime := float64(wantTick) * 0.01; !closeTo(sample.Time, wantTime) {
+			t.Errorf("sample %d at %v, want %v", i, sample.Time, wantTime)
+		}
+	}
+
+	a, b := samples[6], samples[7]
+	if a.ID == b.ID || a.ID != samples[0].ID {
+		t.Errorf("ids = %d and %d, want the same planet to keep its id", a.ID, b.ID)
+	}
+	if !closeTo(a.X, 10*a.Time) || a.VX != 10 || !closeTo(b.Y, 10*b.Time) || b.VY != 10 {
+		t.Errorf("last samples %+v and %+v don't follow the velocities", a, b)
+	}
+}
+
+func TestRecordCSV(t *testing.T) {
+	file, err := os.Open(recordTrajectories(t, recordingCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) == 0 || !slices.Equal(rows[0], trajectoryColumns) {
+		t.Fatalf("header = %v, want %v", rows[0], trajectoryColumns)
+	}
+
+	samples := []trajectorySample{}
+	for _, row := range rows[1:] {
+		values := make([]float64, len(row))
+		for i, value := range row {
+			if trajectoryColumns[i] == "name" {
+				continue
+			}
+			if values[i], err = strconv.ParseFloat(value, 64); err != nil {
+				t.Fatal(err)
+			}
+		}
+		samples = append(samples, trajectorySample{
+			Tick: int(values[0]),
+			Time: values[1],
+			ID:   int(values[2]),
+			Name: row[3],
+			X:    values[4],
+			Y:    values[5],
+			VX:   values[6],
+			VY:   values[7],
+			Mass: values[8],
+		})
+	}
+	checkSamples(t, samples)
+}
+
+func TestRecordNDJSON(t *testing.T) {
+	file, err := os.Open(recordTrajectories(t, recordingNDJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	samples := []trajectorySample{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		sample := trajectorySample{}
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	checkSamples(t, samples)
+}
@@ -3,7 +3,11 @@ package planetsimulation
 import (
 	"fmt"
 	"image"
+	"log"
+	"math"
 	"slices"
+	"strconv"
+	"time"
 
 	"github.com/ebitengine/debugui"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -18,6 +22,12 @@ type ui struct {
 	layouts             []image.Rectangle
 	hasRemovedPlanet    bool
 	pauseSimulationText string
+	eventLog            []simulationEvent
+	eventLogSize        int
+	eventLogWriter      *eventLogWriter
+	eventLogWriterID    int
+	writeEventLog       bool
+	eventLogFilePath    string
 }
 
 func newUI() *ui {
@@ -27,6 +37,7 @@ func newUI() *ui {
 		hasFocus:            debugui.InputCapturingState(0),
 		hasRemovedPlanet:    false,
 		pauseSimulationText: "Pause simulation",
+		eventLogSize:        200,
 	}
 
 	return ui
@@ -39,12 +50,30 @@ func (ui *ui) Update(sim *simulation, planetHandler *planetHandler) error {
 		// set global context
 		ui.ctx = ctx
 
+		ui.replayWindow(ctx, sim.replay)
+		// nothing can be edited while a replay plays
+		if sim.replay.isPlaying() {
+			return nil
+		}
+		ui.rewindWindow(ctx, sim.rewind)
+		if sim.rewind.isRewinding() {
+			return nil
+		}
+
 		ui.createSystemWindow(ctx, planetHandler, sim)
 		ui.createPlanetWindow(ctx, planetHandler)
-		ui.modifyPlanetWindow(ctx, planetHandler)
-		ui.planetListWindow(ctx, planetHandler, sim.gameSize)
-		ui.planetPresetsWindow(ctx, planetHandler, sim.gameSize)
-		ui.simulationPresetsWindow(ctx, sim.simulationPresets, planetHandler, sim.gameSize)
+		ui.modifyPlanetWindow(ctx, planetHandler, sim.history)
+		ui.planetListWindow(ctx, planetHandler, sim.history, sim.gameSize)
+		ui.planetPresetsWindow(ctx, planetHandler, sim.history, sim.gameSize)
+		ui.simulationPresetsWindow(ctx, sim.simulationPresets, planetHandler, sim.history, sim.gameSize)
+		ui.eventLogWindow(ctx, planetHandler, sim.gameSize)
+		ui.generateWindow(ctx, sim.scenarioGenerator)
+		ui.chaosAnalysisWindow(ctx, sim.chaosAnalysis, planetHandler)
+		ui.bodyTableWindow(ctx, sim.bodyTable)
+		ui.horizonsImportWindow(ctx, sim.horizonsImport)
+		ui.recorderWindow(ctx, sim.recorder, planetHandler)
+		ui.timelinesWindow(ctx, sim.timelines, planetHandler)
+		ui.recoveryWindow(ctx, sim.autosave, sim.gameSize)
 		return err
 	})
 	return err
@@ -89,7 +118,24 @@ func (ui *ui) createSystemWindow(ctx *debugui.Context, planetHandler *planetHand
 				ctx.Text("Gravitational Constant:")
 				ctx.NumberFieldF(&planetHandler.gravitationalConstant, 0.1, 2)
 			})
+			integratorIndex := int(planetHandler.integrator)
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-2, -1}, []int{-1})
+				ctx.Text("Integrator:")
+				ctx.Dropdown(&integratorIndex, integratorNames).On(func() {
+					planetHandler.integrator = integrator(integratorIndex)
+				})
+			})
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-2, -1}, []int{-1})
+				ctx.Text("Time step (0 = frame time):")
+				ctx.NumberFieldF(&planetHandler.timeStep, 0.001, 4).On(func() {
+					planetHandler.timeStep = max(planetHandler.timeStep, 0)
+				})
+			})
 		})
+		ui.referenceFrameHeader(ctx, planetHandler)
+		ui.snapshotsHeader(ctx, sim.snapshots, sim.autosave)
 
 		ctx.Button(ui.pauseSimulationText).On(func() {
 			planetHandler.running = !planetHandler.running
@@ -105,14 +151,107 @@ func (ui *ui) createSystemWindow(ctx *debugui.Context, planetHandler *planetHand
 		})
 
 		ctx.Button("Clear all traces").On(func() {
-			for _, planet := range planetHandler.planets {
-				planet.clearTraces()
-			}
+			planetHandler.clearTraces()
 		})
 
 		ctx.Button("Reset Simulation").On(func() {
 			sim.shouldReset = true
 		})
+
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -1}, []int{-1})
+			ctx.Button("Undo (Ctrl+Z)").On(func() {
+				sim.history.shouldUndo = true
+			})
+			ctx.Button("Redo (Ctrl+Y)").On(func() {
+				sim.history.shouldRedo = true
+			})
+		})
+	})
+}
+
+func (ui *ui) snapshotsHeader(ctx *debugui.Context, snapshots *snapshots, autosave *autosave) {
+	ctx.Header("Snapshots", false, func() {
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+			ctx.Text("name:")
+			ctx.TextField(&snapshots.name)
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -1}, []int{-1})
+			ctx.Button("Save").On(func() {
+				if snapshots.name != "" {
+					snapshots.save(snapshots.name)
+				}
+			})
+			ctx.Button("Load").On(func() {
+				if snapshots.name != "" {
+					snapshots.load(snapshots.name)
+				}
+			})
+		})
+		ctx.Text("F5 quick save, F9 quick load")
+		if snapshots.err != nil {
+			ctx.Text(snapshots.err.Error())
+		} else if snapshots.status != "" {
+			ctx.Text(snapshots.status)
+		}
+
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -1}, []int{-1})
+			ctx.Checkbox(&autosave.isEnabled, "Autosave every (s)")
+			ctx.NumberField(&autosave.interval, 1).On(func() {
+				autosave.interval = max(autosave.interval, 5)
+			})
+		})
+		if autosave.err != nil {
+			ctx.Text(autosave.err.Error())
+		} else if autosave.status != "" {
+			ctx.Text(autosave.status)
+		}
+	})
+}
+
+func (ui *ui) referenceFrameHeader(ctx *debugui.Context, planetHandler *planetHandler) {
+	ctx.Header("Reference Frame", false, func() {
+		frame := &planetHandler.frame
+		kind := int(frame.kind)
+		ctx.Dropdown(&kind, frameKindNames).On(func() {
+			frame.kind = frameKind(kind)
+		})
+
+		if frame.kind != frameBody && frame.kind != frameCoRotating || len(planetHandler.planets) == 0 {
+			return
+		}
+
+		names := make([]string, len(planetHandler.planets))
+		for i, planet := range planetHandler.planets {
+			names[i] = planet.Name
+		}
+
+		planetIndex := max(slices.Index(planetHandler.planets, frame.planet), 0)
+		frame.planet = planetHandler.planets[planetIndex]
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+			ctx.Text("planet:")
+			ctx.Dropdown(&planetIndex, names).On(func() {
+				frame.planet = planetHandler.planets[planetIndex]
+			})
+		})
+
+		if frame.kind != frameCoRotating {
+			return
+		}
+
+		otherPlanetIndex := max(slices.Index(planetHandler.planets, frame.otherPlanet), 0)
+		frame.otherPlanet = planetHandler.planets[otherPlanetIndex]
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+			ctx.Text("with:")
+			ctx.Dropdown(&otherPlanetIndex, names).On(func() {
+				frame.otherPlanet = planetHandler.planets[otherPlanetIndex]
+			})
+		})
 	})
 }
 
@@ -208,7 +347,7 @@ func (ui *ui) createPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 	})
 }
 
-func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHandler) {
+func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHandler, history *history) {
 	if !planetHandler.selectedPlanet.isSelected || planetHandler.selectedPlanet.index >= len(planetHandler.planets) {
 		return
 	}
@@ -223,9 +362,18 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 		ctx.GridCell(func(bounds image.Rectangle) {
 			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
 			ctx.Text("Name: ")
+			oldName, oldHasNameChanged := selectedPlanet.Name, selectedPlanet.HasNameChanged
 			ctx.TextField(&selectedPlanet.Name).On(func() {
 				selectedPlanet.HasNameChanged = true
 			})
+			// the text field changes the name while typing
+			if name := selectedPlanet.Name; name != oldName {
+				history.editPlanet(selectedPlanet, "name", func(p *Planet) {
+					p.Name, p.HasNameChanged = oldName, oldHasNameChanged
+				}, func(p *Planet) {
+					p.Name, p.HasNameChanged = name, true
+				})
+			}
 		})
 		ctx.GridCell(func(bounds image.Rectangle) {
 			ctx.SetGridLayout([]int{-2}, []int{-1, -1})
@@ -234,7 +382,12 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 				ctx.SetGridLayout([]int{-2, -2}, []int{-1})
 				ctx.Text("x: ")
 				ctx.NumberFieldF(&x, 1.0, 1).On(func() {
-					selectedPlanet.setPosition(x, selectedPlanet.Y)
+					oldX := selectedPlanet.X
+					history.editPlanet(selectedPlanet, "x", func(p *Planet) {
+						p.setPosition(oldX, p.Y)
+					}, func(p *Planet) {
+						p.setPosition(x, p.Y)
+					})
 				})
 			})
 			// fake negate
@@ -243,7 +396,12 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 				ctx.SetGridLayout([]int{-2, -2}, []int{-1})
 				ctx.Text("y: ")
 				ctx.NumberFieldF(&y, 1.0, 1).On(func() {
-					selectedPlanet.setPosition(selectedPlanet.X, -y)
+					oldY := selectedPlanet.Y
+					history.editPlanet(selectedPlanet, "y", func(p *Planet) {
+						p.setPosition(p.X, oldY)
+					}, func(p *Planet) {
+						p.setPosition(p.X, -y)
+					})
 				})
 			})
 		})
@@ -253,9 +411,15 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 			ctx.Text("radius: ")
 			ctx.NumberFieldF(&radius, 1.0, 1).On(func() {
 				if radius > 0 && radius < 1000 {
-					selectedPlanet.Radius = radius
+					oldRadius := selectedPlanet.Radius
+					history.editPlanet(selectedPlanet, "radius", func(p *Planet) {
+						p.Radius = oldRadius
+						p.updateImage()
+					}, func(p *Planet) {
+						p.Radius = radius
+						p.updateImage()
+					})
 				}
-				selectedPlanet.updateImage()
 			})
 		})
 		mass := selectedPlanet.Mass
@@ -264,14 +428,27 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 			ctx.Text("mass: ")
 			ctx.NumberFieldF(&mass, 1.0, 1).On(func() {
 				if mass > 0 {
-					selectedPlanet.Mass = mass
+					oldMass := selectedPlanet.Mass
+					history.editPlanet(selectedPlanet, "mass", func(p *Planet) {
+						p.Mass = oldMass
+					}, func(p *Planet) {
+						p.Mass = mass
+					})
 				}
 			})
 		})
 		ctx.GridCell(func(bounds image.Rectangle) {
 			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
 			ctx.Text("velocity x: ")
-			ctx.NumberFieldF(&selectedPlanet.Velocity.X, 1.0, 1)
+			velocityX := selectedPlanet.Velocity.X
+			ctx.NumberFieldF(&velocityX, 1.0, 1).On(func() {
+				oldVelocityX := selectedPlanet.Velocity.X
+				history.editPlanet(selectedPlanet, "velocity x", func(p *Planet) {
+					p.Velocity.X = oldVelocityX
+				}, func(p *Planet) {
+					p.Velocity.X = velocityX
+				})
+			})
 		})
 		// fake negate
 		velocityY := -selectedPlanet.Velocity.Y
@@ -279,14 +456,26 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
 			ctx.Text("velocity y: ")
 			ctx.NumberFieldF(&velocityY, 1.0, 1).On(func() {
-				selectedPlanet.Velocity.Y = -velocityY
+				oldVelocityY := selectedPlanet.Velocity.Y
+				history.editPlanet(selectedPlanet, "velocity y", func(p *Planet) {
+					p.Velocity.Y = oldVelocityY
+				}, func(p *Planet) {
+					p.Velocity.Y = -velocityY
+				})
 			})
 		})
 		ctx.Button("Focus Planet").On(func() {
 			planetHandler.focusPlanet(planetHandler.selectedPlanet.index)
 		})
+		ctx.Button("Focus System").On(func() {
+			planetHandler.focusSystem(planetHandler.selectedPlanet.index)
+		})
+		ui.orbitHeader(ctx, planetHandler, selectedPlanet)
 		ctx.Header("Color", true, func() {
 			r, g, b, _ := selectedPlanet.getColor()
+			editColor := func(colorDelta ColorDelta) {
+				history.editPlanetColor(selectedPlanet, colorDelta)
+			}
 			ctx.GridCell(func(bounds image.Rectangle) {
 				ctx.SetGridLayout([]int{-3, -1}, []int{59})
 				ctx.GridCell(func(bounds image.Rectangle) {
@@ -294,17 +483,17 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 					ctx.Text("r: ")
 					ctx.Slider(&r, 0, 255, 1).On(func() {
 						red := uint8(r)
-						selectedPlanet.changeColor(ColorDelta{R: &red})
+						editColor(ColorDelta{R: &red})
 					})
 					ctx.Text("g: ")
 					ctx.Slider(&g, 0, 255, 1).On(func() {
 						green := uint8(g)
-						selectedPlanet.changeColor(ColorDelta{G: &green})
+						editColor(ColorDelta{G: &green})
 					})
 					ctx.Text("b: ")
 					ctx.Slider(&b, 0, 255, 1).On(func() {
 						blue := uint8(b)
-						selectedPlanet.changeColor(ColorDelta{B: &blue})
+						editColor(ColorDelta{B: &blue})
 					})
 				})
 				ctx.GridCell(func(bounds image.Rectangle) {
@@ -321,17 +510,41 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 			ctx.GridCell(func(bounds image.Rectangle) {
 				ctx.SetGridLayout([]int{-3, -2}, []int{-1})
 				ctx.Text("trace every Nth tick:")
-				ctx.Slider(&selectedPlanet.TraceEveryNTick, 1, 15, 1)
+				traceEveryNTick := selectedPlanet.TraceEveryNTick
+				ctx.Slider(&traceEveryNTick, 1, 15, 1).On(func() {
+					old := selectedPlanet.TraceEveryNTick
+					history.editPlanet(selectedPlanet, "trace every Nth tick", func(p *Planet) {
+						p.TraceEveryNTick = old
+					}, func(p *Planet) {
+						p.TraceEveryNTick = traceEveryNTick
+					})
+				})
 			})
 			ctx.GridCell(func(bounds image.Rectangle) {
 				ctx.SetGridLayout([]int{-3, -2}, []int{-1})
 				ctx.Text("draw every Nth tick:")
-				ctx.Slider(&selectedPlanet.DrawEveryNTick, 1, 15, 1)
+				drawEveryNTick := selectedPlanet.DrawEveryNTick
+				ctx.Slider(&drawEveryNTick, 1, 15, 1).On(func() {
+					old := selectedPlanet.DrawEveryNTick
+					history.editPlanet(selectedPlanet, "draw every Nth tick", func(p *Planet) {
+						p.DrawEveryNTick = old
+					}, func(p *Planet) {
+						p.DrawEveryNTick = drawEveryNTick
+					})
+				})
 			})
 			ctx.GridCell(func(bounds image.Rectangle) {
 				ctx.SetGridLayout([]int{-3, -2}, []int{-1})
 				ctx.Text("trace width:")
-				ctx.SliderF(&selectedPlanet.TraceWidth, 1, 15, 1, 0)
+				traceWidth := selectedPlanet.TraceWidth
+				ctx.SliderF(&traceWidth, 1, 15, 1, 0).On(func() {
+					old := selectedPlanet.TraceWidth
+					history.editPlanet(selectedPlanet, "trace width", func(p *Planet) {
+						p.TraceWidth = old
+					}, func(p *Planet) {
+						p.TraceWidth = traceWidth
+					})
+				})
 			})
 			ctx.Button("Clear Traces").On(func() {
 				selectedPlanet.clearTraces()
@@ -347,83 +560,176 @@ func (ui *ui) modifyPlanetWindow(ctx *debugui.Context, planetHandler *planetHand
 				ui.hasRemovedPlanet = false
 				return
 			}
-			planetHandler.deleteSelectedPlanet()
+			history.deletePlanet(planetHandler, planetHandler.selectedPlanet.index)
 			ui.hasRemovedPlanet = true
 		})
 	})
 }
 
-func (ui *ui) planetListWindow(ctx *debugui.Context, planetHandler *planetHandler, screenSize []int) {
+func (ui *ui) orbitHeader(ctx *debugui.Context, planetHandler *planetHandler, selectedPlanet *Planet) {
+	ctx.Header("Orbit", false, func() {
+		orbit, ok := planetHandler.hierarchy.orbit(selectedPlanet, planetHandler.planets, planetHandler.gravitationalConstant)
+		if !ok {
+			ctx.Text("Not bound to any planet")
+			return
+		}
+
+		radius := hillRadius(selectedPlanet, orbit.parent)
+		readouts := [][]string{
+			{"orbits:", orbit.parent.Name},
+			{"distance:", formatFloat(orbit.distance, 1)},
+			{"relative speed:", formatFloat(orbit.speed, 1)},
+			{"semi-major axis:", formatFloat(orbit.semiMajorAxis, 1)},
+			{"eccentricity:", formatFloat(orbit.eccentricity, 3)},
+			{"period:", formatFloat(orbit.period, 1)},
+			{"hill radius:", formatFloat(radius, 1)},
+		}
+		if math.IsInf(orbit.period, 1) {
+			readouts[5][1] = "unbound"
+		}
+
+		for _, readout := range readouts {
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+				ctx.Text(readout[0])
+				ctx.Text(readout[1])
+			})
+		}
+
+		ctx.Button("Focus Parent").On(func() {
+			planetHandler.focusSystem(slices.Index(planetHandler.planets, orbit.parent))
+		})
+	})
+}
+
+func (ui *ui) planetListWindow(ctx *debugui.Context, planetHandler *planetHandler, history *history, screenSize []int) {
 	ctx.Window("Planets", image.Rect(screenSize[0]-200, 0, screenSize[0], 300), func(layout debugui.ContainerLayout) {
 		ui.layouts = append(ui.layouts, layout.BodyBounds)
-		for i, planet := range planetHandler.planets {
-			ctx.IDScope("grid "+string(i), func() {
-				ctx.GridCell(func(bounds image.Rectangle) {
-					ctx.SetGridLayout([]int{15, -4, 15}, []int{20})
-					ctx.DrawOnlyWidget(func(screen *ebiten.Image) {
-						cx := float32(bounds.Min.X) + 7
-						cy := float32(bounds.Min.Y) + float32(bounds.Dy())/2
-						r := float32(8)
-						vector.FillCircle(screen, cx, cy, r, planet.Color, true)
-					})
-					ctx.IDScope("button "+string(i), func() {
-						ctx.Button(fmt.Sprintf("%s: %.1f, %.1f", planet.Name, planet.X, planet.Y)).On(func() {
-							planetHandler.selectPlanet(i)
-							planetHandler.focusPlanet(i)
-						})
-					})
-					ctx.Button("X").On(func() {
-						planetHandler.deletePlanet(i)
-					})
+		childMap := planetHandler.hierarchy.children(planetHandler.planets)
+		for _, planet := range planetHandler.hierarchy.roots(planetHandler.planets) {
+			ui.planetTreeNode(ctx, planetHandler, history, childMap, planet)
+		}
+	})
+}
+
+func (ui *ui) planetTreeNode(ctx *debugui.Context, planetHandler *planetHandler, history *history, childMap map[*Planet][]*Planet, planet *Planet) {
+	i := slices.Index(planetHandler.planets, planet)
+	children := childMap[planet]
+
+	ctx.IDScope("grid "+strconv.Itoa(i), func() {
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{15, -4, 15}, []int{20})
+			ctx.DrawOnlyWidget(func(screen *ebiten.Image) {
+				cx := float32(bounds.Min.X) + 7
+				cy := float32(bounds.Min.Y) + float32(bounds.Dy())/2
+				r := float32(8)
+				vector.FillCircle(screen, cx, cy, r, planet.Color, true)
+			})
+			ctx.IDScope("button "+strconv.Itoa(i), func() {
+				ctx.Button(fmt.Sprintf("%s: %.1f, %.1f", planet.Name, planet.X, planet.Y)).On(func() {
+					planetHandler.selectPlanet(i)
+					planetHandler.focusPlanet(i)
 				})
 			})
+			ctx.Button("X").On(func() {
+				history.deletePlanet(planetHandler, i)
+			})
+		})
+
+		if len(children) == 0 {
+			return
 		}
+
+		ctx.TreeNode(fmt.Sprintf("Satellites (%d)", len(children)), func() {
+			for _, child := range children {
+				ui.planetTreeNode(ctx, planetHandler, history, childMap, child)
+			}
+		})
 	})
 }
 
-func (ui *ui) planetPresetsWindow(ctx *debugui.Context, planetHandler *planetHandler, screenSize []int) {
+func (ui *ui) planetPresetsWindow(ctx *debugui.Context, planetHandler *planetHandler, history *history, screenSize []int) {
 	ctx.Window("Planet Presets", image.Rect(screenSize[0]-200, 320, screenSize[0], 620), func(layout debugui.ContainerLayout) {
 		ui.layouts = append(ui.layouts, layout.BodyBounds)
-		for i, planet := range planetHandler.planetPresets.presets {
-			if planet == nil {
-				continue
+		planetPresets := planetHandler.planetPresets
+		browser := planetPresets.browser
+		if planetPresets.err != nil {
+			ctx.Text(planetPresets.err.Error())
+		}
+
+		presets := planetPresets.all()
+		ui.presetFilter(ctx, browser, len(presets))
+		if browser.selected >= 0 {
+			i := browser.selected
+			preset := presets[i]
+			ui.presetDetails(ctx, browser, &preset.Name, &preset.presetMetadata, planetPresets.isBuiltIn(i), func() {
+				planetPresets.editPreset(i)
+			})
+			ui.presetActions(ctx, browser, planetPresets.isBuiltIn(i), func() {
+				browser.selectPreset(planetPresets.duplicatePreset(i), planetPresets.all()[i].presetMetadata)
+			}, func(delta int) {
+				browser.selected = planetPresets.movePreset(i, delta)
+			})
+		}
+
+		ui.presetList(ctx, browser, len(presets), func(i int) presetMetadata {
+			return presets[i].presetMetadata
+		}, func(i int) string {
+			return presets[i].Name
+		}, func(i int) {
+			preset := presets[i]
+			if ui.confirmPresetDelete(ctx, browser, i, preset.Name, func() {
+				history.deletePlanetPreset(planetPresets, i)
+			}) {
+				return
 			}
-			ctx.IDScope("grid "+string(i), func() {
-				ctx.GridCell(func(bounds image.Rectangle) {
-					ctx.SetGridLayout([]int{15, -3, 15}, []int{20})
-					ctx.DrawOnlyWidget(func(screen *ebiten.Image) {
-						cx := float32(bounds.Min.X) + 7
-						cy := float32(bounds.Min.Y) + float32(bounds.Dy())/2
-						r := float32(8)
-						vector.FillCircle(screen, cx, cy, r, planet.Color, true)
-					})
-					ctx.IDScope("button "+string(i), func() {
-						ctx.Button(fmt.Sprintf("%s", planet.Name)).On(func() {
-							planetHandler.planetCreator.planet = newPlanet(
-								planet.Name,
-								planetHandler.planetCreator.planet.X,
-								planetHandler.planetCreator.planet.Y,
-								planet.Radius,
-								planet.Mass,
-								planet.Velocity,
-								planet.Color,
-								planet.Offset,
-							)
-							planetHandler.planetCreator.planet.HasNameChanged = true
-						})
+
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{15, -3, 20, 15}, []int{20})
+				ctx.DrawOnlyWidget(func(screen *ebiten.Image) {
+					cx := float32(bounds.Min.X) + 7
+					cy := float32(bounds.Min.Y) + float32(bounds.Dy())/2
+					r := float32(8)
+					vector.FillCircle(screen, cx, cy, r, preset.Color, true)
+				})
+				ctx.IDScope("button "+strconv.Itoa(i), func() {
+					name := preset.Name
+					// built-in presets are read-only
+					if planetPresets.isBuiltIn(i) {
+						name += " (built-in)"
+					}
+					ctx.Button(name).On(func() {
+						planetHandler.planetCreator.planet = newPlanet(
+							preset.Name,
+							planetHandler.planetCreator.planet.X,
+							planetHandler.planetCreator.planet.Y,
+							preset.Radius,
+							preset.Mass,
+							preset.Velocity,
+							preset.Color,
+							preset.Offset,
+						)
+						planetHandler.planetCreator.planet.HasNameChanged = true
 					})
+				})
+				ui.presetDetailsButton(ctx, browser, i, preset.presetMetadata)
+				if !planetPresets.isBuiltIn(i) {
 					ctx.Button("X").On(func() {
-						planetHandler.planetPresets.deletePreset(i)
+						browser.pendingDelete = i
 					})
-				})
+				}
 			})
-		}
+		})
 	})
 }
 
-func (ui *ui) simulationPresetsWindow(ctx *debugui.Context, simulationPresets *simulationPresets, planetHandler *planetHandler, screenSize []int) {
+func (ui *ui) simulationPresetsWindow(ctx *debugui.Context, simulationPresets *simulationPresets, planetHandler *planetHandler, history *history, screenSize []int) {
 	ctx.Window("Simulation Presets", image.Rect(screenSize[0]-200, 630, screenSize[0], 940), func(layout debugui.ContainerLayout) {
 		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		browser := simulationPresets.browser
+		if simulationPresets.err != nil {
+			ctx.Text(simulationPresets.err.Error())
+		}
 		ctx.GridCell(func(bounds image.Rectangle) {
 
 			ctx.Text("Name:")
@@ -432,24 +738,566 @@ func (ui *ui) simulationPresetsWindow(ctx *debugui.Context, simulationPresets *s
 		ctx.Button("Save simulation to presets").On(func() {
 			simulationPresets.saveSimulationPreset(planetHandler)
 		})
-		for i, simulationPreset := range simulationPresets.Presets {
-			if simulationPreset == nil {
-				continue
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, 60}, []int{-1})
+			ctx.TextField(&simulationPresets.code).On(func() {
+				simulationPresets.shouldAddCode = true
+			})
+			ctx.Button("Paste code").On(func() {
+				simulationPresets.shouldPasteCode = true
+			})
+		})
+		if simulationPresets.codeErr != nil {
+			ctx.Text(simulationPresets.codeErr.Error())
+		} else if simulationPresets.codeStatus != "" {
+			ctx.Text(simulationPresets.codeStatus)
+		}
+
+		presets := simulationPresets.all()
+		ui.presetFilter(ctx, browser, len(presets))
+		if browser.selected >= 0 {
+			i := browser.selected
+			preset := presets[i]
+			ui.presetDetails(ctx, browser, &preset.Name, &preset.presetMetadata, preset.isBuiltIn, func() {
+				simulationPresets.editSimulationPreset(i)
+			})
+			ctx.Text(fmt.Sprintf("%d bodies", len(preset.Planets)))
+			if preset.Integrator != "" {
+				ctx.Text(fmt.Sprintf("needs %s with dt %s", preset.Integrator, formatFloat(preset.TimeStep, 5)))
 			}
-			ctx.IDScope("grid "+string(i), func() {
-				ctx.GridCell(func(bounds image.Rectangle) {
-					ctx.SetGridLayout([]int{-3, 15}, []int{20})
-					ctx.IDScope("button "+string(i), func() {
-						ctx.Button(fmt.Sprintf("%s", simulationPreset.Name)).On(func() {
-							simulationPresets.shouldLoadSimulation = true
-							simulationPresets.presetIndex = i
-						})
+			ui.presetActions(ctx, browser, preset.isBuiltIn, func() {
+				browser.selectPreset(simulationPresets.duplicateSimulationPreset(i), preset.presetMetadata)
+			}, func(delta int) {
+				browser.selected = simulationPresets.moveSimulationPreset(i, delta)
+			})
+		}
+
+		ui.presetList(ctx, browser, len(presets), func(i int) presetMetadata {
+			return presets[i].presetMetadata
+		}, func(i int) string {
+			return presets[i].Name
+		}, func(i int) {
+			simulationPreset := presets[i]
+			if ui.confirmPresetDelete(ctx, browser, i, simulationPreset.Name, func() {
+				history.deleteSimulationPreset(simulationPresets, i)
+			}) {
+				return
+			}
+
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-3, 40, 20, 15}, []int{20})
+				ctx.IDScope("button "+strconv.Itoa(i), func() {
+					name := simulationPreset.Name
+					// built-in presets are read-only
+					if simulationPreset.isBuiltIn {
+						name += " (built-in)"
+					}
+					ctx.Button(name).On(func() {
+						simulationPresets.shouldLoadSimulation = true
+						simulationPresets.presetIndex = i
 					})
+				})
+				ctx.Button("Copy").On(func() {
+					simulationPresets.shouldCopyCode = true
+					simulationPresets.codeIndex = i
+				})
+				ui.presetDetailsButton(ctx, browser, i, simulationPreset.presetMetadata)
+				if !simulationPreset.isBuiltIn {
 					ctx.Button("X").On(func() {
-						simulationPresets.removeSimulationPreset(i)
+						browser.pendingDelete = i
+					})
+				}
+			})
+		})
+	})
+}
+
+// presetFilter draws the filter of a presets window and forgets the
+// selection if the preset is gone, e.g. after an undo.
+func (ui *ui) presetFilter(ctx *debugui.Context, browser *presetBrowser, count int) {
+	if browser.selected >= count {
+		browser.selected = -1
+	}
+	if browser.pendingDelete >= count {
+		browser.pendingDelete = -1
+	}
+
+	ctx.GridCell(func(bounds image.Rectangle) {
+		ctx.SetGridLayout([]int{40, -1}, []int{-1})
+		ctx.Text("Filter:")
+		ctx.TextField(&browser.filter)
+	})
+}
+
+// presetList draws the presets matching the filter grouped by folder.
+func (ui *ui) presetList(ctx *debugui.Context, browser *presetBrowser, count int, metadata func(i int) presetMetadata, name func(i int) string, row func(i int)) {
+	names, indices := folders(count, func(i int) bool {
+		return metadata(i).Matches(name(i), browser.filter)
+	}, func(i int) string {
+		return metadata(i).Folder
+	})
+
+	for _, folder := range names {
+		rows := indices[folder]
+		if len(rows) == 0 {
+			continue
+		}
+
+		ctx.IDScope("folder "+folder, func() {
+			drawRows := func() {
+				for _, i := range rows {
+					ctx.IDScope("grid "+strconv.Itoa(i), func() {
+						row(i)
+					})
+				}
+			}
+			if folder == "" {
+				drawRows()
+				return
+			}
+			ctx.TreeNode(folder, drawRows)
+		})
+	}
+}
+
+func (ui *ui) presetDetailsButton(ctx *debugui.Context, browser *presetBrowser, i int, metadata presetMetadata) {
+	ctx.Button("...").On(func() {
+		if browser.selected == i {
+			browser.selected = -1
+			return
+		}
+		browser.selectPreset(i, metadata)
+	})
+}
+
+// presetDetails draws the metadata of the selected preset, edited is called
+// once a change is done.
+func (ui *ui) presetDetails(ctx *debugui.Context, browser *presetBrowser, name *string, metadata *presetMetadata, isBuiltIn bool, edited func()) {
+	ctx.IDScope("details", func() {
+		if isBuiltIn {
+			ctx.Text(*name + " (built-in)")
+			if metadata.Description != "" {
+				ctx.Text(metadata.Description)
+			}
+			if len(metadata.Tags) > 0 {
+				ctx.Text("Tags: " + formatTags(metadata.Tags))
+			}
+			return
+		}
+
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{50, -1}, []int{-1, -1, -1, -1})
+			ctx.Text("Name:")
+			ctx.TextField(name).On(edited)
+			ctx.Text("About:")
+			ctx.TextField(&metadata.Description).On(edited)
+			ctx.Text("Tags:")
+			ctx.TextField(&browser.tags).On(func() {
+				metadata.Tags = parseTags(browser.tags)
+				browser.tags = formatTags(metadata.Tags)
+				edited()
+			})
+			ctx.Text("Folder:")
+			ctx.TextField(&metadata.Folder).On(edited)
+		})
+		if !metadata.Created.IsZero() {
+			ctx.Text("Created " + metadata.Created.Local().Format(time.DateTime))
+		}
+		if !metadata.Modified.IsZero() {
+			ctx.Text("Modified " + metadata.Modified.Local().Format(time.DateTime))
+		}
+	})
+}
+
+// presetActions draws the buttons below the details, built-in presets can
+// only be duplicated.
+func (ui *ui) presetActions(ctx *debugui.Context, browser *presetBrowser, isBuiltIn bool, duplicate func(), move func(delta int)) {
+	ctx.GridCell(func(bounds image.Rectangle) {
+		if isBuiltIn {
+			ctx.SetGridLayout([]int{-2, -1}, []int{-1})
+		} else {
+			ctx.SetGridLayout([]int{-2, -1, -1, -1}, []int{-1})
+		}
+		ctx.Button("Duplicate").On(duplicate)
+		if !isBuiltIn {
+			ctx.Button("Up").On(func() {
+				move(-1)
+			})
+			ctx.Button("Down").On(func() {
+				move(1)
+			})
+		}
+		ctx.Button("Close").On(func() {
+			browser.selected = -1
+		})
+	})
+}
+
+// confirmPresetDelete asks before deleting the preset at index i if it is
+// waiting for the confirmation and returns whether it did.
+func (ui *ui) confirmPresetDelete(ctx *debugui.Context, browser *presetBrowser, i int, name string, deletePreset func()) bool {
+	if browser.pendingDelete != i {
+		return false
+	}
+
+	ctx.Text(fmt.Sprintf("Delete %s?", name))
+	ctx.GridCell(func(bounds image.Rectangle) {
+		ctx.SetGridLayout([]int{-1, -1}, []int{-1})
+		ctx.Button("Delete").On(func() {
+			deletePreset()
+			browser.pendingDelete = -1
+			browser.selected = -1
+		})
+		ctx.Button("Cancel").On(func() {
+			browser.pendingDelete = -1
+		})
+	})
+
+	return true
+}
+
+func (ui *ui) generateWindow(ctx *debugui.Context, generator *scenarioGenerator) {
+	ctx.Window("Generate", image.Rect(260, 0, 510, 260), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.Dropdown(&generator.scenarioIndex, generator.scenarioNames())
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("seed: ")
+			ctx.NumberField(&generator.seed, 1)
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("bodies: ")
+			ctx.NumberField(&generator.count, 1).On(func() {
+				generator.count = max(generator.count, 1)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("central mass: ")
+			ctx.NumberFieldF(&generator.centralMass, 10.0, 1).On(func() {
+				generator.centralMass = max(generator.centralMass, 1)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("body mass: ")
+			ctx.NumberFieldF(&generator.planetMass, 0.1, 2).On(func() {
+				generator.planetMass = max(generator.planetMass, 0.01)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("inner radius: ")
+			ctx.NumberFieldF(&generator.innerRadius, 1.0, 1).On(func() {
+				generator.innerRadius = min(max(generator.innerRadius, 10), generator.outerRadius)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("outer radius: ")
+			ctx.NumberFieldF(&generator.outerRadius, 1.0, 1).On(func() {
+				generator.outerRadius = max(generator.outerRadius, generator.innerRadius)
+			})
+		})
+		ctx.Checkbox(&generator.replaceScene, "Replace current scene")
+		ctx.Button("Generate").On(func() {
+			generator.shouldGenerate = true
+		})
+	})
+}
+
+func (ui *ui) chaosAnalysisWindow(ctx *debugui.Context, analysis *chaosAnalysis, planetHandler *planetHandler) {
+	ctx.Window("Chaos Analysis", image.Rect(515, 0, 815, 330), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("epsilon: ")
+			ctx.NumberFieldF(&analysis.epsilon, 1e-4, 6).On(func() {
+				analysis.epsilon = max(analysis.epsilon, 1e-9)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-3, -2}, []int{-1})
+			ctx.Text("renormalize every Nth tick:")
+			ctx.Slider(&analysis.RenormalizeEveryNTick, 1, 600, 1)
+		})
+		if analysis.isRunning {
+			ctx.Button("Stop analysis").On(func() {
+				analysis.stop("Stopped")
+			})
+		} else {
+			ctx.Button("Start analysis").On(func() {
+				analysis.start(planetHandler)
+			})
+		}
+
+		readouts := [][]string{
+			{"status:", analysis.status},
+			{"separation:", strconv.FormatFloat(analysis.separation, 'g', 4, 64)},
+			{"ln(d / epsilon) sum:", formatFloat(analysis.logStretch, 3)},
+			{"lyapunov exponent:", formatFloat(analysis.lyapunovExponent, 4)},
+		}
+		for _, readout := range readouts {
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+				ctx.Text(readout[0])
+				ctx.Text(readout[1])
+			})
+		}
+
+		// accumulated divergence over time, the slope is the lyapunov exponent
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1}, []int{120})
+			ctx.DrawOnlyWidget(func(screen *ebiten.Image) {
+				vector.StrokeRect(screen, float32(bounds.Min.X), float32(bounds.Min.Y), float32(bounds.Dx()), float32(bounds.Dy()), 1, SetColor(120, 120, 120, 255), false)
+				if len(analysis.samples) < 2 {
+					return
+				}
+
+				first, last := analysis.samples[0], analysis.samples[len(analysis.samples)-1]
+				minY, maxY := math.Inf(1), math.Inf(-1)
+				for _, sample := range analysis.samples {
+					minY = min(minY, sample.Y)
+					maxY = max(maxY, sample.Y)
+				}
+				if maxY == minY || last.X == first.X {
+					return
+				}
+
+				toScreen := func(sample vector2) (float32, float32) {
+					x := float64(bounds.Min.X) + (sample.X-first.X)/(last.X-first.X)*float64(bounds.Dx())
+					y := float64(bounds.Max.Y) - (sample.Y-minY)/(maxY-minY)*float64(bounds.Dy())
+					return float32(x), float32(y)
+				}
+
+				for i := 0; i < len(analysis.samples)-1; i++ {
+					x1, y1 := toScreen(analysis.samples[i])
+					x2, y2 := toScreen(analysis.samples[i+1])
+					vector.StrokeLine(screen, x1, y1, x2, y2, 1.5, SetColor(255, 120, 80, 255), true)
+				}
+			})
+		})
+	})
+}
+
+func (ui *ui) bodyTableWindow(ctx *debugui.Context, table *bodyTable) {
+	ctx.Window("Body Table (CSV)", image.Rect(820, 0, 1070, 330), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -3}, []int{-1})
+			ctx.Text("file: ")
+			ctx.TextField(&table.filePath)
+		})
+		ctx.TreeNode("Column mapping", func() {
+			for i, name := range bodyTableColumns {
+				ctx.IDScope(name, func() {
+					ctx.GridCell(func(bounds image.Rectangle) {
+						ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+						ctx.Text(name + ": ")
+						ctx.TextField(&table.columns[i])
 					})
 				})
+			}
+		})
+		ctx.TreeNode("Unit scaling", func() {
+			scales := []struct {
+				name  string
+				value *float64
+			}{
+				{"length", &table.lengthScale},
+				{"velocity", &table.velocityScale},
+				{"mass", &table.massScale},
+				{"radius", &table.radiusScale},
+			}
+			for _, scale := range scales {
+				ctx.IDScope(scale.name, func() {
+					ctx.GridCell(func(bounds image.Rectangle) {
+						ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+						ctx.Text(scale.name + ": ")
+						ctx.NumberFieldF(scale.value, 0.1, 4).On(func() {
+							if *scale.value <= 0 {
+								*scale.value = 1
+							}
+						})
+					})
+				})
+			}
+		})
+		ctx.Checkbox(&table.replaceScene, "Replace current scene")
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -1}, []int{-1})
+			ctx.Button("Import").On(func() {
+				table.shouldImport = true
 			})
+			ctx.Button("Export").On(func() {
+				table.shouldExport = true
+			})
+		})
+		if table.err != nil {
+			ctx.Text(table.err.Error())
+		} else if table.status != "" {
+			ctx.Text(table.status)
+		}
+	})
+}
+
+func (ui *ui) horizonsImportWindow(ctx *debugui.Context, importer *horizonsImport) {
+	ctx.Window("Horizons Import", image.Rect(1075, 0, 1325, 260), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -3}, []int{-1})
+			ctx.Text("folder: ")
+			ctx.TextField(&importer.directory)
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("pixels per AU: ")
+			ctx.NumberFieldF(&importer.pixelsPerAU, 1, 1).On(func() {
+				importer.pixelsPerAU = max(importer.pixelsPerAU, 1)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("days per second: ")
+			ctx.NumberFieldF(&importer.daysPerSecond, 0.1, 2).On(func() {
+				importer.daysPerSecond = max(importer.daysPerSecond, 0.01)
+			})
+		})
+		ctx.Checkbox(&importer.addCenterBody, "Add centre body")
+		ctx.Checkbox(&importer.centerOfMassAtRest, "Centre of mass at rest")
+		ctx.Checkbox(&importer.replaceScene, "Replace current scene")
+		ctx.Button("Import").On(func() {
+			importer.shouldImport = true
+		})
+		if importer.err != nil {
+			ctx.Text(importer.err.Error())
+		} else if importer.status != "" {
+			ctx.Text(importer.status)
+		}
+	})
+}
+
+func (ui *ui) recorderWindow(ctx *debugui.Context, recorder *trajectoryRecorder, planetHandler *planetHandler) {
+	ctx.Window("Trajectory Recorder", image.Rect(1075, 265, 1325, 445), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -3}, []int{-1})
+			ctx.Text("file: ")
+			ctx.TextField(&recorder.filePath)
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("format: ")
+			format := int(recorder.format)
+			ctx.Dropdown(&format, recordingFormatNames).On(func() {
+				recorder.format = recordingFormat(format)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("every N ticks: ")
+			ctx.NumberField(&recorder.RecordEveryNTick, 1).On(func() {
+				recorder.RecordEveryNTick = max(recorder.RecordEveryNTick, 1)
+			})
+		})
+
+		if recorder.isRecording {
+			ctx.Button("Stop recording").On(func() {
+				recorder.err = recorder.stop()
+			})
+			ctx.Text(fmt.Sprintf("Recording to %s, %d samples", recorder.fileName(), recorder.samples))
+		} else {
+			ctx.Button("Start recording").On(func() {
+				recorder.err = recorder.start(planetHandler)
+			})
+			if recorder.samples > 0 {
+				ctx.Text(fmt.Sprintf("Wrote %d samples to %s", recorder.samples, recorder.fileName()))
+			}
+		}
+		if recorder.err != nil {
+			ctx.Text(recorder.err.Error())
+		}
+	})
+}
+
+func (ui *ui) recoveryWindow(ctx *debugui.Context, autosave *autosave, screenSize []int) {
+	if !autosave.showRecovery {
+		return
+	}
+
+	x, y := screenSize[0]/2, screenSize[1]/2
+	ctx.Window("Restore Last Session", image.Rect(x-150, y-60, x+150, y+60), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.Text("Autosave of " + autosave.recoveryTime.Format(time.DateTime))
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -1}, []int{-1})
+			ctx.Button("Restore").On(func() {
+				autosave.shouldRestore = true
+			})
+			ctx.Button("Start new").On(func() {
+				autosave.showRecovery = false
+			})
+		})
+	})
+}
+
+func (ui *ui) logEvent(event simulationEvent) {
+	ui.eventLog = append(ui.eventLog, event)
+
+	if len(ui.eventLog) > ui.eventLogSize {
+		ui.eventLog = slices.Delete(ui.eventLog, 0, len(ui.eventLog)-ui.eventLogSize)
+	}
+}
+
+func (ui *ui) toggleEventLogWriter(planetHandler *planetHandler) {
+	if !ui.writeEventLog {
+		planetHandler.events.unsubscribe(ui.eventLogWriterID)
+		if err := ui.eventLogWriter.close(); err != nil {
+			log.Printf("Failed to close event log %s: %v", ui.eventLogFilePath, err)
+		}
+		return
+	}
+
+	writer, err := newEventLogWriter(ui.eventLogFilePath)
+	if err != nil {
+		log.Printf("Failed to open event log %s: %v", ui.eventLogFilePath, err)
+		ui.writeEventLog = false
+		return
+	}
+
+	ui.eventLogWriter = writer
+	ui.eventLogWriterID = planetHandler.events.subscribe(func(event simulationEvent) {
+		if err := writer.write(event); err != nil {
+			log.Printf("Failed to write event log %s: %v", ui.eventLogFilePath, err)
+		}
+	})
+}
+
+func (ui *ui) eventLogWindow(ctx *debugui.Context, planetHandler *planetHandler, screenSize []int) {
+	ctx.Window("Event Log", image.Rect(260, screenSize[1]-220, 760, screenSize[1]), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -1}, []int{-1})
+			ctx.Checkbox(&ui.writeEventLog, "Write to "+ui.eventLogFilePath).On(func() {
+				ui.toggleEventLogWriter(planetHandler)
+			})
+			ctx.Button("Clear").On(func() {
+				ui.eventLog = slices.Delete(ui.eventLog, 0, len(ui.eventLog))
+			})
+		})
+
+		// newest first
+		for i := len(ui.eventLog) - 1; i >= 0; i-- {
+			event := ui.eventLog[i]
+			text := fmt.Sprintf("%d (%.2fs) %s: %s", event.Tick, event.Time, event.Kind, event.Planet)
+			if event.Other != "" {
+				text += ", " + event.Other
+			}
+			if event.Distance != 0 {
+				text += fmt.Sprintf(" at %.1f", event.Distance)
+			}
+			ctx.Text(text)
 		}
 	})
 }
@@ -457,3 +1305,249 @@ func (ui *ui) simulationPresetsWindow(ctx *debugui.Context, simulationPresets *s
 func (ui *ui) Draw(screen *ebiten.Image) {
 	ui.debugui.Draw(screen)
 }
+
+func (ui *ui) replayWindow(ctx *debugui.Context, replay *replay) {
+	ctx.Window("Replay", image.Rect(1075, 450, 1325, 650), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		if player := replay.player; player != nil {
+			ui.replayPlayerControls(ctx, replay, player)
+			return
+		}
+
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-1, -3}, []int{-1})
+			ctx.Text("file: ")
+			ctx.TextField(&replay.filePath)
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("every N ticks: ")
+			ctx.NumberField(&replay.RecordEveryNTick, 1).On(func() {
+				replay.RecordEveryNTick = max(replay.RecordEveryNTick, 1)
+			})
+		})
+
+		if replay.isRecording {
+			ctx.Button("Stop recording").On(func() {
+				replay.shouldStopRecording = true
+			})
+			ctx.Text(fmt.Sprintf("Recording, %d frames", replay.frames))
+		} else {
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-1, -1}, []int{-1})
+				ctx.Button("Start recording").On(func() {
+					replay.shouldStartRecording = true
+				})
+				ctx.Button("Play").On(func() {
+					replay.shouldPlay = true
+				})
+			})
+			if replay.status != "" {
+				ctx.Text(replay.status)
+			}
+		}
+		if replay.err != nil {
+			ctx.Text(replay.err.Error())
+		}
+	})
+}
+
+func (ui *ui) replayPlayerControls(ctx *debugui.Context, replay *replay, player *replayPlayer) {
+	ctx.Text(fmt.Sprintf("Frame %d of %d, tick %d, t = %s", player.frame+1, player.lastFrame()+1, player.state.tick, formatFloat(player.state.time, 2)))
+	ctx.SliderF(&player.Position, 0, float64(player.lastFrame()), 1, 0)
+
+	ctx.GridCell(func(bounds image.Rectangle) {
+		ctx.SetGridLayout([]int{-1, -1, -1, -1}, []int{-1})
+		ctx.Button("<<").On(func() {
+			player.Speed = -max(math.Abs(player.Speed)*2, 1)
+			player.isPaused = false
+		})
+		playText := "Play"
+		if !player.isPaused {
+			playText = "Pause"
+		}
+		ctx.Button(playText).On(func() {
+			player.isPaused = !player.isPaused
+		})
+		ctx.Button(">>").On(func() {
+			player.Speed = max(math.Abs(player.Speed)*2, 1)
+			player.isPaused = false
+		})
+		ctx.Button("1x").On(func() {
+			player.Speed = 1
+		})
+	})
+	ctx.GridCell(func(bounds image.Rectangle) {
+		ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+		ctx.Text("speed: ")
+		ctx.SliderF(&player.Speed, -16, 16, 0.25, 2)
+	})
+
+	ctx.Button("Stop replay").On(func() {
+		replay.shouldStopPlaying = true
+	})
+	ctx.Text("Space pauses, the simulation continues after the replay")
+}
+
+func (ui *ui) timelinesWindow(ctx *debugui.Context, timelines *timelines, planetHandler *planetHandler) {
+	ctx.Window("Timelines", image.Rect(820, 335, 1070, 645), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-3, -1}, []int{-1})
+			ctx.TextField(&timelines.forkName)
+			ctx.Button("Fork").On(func() {
+				timelines.shouldFork = true
+			})
+		})
+
+		for i, branch := range timelines.children(nil) {
+			ui.timelineTreeNode(ctx, timelines, branch, strconv.Itoa(i))
+		}
+
+		ctx.Header("Compare", true, func() {
+			branchNames := []string{"none"}
+			for _, branch := range timelines.branches {
+				branchNames = append(branchNames, branch.name)
+			}
+			compareIndex := slices.Index(timelines.branches, timelines.compare) + 1
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+				ctx.Text("branch:")
+				ctx.Dropdown(&compareIndex, branchNames).On(func() {
+					timelines.compare = nil
+					if compareIndex > 0 {
+						timelines.compare = timelines.branches[compareIndex-1]
+					}
+				})
+			})
+
+			if timelines.compare == nil || len(planetHandler.planets) == 0 {
+				return
+			}
+			if timelines.compare == timelines.current {
+				ctx.Text("Choose a branch other than the shown one")
+				return
+			}
+
+			names := make([]string, len(planetHandler.planets))
+			for i, planet := range planetHandler.planets {
+				names[i] = planet.Name
+			}
+			planetIndex := max(slices.IndexFunc(planetHandler.planets, func(planet *Planet) bool {
+				return planet.id == timelines.compareBodyID
+			}), 0)
+			timelines.compareBodyID = planetHandler.planets[planetIndex].id
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-1, -2}, []int{-1})
+				ctx.Text("body:")
+				ctx.Dropdown(&planetIndex, names).On(func() {
+					timelines.compareBodyID = planetHandler.planets[planetIndex].id
+				})
+			})
+
+			if divergence, ok := timelines.divergence(planetHandler); ok {
+				ctx.Text("Divergence: " + formatFloat(divergence, 2))
+			} else {
+				ctx.Text("The body doesn't exist in " + timelines.compare.name)
+			}
+		})
+
+		if timelines.status != "" {
+			ctx.Text(timelines.status)
+		}
+	})
+}
+
+func (ui *ui) timelineTreeNode(ctx *debugui.Context, timelines *timelines, branch *timelineBranch, id string) {
+	children := timelines.children(branch)
+
+	ctx.IDScope("branch "+id, func() {
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-4, -2, 20}, []int{-1})
+			label := fmt.Sprintf("%s (tick %d)", branch.name, branch.handler.tick)
+			if branch == timelines.current {
+				label = "> " + label
+			}
+			ctx.Button(label).On(func() {
+				timelines.switchTarget = branch
+				timelines.shouldSwitch = true
+			})
+
+			runningText := "Pause"
+			if !branch.handler.running {
+				runningText = "Run"
+			}
+			ctx.Button(runningText).On(func() {
+				branch.handler.running = !branch.handler.running
+			})
+
+			if branch.parent != nil {
+				ctx.Button("X").On(func() {
+					timelines.deleteTarget = branch
+					timelines.shouldDelete = true
+				})
+			}
+		})
+
+		if len(children) == 0 {
+			return
+		}
+
+		ctx.TreeNode(fmt.Sprintf("Forks (%d)", len(children)), func() {
+			for i, child := range children {
+				ui.timelineTreeNode(ctx, timelines, child, id+"."+strconv.Itoa(i))
+			}
+		})
+	})
+}
+
+func (ui *ui) rewindWindow(ctx *debugui.Context, rewind *rewind) {
+	ctx.Window("Rewind", image.Rect(515, 335, 815, 540), func(layout debugui.ContainerLayout) {
+		ui.layouts = append(ui.layouts, layout.BodyBounds)
+		if rewind.isRewinding() {
+			state := rewind.states.at(rewind.shown)
+			newest := rewind.states.at(rewind.states.len() - 1)
+			ctx.Text(fmt.Sprintf("Tick %d, %s s before the newest state", state.tick, formatFloat(newest.simulatedTime-state.simulatedTime, 2)))
+			ctx.Slider(&rewind.Position, 0, rewind.states.len()-1, 1)
+			ctx.GridCell(func(bounds image.Rectangle) {
+				ctx.SetGridLayout([]int{-1, -1, -2, -2}, []int{-1})
+				ctx.Button("<").On(func() {
+					rewind.shouldStepBackward = true
+				})
+				ctx.Button(">").On(func() {
+					rewind.shouldStepForward = true
+				})
+				ctx.Button("Resume here").On(func() {
+					rewind.shouldResume = true
+				})
+				ctx.Button("Cancel").On(func() {
+					rewind.shouldCancel = true
+				})
+			})
+			ctx.Text("Backspace/Left and Right step, Enter resumes, Escape cancels")
+			return
+		}
+
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("memory (MiB): ")
+			ctx.NumberField(&rewind.BudgetMiB, 8).On(func() {
+				rewind.BudgetMiB = max(rewind.BudgetMiB, 1)
+			})
+		})
+		ctx.GridCell(func(bounds image.Rectangle) {
+			ctx.SetGridLayout([]int{-2, -2}, []int{-1})
+			ctx.Text("every N ticks: ")
+			ctx.NumberField(&rewind.RecordEveryNTick, 1).On(func() {
+				rewind.RecordEveryNTick = max(rewind.RecordEveryNTick, 1)
+			})
+		})
+		ctx.Text(fmt.Sprintf("%d states, %s s, %s of %d MiB", rewind.states.len(), formatFloat(rewind.duration(), 1), formatFloat(float64(rewind.size)/(1<<20), 1), rewind.BudgetMiB))
+		ctx.Button("Rewind (Backspace)").On(func() {
+			rewind.shouldStart = true
+		})
+		if rewind.status != "" {
+			ctx.Text(rewind.status)
+		}
+	})
+}
@@ -1,11 +1,12 @@
 package planetsimulation
 
 import (
+	"errors"
 	"image/color"
-	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 )
 
@@ -14,18 +15,6 @@ type vector2 struct {
 	Y float64 `json:"y"`
 }
 
-func (v vector2) normalize() vector2 {
-	distance := math.Sqrt(v.X*v.X + v.Y*v.Y)
-
-	norX := v.X / distance
-	norY := v.Y / distance
-
-	return vector2{
-		norX,
-		norY,
-	}
-}
-
 func (v vector2) add(v2 vector2) vector2 {
 	return vector2{
 		v.X + v2.X,
@@ -37,6 +26,24 @@ type ColorDelta struct {
 	R, G, B, A *uint8
 }
 
+// apply returns c with the channels of the delta that are set.
+func (colorDelta ColorDelta) apply(c color.NRGBA) color.NRGBA {
+	if colorDelta.R != nil {
+		c.R = *colorDelta.R
+	}
+	if colorDelta.G != nil {
+		c.G = *colorDelta.G
+	}
+	if colorDelta.B != nil {
+		c.B = *colorDelta.B
+	}
+	if colorDelta.A != nil {
+		c.A = *colorDelta.A
+	}
+
+	return c
+}
+
 func SetColor(r uint8, g uint8, b uint8, a uint8) color.NRGBA {
 	color := color.NRGBA{
 		R: r, G: g, B: b, A: a,
@@ -91,24 +98,78 @@ func formatFloat(v float64, n int) string {
 	return strconv.FormatFloat(v, 'f', n, 64)
 }
 
-func readFile(path string) []byte {
-	if _, err := os.Stat(path); err != nil {
-		return []byte{}
+// readFile returns the content of path or nothing if it doesn't exist.
+func readFile(path string) ([]byte, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return []byte{}, nil
 	}
 
-	content, err := os.ReadFile(path)
-	if err != nil {
-		log.Printf("Failed to read file %s: %v", path, err)
+	return os.ReadFile(path)
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
 	}
-	return content
+
+	return os.WriteFile(path, content, 0o644)
 }
 
-func writeFile(path string, content []byte) {
-	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
-		log.Printf("Failed to create dir for %s file: %v", path, err)
+// writeFileAtomic writes content to a temporary file next to path and renames
+// it, so path always holds either the old or the new content.
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(path, content, os.ModePerm); err != nil {
-		log.Printf("Failed to create file %s: %v", path, err)
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
 	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// userDataDir returns the directory for data of the application:
+// $XDG_DATA_HOME (or ~/.local/share) on Linux, %LocalAppData% on Windows and
+// ~/Library/Application Support on macOS.
+func userDataDir() (string, error) {
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		dir = os.Getenv("LocalAppData")
+		if dir == "" {
+			return "", errors.New("%LocalAppData% is not defined")
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, "Library", "Application Support")
+	default:
+		dir = os.Getenv("XDG_DATA_HOME")
+		if !filepath.IsAbs(dir) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+
+	return filepath.Join(dir, "PlanetSimulation"), nil
 }
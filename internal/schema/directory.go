@@ -0,0 +1,18 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// UserDirectory returns the directory of the presets of the user in their
+// config directory: $XDG_CONFIG_HOME (or ~/.config) on Linux, %AppData% on
+// Windows and ~/Library/Application Support on macOS.
+func UserDirectory() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, "PlanetSimulation"), nil
+}
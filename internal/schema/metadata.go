@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Metadata describes a planet or simulation preset, it is stored with the
+// preset.
+type Metadata struct {
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Folder      string    `json:"folder,omitempty"`
+	Created     time.Time `json:"created,omitzero"`
+	Modified    time.Time `json:"modified,omitzero"`
+}
+
+// Touch marks the preset as modified now, and as created if it is new.
+func (metadata *Metadata) Touch() {
+	now := time.Now().Truncate(time.Second)
+	if metadata.Created.IsZero() {
+		metadata.Created = now
+	}
+	metadata.Modified = now
+}
+
+// Clone returns a copy that doesn't share the tags.
+func (metadata Metadata) Clone() Metadata {
+	metadata.Tags = slices.Clone(metadata.Tags)
+	return metadata
+}
+
+// Matches reports whether every word of filter is part of the name, the
+// description, the folder or a tag. Words starting with # have to be a tag.
+func (metadata Metadata) Matches(name string, filter string) bool {
+	text := strings.ToLower(strings.Join(append([]string{name, metadata.Description, metadata.Folder}, metadata.Tags...), "\n"))
+
+	for _, word := range strings.Fields(strings.ToLower(filter)) {
+		if tag, ok := strings.CutPrefix(word, "#"); ok {
+			if !slices.ContainsFunc(metadata.Tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+				return false
+			}
+			continue
+		}
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,479 @@
+// Package schema is the file format of the presets. It doesn't depend on
+// Ebiten, so the game and the headless runs read and validate presets the
+// same way.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"PlanetSimulation/internal/physics"
+)
+
+// Preset files are JSON documents with an explicit version. Files without a
+// version field are raw dumps of the old internal structs (version 0) and
+// are migrated when loaded; they are written back in the current version on
+// the next save.
+//
+// Version 2 of simulation_presets.json:
+//
+//	{
+//	  "version": 2,
+//	  "presets": [{
+//	    "name": "Figure-eight",
+//	    ...metadata,
+//	    "gravitationalConstant": 10000,  // optional, > 0
+//	    "integrator": "leapfrog",        // optional, "euler" or "leapfrog"
+//	    "timeStep": 0.004,               // optional, >= 0, 0 follows the frame time
+//	    "bodies": [body, ...]
+//	  }]
+//	}
+//
+// Version 2 of planet_presets.json:
+//
+//	{
+//	  "version": 2,
+//	  "planets": [{...body, ...metadata}, ...]
+//	}
+//
+// with the optional metadata
+//
+//	"description": "text",
+//	"tags": ["three-body", ...],
+//	"folder": "Periodic orbits",
+//	"created": "2024-05-01T12:00:00Z",
+//	"modified": "2024-05-01T12:00:00Z"
+//
+// Version 1 is version 2 without the metadata other than the description of
+// simulation presets.
+//
+// with every body being
+//
+//	{
+//	  "name": "Earth",
+//	  "x": 0, "y": 0,                     // screen y points down
+//	  "velocity": {"x": 0, "y": 0},
+//	  "mass": 5,                          // > 0
+//	  "radius": 10,                       // > 0
+//	  "color": {"r": 255, "g": 0, "b": 0, "a": 255},
+//	  "trace": {"width": 1.5, "everyNTick": 5, "drawEveryNTick": 1, "antialias": false}
+//	}
+const Version = 2
+
+const (
+	SimulationPresetsFileName = "simulation_presets.json"
+	PlanetPresetsFileName     = "planet_presets.json"
+)
+
+type Color struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+	A uint8 `json:"a"`
+}
+
+type Trace struct {
+	Width          float64 `json:"width"`
+	EveryNTick     int     `json:"everyNTick"`
+	DrawEveryNTick int     `json:"drawEveryNTick"`
+	Antialias      bool    `json:"antialias"`
+}
+
+type Body struct {
+	Name     string         `json:"name"`
+	X        float64        `json:"x"`
+	Y        float64        `json:"y"`
+	Velocity physics.Vector `json:"velocity"`
+	Mass     float64        `json:"mass"`
+	Radius   float64        `json:"radius"`
+	Color    Color          `json:"color"`
+	Trace    Trace          `json:"trace"`
+}
+
+type SimulationPreset struct {
+	Name string `json:"name"`
+	Metadata
+	GravitationalConstant float64 `json:"gravitationalConstant,omitempty"`
+	Integrator            string  `json:"integrator,omitempty"`
+	TimeStep              float64 `json:"timeStep,omitempty"`
+	Bodies                []Body  `json:"bodies"`
+}
+
+type SimulationPresetsFile struct {
+	Version int                `json:"version"`
+	Presets []SimulationPreset `json:"presets"`
+}
+
+type PlanetPreset struct {
+	Body
+	Metadata
+}
+
+type PlanetPresetsFile struct {
+	Version int            `json:"version"`
+	Planets []PlanetPreset `json:"planets"`
+}
+
+// ValidationError points to the offending value, e.g.
+// "presets[2].bodies[0].mass: must be positive, got -1".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Path, err.Message)
+}
+
+func IsFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func ValidateFinite(path string, v float64) error {
+	if !IsFinite(v) {
+		return &ValidationError{path, fmt.Sprintf("must be a finite number, got %v", v)}
+	}
+
+	return nil
+}
+
+func ValidatePositive(path string, v float64) error {
+	if !IsFinite(v) || v <= 0 {
+		return &ValidationError{path, fmt.Sprintf("must be positive, got %v", v)}
+	}
+
+	return nil
+}
+
+// field returns the path of name in path, path is empty at the top level.
+func field(path string, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// ValidatePhysics checks the values the physics depend on. Headless runs
+// only need these.
+func (body Body) ValidatePhysics(path string) error {
+	return errors.Join(
+		ValidateFinite(field(path, "x"), body.X),
+		ValidateFinite(field(path, "y"), body.Y),
+		ValidateFinite(field(path, "velocity.x"), body.Velocity.X),
+		ValidateFinite(field(path, "velocity.y"), body.Velocity.Y),
+		ValidatePositive(field(path, "mass"), body.Mass),
+		ValidatePositive(field(path, "radius"), body.Radius),
+	)
+}
+
+func (body Body) Validate(path string) error {
+	errs := []error{
+		body.ValidatePhysics(path),
+		ValidatePositive(field(path, "trace.width"), body.Trace.Width),
+	}
+
+	if body.Trace.EveryNTick < 1 {
+		errs = append(errs, &ValidationError{field(path, "trace.everyNTick"), fmt.Sprintf("must be at least 1, got %d", body.Trace.EveryNTick)})
+	}
+	if body.Trace.DrawEveryNTick < 1 {
+		errs = append(errs, &ValidationError{field(path, "trace.drawEveryNTick"), fmt.Sprintf("must be at least 1, got %d", body.Trace.DrawEveryNTick)})
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateSettings checks the values other than the bodies. A gravitational
+// constant of 0 isn't set, the game keeps its current one and headless runs
+// use the default.
+func (preset SimulationPreset) validateSettings(path string) error {
+	errs := make([]error, 0)
+
+	if preset.GravitationalConstant != 0 {
+		errs = append(errs, ValidatePositive(field(path, "gravitationalConstant"), preset.GravitationalConstant))
+	}
+	if _, ok := physics.ParseIntegrator(preset.Integrator); preset.Integrator != "" && !ok {
+		errs = append(errs, &ValidationError{field(path, "integrator"), fmt.Sprintf("unknown integrator %q", preset.Integrator)})
+	}
+	if !IsFinite(preset.TimeStep) || preset.TimeStep < 0 {
+		errs = append(errs, &ValidationError{field(path, "timeStep"), fmt.Sprintf("must be zero or positive, got %v", preset.TimeStep)})
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidatePhysics checks the settings and the values of the bodies the
+// physics depend on.
+func (preset SimulationPreset) ValidatePhysics(path string) error {
+	errs := []error{preset.validateSettings(path)}
+	for i, body := range preset.Bodies {
+		errs = append(errs, body.ValidatePhysics(field(path, fmt.Sprintf("bodies[%d]", i))))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (preset SimulationPreset) Validate(path string) error {
+	errs := []error{preset.validateSettings(path)}
+	for i, body := range preset.Bodies {
+		errs = append(errs, body.Validate(field(path, fmt.Sprintf("bodies[%d]", i))))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (file SimulationPresetsFile) Validate() error {
+	errs := make([]error, 0)
+	for i, preset := range file.Presets {
+		errs = append(errs, preset.Validate(fmt.Sprintf("presets[%d]", i)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (file PlanetPresetsFile) Validate() error {
+	errs := make([]error, 0)
+	for i, planet := range file.Planets {
+		errs = append(errs, planet.Validate(fmt.Sprintf("planets[%d]", i)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// fileVersion returns the version of a preset file, 0 if it has none.
+func fileVersion(content []byte) (int, error) {
+	var header struct {
+		Version *int `json:"version"`
+	}
+
+	// version 0 planet preset files aren't objects
+	if err := json.Unmarshal(content, &header); err != nil {
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) && typeError.Field == "" {
+			return 0, nil
+		}
+		return 0, JSONError(content, err)
+	}
+
+	if header.Version == nil {
+		return 0, nil
+	}
+	if *header.Version < 0 || *header.Version > Version {
+		return 0, fmt.Errorf("unsupported version %d, this build supports up to %d", *header.Version, Version)
+	}
+
+	return *header.Version, nil
+}
+
+// JSONError adds the line and column to syntax and type errors. They point to
+// the character the decoder stopped at, the offending one of a syntax error
+// and the last one of a value of the wrong type.
+func JSONError(content []byte, err error) error {
+	var offset int64
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		offset = syntaxError.Offset
+	case errors.As(err, &typeError):
+		offset = typeError.Offset
+	default:
+		return err
+	}
+
+	// the offset is just after the character
+	line, column := 1, 1
+	for _, char := range content[:min(max(int(offset)-1, 0), len(content))] {
+		if char == '\n' {
+			line++
+			column = 1
+			continue
+		}
+		column++
+	}
+
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+// migrate upgrades content step by step from its version to the current one.
+func migrate(content []byte, migrations []func(content []byte) ([]byte, error)) ([]byte, error) {
+	version, err := fileVersion(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for ; version < Version; version++ {
+		content, err = migrations[version](content)
+		if err != nil {
+			return nil, fmt.Errorf("migrating from version %d: %w", version, err)
+		}
+	}
+
+	return content, nil
+}
+
+// legacyPlanet is a Planet as it was dumped by version 0.
+type legacyPlanet struct {
+	Name            string
+	X               float64
+	Y               float64
+	Radius          float64
+	Velocity        physics.Vector `json:"velocity"`
+	Mass            float64
+	Color           Color
+	TraceWidth      float64
+	AntialiasTraces bool
+	TraceEveryNTick int
+	DrawEveryNTick  int
+}
+
+func (planet legacyPlanet) body() Body {
+	return Body{
+		Name:     planet.Name,
+		X:        planet.X,
+		Y:        planet.Y,
+		Velocity: planet.Velocity,
+		Mass:     planet.Mass,
+		Radius:   planet.Radius,
+		Color:    planet.Color,
+		Trace: Trace{
+			Width:          planet.TraceWidth,
+			EveryNTick:     planet.TraceEveryNTick,
+			DrawEveryNTick: planet.DrawEveryNTick,
+			Antialias:      planet.AntialiasTraces,
+		},
+	}
+}
+
+var simulationPresetsMigrations = []func(content []byte) ([]byte, error){
+	// 0 -> 1: {"Presets": [{"Name", "Planets": [Planet]}]}
+	func(content []byte) ([]byte, error) {
+		var legacy struct {
+			Presets []struct {
+				Name                  string
+				Description           string
+				GravitationalConstant float64
+				Integrator            string
+				TimeStep              float64
+				Planets               []legacyPlanet
+			}
+		}
+		if err := json.Unmarshal(content, &legacy); err != nil {
+			return nil, err
+		}
+
+		file := SimulationPresetsFile{Version: 1, Presets: make([]SimulationPreset, 0)}
+		for _, preset := range legacy.Presets {
+			record := SimulationPreset{
+				Name:                  preset.Name,
+				Metadata:              Metadata{Description: preset.Description},
+				GravitationalConstant: preset.GravitationalConstant,
+				Integrator:            preset.Integrator,
+				TimeStep:              preset.TimeStep,
+				Bodies:                make([]Body, 0),
+			}
+			for _, planet := range preset.Planets {
+				record.Bodies = append(record.Bodies, planet.body())
+			}
+			file.Presets = append(file.Presets, record)
+		}
+
+		return json.Marshal(file)
+	},
+	// 1 -> 2: adds optional metadata
+	setVersion(2),
+}
+
+var planetPresetsMigrations = []func(content []byte) ([]byte, error){
+	// 0 -> 1: either a list of Planets or, because of an old bug, just the
+	// file path as a string in which case there is nothing to migrate
+	func(content []byte) ([]byte, error) {
+		file := PlanetPresetsFile{Version: 1, Planets: make([]PlanetPreset, 0)}
+
+		var path string
+		if err := json.Unmarshal(content, &path); err == nil {
+			return json.Marshal(file)
+		}
+
+		var legacy []legacyPlanet
+		if err := json.Unmarshal(content, &legacy); err != nil {
+			return nil, err
+		}
+		for _, planet := range legacy {
+			file.Planets = append(file.Planets, PlanetPreset{Body: planet.body()})
+		}
+
+		return json.Marshal(file)
+	},
+	// 1 -> 2: adds optional metadata
+	setVersion(2),
+}
+
+// setVersion returns a migration that only changes the version, for versions
+// that add optional fields.
+func setVersion(version int) func(content []byte) ([]byte, error) {
+	return func(content []byte) ([]byte, error) {
+		var file map[string]json.RawMessage
+		if err := json.Unmarshal(content, &file); err != nil {
+			return nil, err
+		}
+		file["version"] = json.RawMessage(strconv.Itoa(version))
+
+		return json.Marshal(file)
+	}
+}
+
+// ParseSimulationPresets migrates and parses a simulation presets file
+// without validating it. Empty content means there is no file yet.
+func ParseSimulationPresets(content []byte) (SimulationPresetsFile, error) {
+	file := SimulationPresetsFile{Version: Version}
+	if len(content) == 0 {
+		return file, nil
+	}
+
+	content, err := migrate(content, simulationPresetsMigrations)
+	if err != nil {
+		return file, err
+	}
+
+	if err := json.Unmarshal(content, &file); err != nil {
+		return file, JSONError(content, err)
+	}
+
+	return file, nil
+}
+
+// DecodeSimulationPresets migrates, parses and validates a simulation presets
+// file. Empty content means there is no file yet.
+func DecodeSimulationPresets(content []byte) (SimulationPresetsFile, error) {
+	file, err := ParseSimulationPresets(content)
+	if err != nil {
+		return file, err
+	}
+
+	return file, file.Validate()
+}
+
+// DecodePlanetPresets migrates, parses and validates a planet presets file.
+// Empty content means there is no file yet.
+func DecodePlanetPresets(content []byte) (PlanetPresetsFile, error) {
+	file := PlanetPresetsFile{Version: Version}
+	if len(content) == 0 {
+		return file, nil
+	}
+
+	content, err := migrate(content, planetPresetsMigrations)
+	if err != nil {
+		return file, err
+	}
+
+	if err := json.Unmarshal(content, &file); err != nil {
+		return file, JSONError(content, err)
+	}
+
+	return file, file.Validate()
+}
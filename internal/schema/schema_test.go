@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"PlanetSimulation/internal/physics"
+)
+
+func TestMetadataMatches(t *testing.T) {
+	metadata := Metadata{Description: "Three equal masses", Tags: []string{"three-body"}, Folder: "Periodic orbits"}
+
+	tests := map[string]bool{
+		"":                 true,
+		"EIGHT":            true,
+		"periodic equal":   true,
+		"#three-body":      true,
+		"#three":           false,
+		"eight #stars":     false,
+		"eight solar":      false,
+		"#Three-Body mass": true,
+	}
+
+	for filter, want := range tests {
+		if got := metadata.Matches("Figure-eight", filter); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestValidateSimulationPreset(t *testing.T) {
+	body := Body{Name: "Star", Mass: 1, Radius: 1, Trace: Trace{Width: 1, EveryNTick: 1, DrawEveryNTick: 1}}
+
+	// without a gravitational constant the default is used
+	preset := SimulationPreset{Name: "Star", Bodies: []Body{body}}
+	if err := preset.Validate("presets[0]"); err != nil {
+		t.Errorf("a preset without a gravitational constant is invalid: %v", err)
+	}
+
+	preset.GravitationalConstant = -1
+	preset.Integrator = "rk4"
+	err := preset.Validate("presets[0]")
+	for _, want := range []string{
+		"presets[0].gravitationalConstant: must be positive, got -1",
+		`presets[0].integrator: unknown integrator "rk4"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v doesn't contain %q", err, want)
+		}
+	}
+}
+
+func TestValidatePhysicsIgnoresTraces(t *testing.T) {
+	preset := SimulationPreset{Bodies: []Body{{Name: "Star", Mass: 1, Radius: 1}}}
+	if err := preset.ValidatePhysics(""); err != nil {
+		t.Errorf("a body without a trace is invalid for the physics: %v", err)
+	}
+	if err := preset.Validate(""); err == nil || !strings.Contains(err.Error(), "bodies[0].trace.width") {
+		t.Errorf("error %v doesn't point to the trace width", err)
+	}
+
+	preset.Bodies[0].Mass = 0
+	if err := preset.ValidatePhysics(""); err == nil || !strings.Contains(err.Error(), "bodies[0].mass: must be positive, got 0") {
+		t.Errorf("error %v doesn't point to the mass", err)
+	}
+}
+
+func TestMigrateLegacyPlanetPresets(t *testing.T) {
+	legacy := `[{"Name": "Earth", "X": 1, "Y": 2, "Radius": 3, "velocity": {"x": 4, "y": 5}, "Mass": 6,
+		"Color": {"R": 10, "G": 20, "B": 30, "A": 255},
+		"TraceWidth": 1.5, "AntialiasTraces": true, "TraceEveryNTick": 5, "DrawEveryNTick": 2}]`
+
+	file, err := DecodePlanetPresets([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Body{
+		Name:     "Earth",
+		X:        1,
+		Y:        2,
+		Velocity: physics.Vector{X: 4, Y: 5},
+		Mass:     6,
+		Radius:   3,
+		Color:    Color{10, 20, 30, 255},
+		Trace:    Trace{Width: 1.5, EveryNTick: 5, DrawEveryNTick: 2, Antialias: true},
+	}
+	if file.Version != Version || len(file.Planets) != 1 || file.Planets[0].Body != want {
+		t.Errorf("file = %+v, want version %d with %+v", file, Version, want)
+	}
+}
+
+func TestMigratePlanetPresetsPath(t *testing.T) {
+	// an old bug saved the path of the file instead of the presets
+	file, err := DecodePlanetPresets([]byte(`"assets/data/planet_presets.json"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != Version || len(file.Planets) != 0 {
+		t.Errorf("file = %+v, want version %d without planets", file, Version)
+	}
+}
+
+func TestMigrateSimulationPresetsMetadata(t *testing.T) {
+	version1 := `{"version": 1, "presets": [{"name": "Binary", "description": "Two stars", "gravitationalConstant": 5, "bodies": []}]}`
+
+	file, err := DecodeSimulationPresets([]byte(version1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != Version || len(file.Presets) != 1 {
+		t.Fatalf("file = %+v, want version %d with one preset", file, Version)
+	}
+	preset := file.Presets[0]
+	if preset.Name != "Binary" || preset.Description != "Two stars" || preset.GravitationalConstant != 5 {
+		t.Errorf("preset = %+v", preset)
+	}
+	if len(preset.Tags) != 0 || preset.Folder != "" || !preset.Created.IsZero() || !preset.Modified.IsZero() {
+		t.Errorf("metadata = %+v, want only the description", preset.Metadata)
+	}
+}
+
+func TestMalformedFileReportsPosition(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"syntax":  {"{\n  \"version\": 2,\n  \"presets\": [}\n}", "line 3, column 15: invalid character '}'"},
+		"type":    {"{\n  \"version\": 2,\n  \"presets\": [{\"bodies\": [{\"mass\": \"heavy\"}]}]\n}", "line 3, column 42: json: cannot unmarshal string"},
+		"version": {"{\n  \"version\": \"two\"\n}", "line 2, column 18: json: cannot unmarshal string"},
+		"future":  {`{"version": 3, "presets": []}`, "unsupported version 3"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeSimulationPresets([]byte(test.content))
+			if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+				t.Errorf("error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}